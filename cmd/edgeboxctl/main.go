@@ -9,13 +9,18 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/edgebox-iot/edgeboxctl/internal/config"
 	"github.com/edgebox-iot/edgeboxctl/internal/diagnostics"
+	"github.com/edgebox-iot/edgeboxctl/internal/health"
+	"github.com/edgebox-iot/edgeboxctl/internal/options"
+	"github.com/edgebox-iot/edgeboxctl/internal/server"
 	"github.com/edgebox-iot/edgeboxctl/internal/tasks"
 	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+	"github.com/edgebox-iot/edgeboxctl/internal/webhooks"
 )
 
-const defaultNotReadySleepTime time.Duration = time.Second * 60
 const defaultSleepTime time.Duration = time.Second
+const migrationRetrySleepTime time.Duration = time.Second * 30
 
 func main() {
 
@@ -23,10 +28,52 @@ func main() {
 
 	version := flag.Bool("version", false, "Get the version info")
 	db := flag.Bool("database", false, "Get database connection info")
-	name := flag.String("name", "edgebox", "Name for the service")
+	name := flag.String("name", config.DefaultInstanceName, "Name for the service (instance name)")
+	root := flag.String("root", config.DefaultInstanceRoot, "Root folder of the instance, from where all default paths are derived")
+	configFile := flag.String("config", config.DefaultConfigFileLocation, "Location of the configuration file")
 
 	flag.Parse()
 
+	// The reference is generated at build time, without a configuration
+	if flag.Arg(0) == "options" && flag.Arg(1) == "reference" {
+		fmt.Print(options.Reference())
+		os.Exit(0)
+	}
+
+	// Only flags explicitly given take precedence over the config file and environment
+	instance := config.Instance{}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			instance.Name = *name
+		case "root":
+			instance.Root = *root
+		}
+	})
+
+	err := config.LoadInstance(*configFile, instance)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The release can be overriden via configuration, taking precedence over the build-time value.
+	diagnostics.Version = config.Get().Release
+
+	if flag.Arg(0) == "config" {
+		runConfigCommand(flag.Args()[1:])
+		os.Exit(0)
+	}
+
+	if flag.Arg(0) == "options" {
+		runOptionsCommand(flag.Args()[1:])
+		os.Exit(0)
+	}
+
+	if flag.Arg(0) == "secrets" {
+		runSecretsCommand(flag.Args()[1:])
+		os.Exit(0)
+	}
+
 	if *version {
 		printVersion()
 		os.Exit(0)
@@ -37,7 +84,7 @@ func main() {
 		os.Exit(0)
 	}
 
-	log.Printf("Starting edgeboxctl service for %s", *name)
+	log.Printf("Starting edgeboxctl service for %s (%s)", config.Get().Instance.Name, config.Get().Instance.Root)
 
 	// setup signal catching
 	sigs := make(chan os.Signal, 1)
@@ -60,20 +107,36 @@ func main() {
 
 	printDbDetails()
 
+	err = server.Start()
+	if err != nil {
+		log.Printf("Control API could not be started: %s", err)
+	}
+
+	webhooks.Start()
+
 	tick := 0
+	migrated := false
 
 	// infinite loop
 	for {
 
-		if isSystemReady() {
-			tick++ // Tick is an int, so eventually will "go out of ticks?" Maybe we want to reset the ticks every once in a while, to avoid working with big numbers...
-			systemIterator(name, tick)
-		} else {
-			// Wait about 60 seconds before trying again.
-			log.Printf("System not ready. Next try will be executed in 60 seconds")
-			time.Sleep(defaultNotReadySleepTime)
+		if !health.IsReady() {
+			health.WaitUntilReady()
+			log.Printf("System is ready")
 		}
 
+		// Tables owned by edgeboxctl are migrated once the api database exists, before executing any task
+		if !migrated {
+			migrated = migrateDatabase()
+			if !migrated {
+				time.Sleep(migrationRetrySleepTime)
+				continue
+			}
+		}
+
+		tick++ // Tick is an int, so eventually will "go out of ticks?" Maybe we want to reset the ticks every once in a while, to avoid working with big numbers...
+		systemIterator(tick)
+
 	}
 
 }
@@ -90,6 +153,69 @@ func printVersion() {
 	)
 }
 
+// runConfigCommand : Handles the "config" subcommand (edgeboxctl config show)
+func runConfigCommand(args []string) {
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Println("Usage: edgeboxctl config show")
+		os.Exit(1)
+	}
+
+	fmt.Print(config.Get().String())
+}
+
+// runSecretsCommand : Handles the "secrets" subcommand (edgeboxctl secrets rotate)
+func runSecretsCommand(args []string) {
+	if len(args) == 0 || args[0] != "rotate" {
+		fmt.Println("Usage: edgeboxctl secrets rotate")
+		os.Exit(1)
+	}
+
+	count, err := options.RotateSecretKey()
+	if err != nil {
+		log.Fatalf("Error rotating the secret key: %s", err)
+	}
+
+	fmt.Printf("Rotated the secret key, %d secret options encrypted with the new key\n", count)
+}
+
+// runOptionsCommand : Handles the "options" subcommand (edgeboxctl options history [key]), "options reference" is handled before loading the configuration
+func runOptionsCommand(args []string) {
+	if len(args) == 0 || args[0] != "history" || len(args) > 2 {
+		fmt.Println("Usage: edgeboxctl options reference|history [key]")
+		os.Exit(1)
+	}
+
+	key := ""
+	if len(args) == 2 {
+		key = args[1]
+	}
+
+	changes, err := options.History(key, utils.OPTION_HISTORY_LIMIT)
+	if err != nil {
+		log.Fatalf("Error reading the option history: %s", err)
+	}
+
+	// Oldest first, as a log
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		fmt.Printf("%s  %-30s %-45s %q -> %q\n", change.Created, change.Source, change.Name, change.OldValue, change.NewValue)
+	}
+}
+
+// migrateDatabase : Applies pending database migrations, returns false if they could not be applied
+func migrateDatabase() bool {
+	applied, err := utils.MigrateDatabase()
+	if err != nil {
+		log.Printf("Error migrating the database schema: %s", err)
+		return false
+	}
+
+	if applied > 0 {
+		log.Printf("Database schema migrated to version %d", utils.LatestSchemaVersion())
+	}
+	return true
+}
+
 func printDbDetails() {
 	fmt.Printf(
 		"\n\nSQLite Database Location:\n %s\n\n",
@@ -97,18 +223,7 @@ func printDbDetails() {
 	)
 }
 
-// IsSystemReady : Checks hability of the service to execute commands (Only after "edgebox --build" is ran at least once via SSH, or if built for distribution)
-func isSystemReady() bool {
-	_, err := os.Stat(utils.GetPath(utils.WsPath) + ".ready")
-	return !os.IsNotExist(err)
-}
-
-// IsDatabaseReady : Checks is it can successfully connect to the task queue db
-func isDatabaseReady() bool {
-	return false
-}
-
-func systemIterator(name *string, tick int) {
+func systemIterator(tick int) {
 
 	log.Printf("Tick is %d", tick)
 
@@ -1,15 +1,12 @@
 package backups
 
-// import (
-// 	"fmt"
-// 	"os"
-// 	"path/filepath"
-// 	"strings"
+import (
+	"strconv"
+	"strings"
+	"time"
 
-// 	"github.com/edgebox-iot/edgeboxctl/internal/diagnostics"
-// 	"github.com/edgebox-iot/edgeboxctl/internal/utils"
-// 	"github.com/shirou/gopsutil/disk"
-// )
+	"github.com/edgebox-iot/edgeboxctl/internal/options"
+)
 
 // Repository : Struct representing the backup repository of a device in the system
 type Repository struct {
@@ -24,5 +21,88 @@ type Repository struct {
 // Snapshot : Struct representing a single snapshot in the backup repository
 type Snapshot struct {
 	ID         string 			`json:"id"`
-	time 	   string           `json:"time"`
-}
\ No newline at end of file
+	Time 	   string           `json:"time"`
+}
+
+// Status : Struct representing the current state of backups in the system, as last recorded by the backup tasks
+type Status struct {
+	Status         string `json:"status"`
+	IsWorking      bool   `json:"is_working"`
+	Service        string `json:"service"`
+	RepositoryName string `json:"repository_name"`
+	LastRun        int64  `json:"last_run"`
+	LastSuccess    int64  `json:"last_success"`
+	ErrorMessage   string `json:"error_message"`
+	Stats          string `json:"stats"`
+	Size           uint64 `json:"size"`
+}
+
+// restic prints sizes with binary units, ex: "Total Size: 1.234 GiB"
+var sizeUnits = map[string]float64{
+	"B":   1,
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"TiB": 1 << 40,
+}
+
+// GetStatus : Returns the backup status from the options written by the backup tasks
+func GetStatus() Status {
+
+	isWorking, _ := options.GetBool("BACKUP_IS_WORKING")
+	lastRun, _ := options.GetTime("BACKUP_LAST_RUN")
+	lastSuccess, _ := options.GetTime("BACKUP_LAST_SUCCESS")
+
+	stats, _ := options.GetString("BACKUP_STATS")
+	size, _ := ParseStatsSize(stats)
+
+	status, _ := options.GetEnum("BACKUP_STATUS")
+	service, _ := options.GetEnum("BACKUP_SERVICE")
+	repositoryName, _ := options.GetString("BACKUP_REPOSITORY_NAME")
+	errorMessage, _ := options.GetString("BACKUP_ERROR_MESSAGE")
+
+	return Status{
+		Status:         status,
+		IsWorking:      isWorking,
+		Service:        service,
+		RepositoryName: repositoryName,
+		LastRun:        unixOrZero(lastRun),
+		LastSuccess:    unixOrZero(lastSuccess),
+		ErrorMessage:   errorMessage,
+		Stats:          stats,
+		Size:           size,
+	}
+}
+
+// unixOrZero : Returns the unix timestamp of t, or 0 for the zero time (never happened)
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// ParseStatsSize : Returns the repository size in bytes from the output of restic stats, and false if it is not found
+func ParseStatsSize(stats string) (uint64, bool) {
+	for _, line := range strings.Split(stats, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "Total Size:") {
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(line, "Total Size:"))
+		if len(fields) != 2 {
+			return 0, false
+		}
+
+		value, err := strconv.ParseFloat(fields[0], 64)
+		unit, known := sizeUnits[fields[1]]
+		if err != nil || !known || value < 0 {
+			return 0, false
+		}
+
+		return uint64(value * unit), true
+	}
+
+	return 0, false
+}
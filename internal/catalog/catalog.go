@@ -0,0 +1,344 @@
+package catalog
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/config"
+	"github.com/edgebox-iot/edgeboxctl/internal/edgeapps"
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+// Index : Struct representing the catalog index, listing the EdgeApps that can be installed and where to download them
+type Index struct {
+	Apps     []App `json:"apps"`
+	location string
+}
+
+// App : Struct representing an EdgeApp of the catalog
+type App struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Versions    []Version `json:"versions"` // Newest first
+}
+
+// Version : Struct representing a release of an EdgeApp, packed as a tarball of its folder
+type Version struct {
+	Version   string `json:"version"`
+	URL       string `json:"url"`       // Absolute, or relative to the index
+	SHA256    string `json:"sha256"`    // Hex encoded checksum of the tarball
+	Signature string `json:"signature"` // Base64 ed25519 signature of the raw SHA-256 digest, required when catalog.public_key is set
+}
+
+// ErrNoCatalog : Returned when catalog.index is not configured
+var ErrNoCatalog = errors.New("no EdgeApps catalog configured (catalog.index)")
+
+const requestTimeout time.Duration = time.Minute * 10
+const maxIndexSize int64 = 10 * 1024 * 1024
+
+var client = &http.Client{Timeout: requestTimeout}
+
+// GetIndex : Reads the catalog index from the location configured in catalog.index
+func GetIndex() (Index, error) {
+
+	location := config.Get().Catalog.Index
+	if location == "" {
+		return Index{}, ErrNoCatalog
+	}
+
+	index := Index{location: location}
+
+	reader, err := open(location)
+	if err != nil {
+		return index, fmt.Errorf("error reading catalog index: %s", err)
+	}
+	defer reader.Close()
+
+	err = json.NewDecoder(io.LimitReader(reader, maxIndexSize)).Decode(&index)
+	if err != nil {
+		return index, fmt.Errorf("error parsing catalog index: %s", err)
+	}
+
+	return index, nil
+}
+
+// Find : Returns an EdgeApp of the index and one of its versions, the newest one when version is empty
+func (i Index) Find(ID string, version string) (App, Version, error) {
+
+	for _, app := range i.Apps {
+		if app.ID != ID {
+			continue
+		}
+		for _, v := range app.Versions {
+			if version == "" || v.Version == version {
+				return app, v, nil
+			}
+		}
+		if version == "" {
+			return app, Version{}, fmt.Errorf("EdgeApp %s has no versions in the catalog", ID)
+		}
+		return app, Version{}, fmt.Errorf("version %s of EdgeApp %s not found in the catalog", version, ID)
+	}
+
+	return App{}, Version{}, fmt.Errorf("EdgeApp %s not found in the catalog", ID)
+}
+
+// Download : Downloads the tarball of a version to path, verifying its checksum and signature. Nothing is left at path when verification fails.
+func (i Index) Download(v Version, path string) error {
+
+	reader, err := open(i.resolve(v.URL))
+	if err != nil {
+		return fmt.Errorf("error downloading %s: %s", v.URL, err)
+	}
+	defer reader.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = verify(v, hash.Sum(nil))
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	return nil
+}
+
+// verify : Checks the SHA-256 digest of a downloaded tarball against the index and, when catalog.public_key is set, its signature
+func verify(v Version, digest []byte) error {
+
+	expected, err := hex.DecodeString(strings.TrimSpace(v.SHA256))
+	if err != nil || len(expected) != sha256.Size {
+		return fmt.Errorf("invalid sha256 in the catalog for version %s", v.Version)
+	}
+	if !bytes.Equal(expected, digest) {
+		return fmt.Errorf("checksum mismatch for version %s: expected %s, got %x", v.Version, v.SHA256, digest)
+	}
+
+	publicKey := config.Get().Catalog.PublicKey
+	if publicKey == "" {
+		return nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("invalid catalog.public_key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(v.Signature)
+	if err != nil || v.Signature == "" {
+		return fmt.Errorf("version %s is not signed", v.Version)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), digest, signature) {
+		return fmt.Errorf("invalid signature for version %s", v.Version)
+	}
+
+	return nil
+}
+
+// Install : Downloads an EdgeApp version from the catalog (the newest when version is empty) and places it in the EdgeApps folder, together with the
+// required dependencies that are not available yet. Returns the IDs placed, dependencies last. Installing them is left to the usual install flow.
+func Install(ID string, version string) ([]string, error) {
+
+	if edgeapps.Exists(ID) {
+		return nil, fmt.Errorf("EdgeApp %s is already available", ID)
+	}
+
+	index, err := GetIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	placed := []string{}
+	pending := []string{ID}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+		if edgeapps.Exists(current) {
+			continue
+		}
+
+		requestedVersion := version
+		if current != ID {
+			requestedVersion = ""
+			// Left for the install to report, as dependencies can also come from elsewhere
+			if _, _, err := index.Find(current, ""); err != nil {
+				log.Printf("Not downloading %s, required by %s: %s", current, ID, err)
+				continue
+			}
+		}
+		err = index.place(current, requestedVersion)
+		if err != nil {
+			return placed, err
+		}
+		placed = append(placed, current)
+
+		manifest, err := edgeapps.GetManifest(current)
+		if err == nil {
+			pending = append(pending, manifest.Dependencies.Required...)
+		}
+	}
+
+	return placed, nil
+}
+
+// place : Downloads, verifies and unpacks an EdgeApp of the index into the EdgeApps folder, renaming it into place once complete
+func (i Index) place(ID string, version string) error {
+
+	staged, cleanup, err := i.stage(ID, version)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	return os.Rename(staged, utils.GetPath(utils.EdgeAppsPath)+ID)
+}
+
+// Stage : Downloads, verifies and unpacks an EdgeApp version from the catalog (the newest when version is empty) into a hidden folder of the EdgeApps folder.
+// Returns the folder with the EdgeApp definition, and a function removing what is left of the staging once done with it.
+func Stage(ID string, version string) (string, func(), error) {
+
+	index, err := GetIndex()
+	if err != nil {
+		return "", func() {}, err
+	}
+
+	return index.stage(ID, version)
+}
+
+func (i Index) stage(ID string, version string) (string, func(), error) {
+
+	noop := func() {}
+
+	if strings.ContainsAny(ID, "/\\") || strings.HasPrefix(ID, ".") || ID == "" {
+		return "", noop, fmt.Errorf("invalid EdgeApp ID %s", ID)
+	}
+
+	_, v, err := i.Find(ID, version)
+	if err != nil {
+		return "", noop, err
+	}
+
+	staging, err := ioutil.TempDir(utils.GetPath(utils.EdgeAppsPath), "."+ID+".catalog")
+	if err != nil {
+		return "", noop, err
+	}
+	cleanup := func() { os.RemoveAll(staging) }
+
+	log.Printf("Downloading %s %s from the catalog", ID, v.Version)
+	tarball := filepath.Join(staging, "edgeapp.tar")
+	err = i.Download(v, tarball)
+	if err != nil {
+		cleanup()
+		return "", noop, err
+	}
+
+	unpacked := filepath.Join(staging, "edgeapp")
+	err = Unpack(tarball, unpacked)
+	if err == nil {
+		os.Remove(tarball)
+	} else {
+		cleanup()
+		return "", noop, fmt.Errorf("error unpacking %s %s: %s", ID, v.Version, err)
+	}
+
+	root, err := findEdgeAppRoot(unpacked)
+	if err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("invalid tarball for %s %s: %s", ID, v.Version, err)
+	}
+
+	return root, cleanup, nil
+}
+
+// findEdgeAppRoot : Returns the folder with the edgebox-compose.yml file, being the unpacked folder itself or the single folder inside it
+func findEdgeAppRoot(unpacked string) (string, error) {
+
+	if _, err := os.Stat(filepath.Join(unpacked, "edgebox-compose.yml")); err == nil {
+		return unpacked, nil
+	}
+
+	files, err := ioutil.ReadDir(unpacked)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 1 && files[0].IsDir() {
+		root := filepath.Join(unpacked, files[0].Name())
+		if _, err := os.Stat(filepath.Join(root, "edgebox-compose.yml")); err == nil {
+			return root, nil
+		}
+	}
+
+	return "", errors.New("no edgebox-compose.yml found")
+}
+
+// resolve : Returns the location of ref, which can be relative to the index
+func (i Index) resolve(ref string) string {
+
+	if isURL(ref) {
+		return ref
+	}
+
+	if isURL(i.location) {
+		base, err := url.Parse(i.location)
+		if err == nil {
+			relative, err := url.Parse(ref)
+			if err == nil {
+				return base.ResolveReference(relative).String()
+			}
+		}
+		return ref
+	}
+
+	if filepath.IsAbs(ref) {
+		return ref
+	}
+	return filepath.Join(filepath.Dir(i.location), ref)
+}
+
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// open : Opens a local file, or the body of an http(s) URL
+func open(location string) (io.ReadCloser, error) {
+
+	if !isURL(location) {
+		return os.Open(location)
+	}
+
+	response, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("%s responded %s", location, response.Status)
+	}
+
+	return response.Body, nil
+}
@@ -0,0 +1,258 @@
+// +build unit
+
+package catalog
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/config"
+)
+
+type testEntry struct {
+	name     string
+	content  string
+	typeflag byte
+	linkname string
+}
+
+func makeTarball(t *testing.T, entries []testEntry) []byte {
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	writer := tar.NewWriter(gzipWriter)
+	for _, entry := range entries {
+		typeflag := entry.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+		header := &tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.content)), Typeflag: typeflag, Linkname: entry.linkname}
+		if typeflag != tar.TypeReg {
+			header.Size = 0
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte(entry.content))
+	}
+	writer.Close()
+	gzipWriter.Close()
+	return buffer.Bytes()
+}
+
+func setupTestCatalog(t *testing.T, index string, publicKey string) (string, func()) {
+	dir, _ := ioutil.TempDir("", "edgeboxctl-catalog")
+	os.MkdirAll(filepath.Join(dir, "apps"), 0755)
+
+	c := config.Default()
+	c.Paths.EdgeApps = filepath.Join(dir, "apps") + "/"
+	c.Catalog.Index = index
+	c.Catalog.PublicKey = publicKey
+	config.Set(c)
+
+	return dir, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func writeIndex(t *testing.T, path string, apps []App) {
+	content, _ := json.Marshal(Index{Apps: apps})
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checksum(content []byte) string {
+	digest := sha256.Sum256(content)
+	return hex.EncodeToString(digest[:])
+}
+
+func TestInstallFromLocalIndex(t *testing.T) {
+	dir, cleanup := setupTestCatalog(t, "", "")
+	defer cleanup()
+	config.Get().Catalog.Index = filepath.Join(dir, "index.json")
+
+	app := makeTarball(t, []testEntry{
+		{name: "nextcloud/edgebox-compose.yml", content: "services: {}\n"},
+		{name: "nextcloud/edgeapp.yml", content: "version: 2.0.0\ndependencies:\n  required: [mariadb]\n"},
+		{name: "nextcloud/config", typeflag: tar.TypeDir},
+		{name: "nextcloud/config/current", typeflag: tar.TypeSymlink, linkname: "../edgeapp.yml"},
+	})
+	dependency := makeTarball(t, []testEntry{{name: "edgebox-compose.yml", content: "services: {}\n"}})
+	ioutil.WriteFile(filepath.Join(dir, "nextcloud-2.0.0.tar.gz"), app, 0644)
+	ioutil.WriteFile(filepath.Join(dir, "mariadb.tar.gz"), dependency, 0644)
+
+	writeIndex(t, filepath.Join(dir, "index.json"), []App{
+		{ID: "nextcloud", Versions: []Version{
+			{Version: "2.0.0", URL: "nextcloud-2.0.0.tar.gz", SHA256: checksum(app)},
+			{Version: "1.0.0", URL: "missing.tar.gz", SHA256: checksum(app)},
+		}},
+		{ID: "mariadb", Versions: []Version{{Version: "10", URL: "mariadb.tar.gz", SHA256: checksum(dependency)}}},
+	})
+
+	placed, err := Install("nextcloud", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(placed, ",") != "nextcloud,mariadb" {
+		t.Log("Expected the newest version and its dependency to be placed, got", placed)
+		t.Fail()
+	}
+
+	for _, path := range []string{"nextcloud/edgebox-compose.yml", "nextcloud/config/current", "mariadb/edgebox-compose.yml"} {
+		if _, err := os.Stat(filepath.Join(dir, "apps", path)); err != nil {
+			t.Log("Expected", path, "to be unpacked:", err)
+			t.Fail()
+		}
+	}
+
+	files, _ := ioutil.ReadDir(filepath.Join(dir, "apps"))
+	if len(files) != 2 {
+		t.Log("Expected staging folders to be removed, got", len(files), "entries")
+		t.Fail()
+	}
+
+	if _, err := Install("nextcloud", ""); err == nil {
+		t.Log("Expected EdgeApps already available not to be replaced")
+		t.Fail()
+	}
+}
+
+func TestInstallChecksumMismatch(t *testing.T) {
+	app := makeTarball(t, []testEntry{{name: "edgebox-compose.yml", content: "services: {}\n"}})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/index.json" {
+			content, _ := json.Marshal(Index{Apps: []App{{ID: "app", Versions: []Version{{Version: "1", URL: "/app.tar.gz", SHA256: checksum([]byte("other"))}}}}})
+			w.Write(content)
+			return
+		}
+		w.Write(app)
+	}))
+	defer server.Close()
+
+	dir, cleanup := setupTestCatalog(t, server.URL+"/index.json", "")
+	defer cleanup()
+
+	_, err := Install("app", "")
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Log("Expected a checksum mismatch, got", err)
+		t.Fail()
+	}
+
+	files, _ := ioutil.ReadDir(filepath.Join(dir, "apps"))
+	if len(files) != 0 {
+		t.Log("Expected nothing to be left in the EdgeApps folder, got", len(files), "entries")
+		t.Fail()
+	}
+}
+
+func TestInstallSigned(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	app := makeTarball(t, []testEntry{{name: "edgebox-compose.yml", content: "services: {}\n"}})
+	digest := sha256.Sum256(app)
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, digest[:]))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/catalog/index.json":
+			content, _ := json.Marshal(Index{Apps: []App{
+				{ID: "signed", Versions: []Version{{Version: "1", URL: "app.tar.gz", SHA256: checksum(app), Signature: signature}}},
+				{ID: "unsigned", Versions: []Version{{Version: "1", URL: "app.tar.gz", SHA256: checksum(app)}}},
+				{ID: "forged", Versions: []Version{{Version: "1", URL: "app.tar.gz", SHA256: checksum(app), Signature: base64.StdEncoding.EncodeToString(make([]byte, 64))}}},
+			}})
+			w.Write(content)
+		case "/catalog/app.tar.gz":
+			w.Write(app)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	_, cleanup := setupTestCatalog(t, server.URL+"/catalog/index.json", base64.StdEncoding.EncodeToString(publicKey))
+	defer cleanup()
+
+	if _, err := Install("signed", ""); err != nil {
+		t.Log("Expected a signed tarball to be installed, got", err)
+		t.Fail()
+	}
+	for _, ID := range []string{"unsigned", "forged"} {
+		if _, err := Install(ID, ""); err == nil {
+			t.Log("Expected", ID, "to be refused")
+			t.Fail()
+		}
+	}
+}
+
+func TestUnpackRefusesEscapingEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "edgeboxctl-unpack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := map[string][]testEntry{
+		"parent path":      {{name: "../evil", content: "x"}},
+		"absolute symlink": {{name: "link", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"}},
+		"escaping symlink": {{name: "a/link", typeflag: tar.TypeSymlink, linkname: "../../etc"}},
+		"device":           {{name: "dev", typeflag: tar.TypeChar}},
+		"chained symlinks": {
+			{name: "a/b/p", typeflag: tar.TypeSymlink, linkname: "../.."},
+			{name: "a/b/p/q", typeflag: tar.TypeSymlink, linkname: "../.."},
+			{name: "a/b/p/q/evil", content: "x"},
+		},
+		"file over symlink":   {{name: "link", typeflag: tar.TypeSymlink, linkname: "file"}, {name: "link", content: "x"}},
+		"folder over symlink": {{name: "link", typeflag: tar.TypeSymlink, linkname: "sub"}, {name: "link", typeflag: tar.TypeDir}},
+	}
+
+	for name, entries := range cases {
+		out, err := ioutil.TempDir(dir, "out")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tarball := filepath.Join(dir, "test.tar.gz")
+		ioutil.WriteFile(tarball, makeTarball(t, entries), 0644)
+		if err := Unpack(tarball, out); err == nil {
+			t.Log("Expected an error for", name)
+			t.Fail()
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "evil")); !os.IsNotExist(err) {
+		t.Fatal("Expected no file to be written outside the destination, got", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	index := Index{Apps: []App{{ID: "app", Versions: []Version{{Version: "2"}, {Version: "1"}}}}}
+
+	if _, v, err := index.Find("app", ""); err != nil || v.Version != "2" {
+		t.Log("Expected the newest version, got", v, err)
+		t.Fail()
+	}
+	if _, v, err := index.Find("app", "1"); err != nil || v.Version != "1" {
+		t.Log("Expected the requested version, got", v, err)
+		t.Fail()
+	}
+	if _, _, err := index.Find("app", "3"); err == nil {
+		t.Log("Expected an error for a version not listed")
+		t.Fail()
+	}
+	if _, _, err := index.Find("other", ""); err == nil {
+		t.Log("Expected an error for an EdgeApp not listed")
+		t.Fail()
+	}
+}
@@ -0,0 +1,125 @@
+package catalog
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Unpack : Extracts a tar (optionally gzip compressed) tarball into dest. Entries are refused when they would end up outside dest,
+// and only folders, regular files and relative symlinks staying inside dest are accepted. Entries are never written through a symlink,
+// as a symlink pointing inside dest could be chained with another one to point outside of it.
+func Unpack(tarball string, dest string) error {
+
+	file, err := os.Open(tarball)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = bufio.NewReader(file)
+	magic, err := reader.(*bufio.Reader).Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	err = os.MkdirAll(dest, 0755)
+	if err != nil {
+		return err
+	}
+
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Clean(header.Name)
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("entry %s is outside the EdgeApp folder", header.Name)
+		}
+		target := filepath.Join(dest, name)
+		mode := os.FileMode(header.Mode).Perm()
+
+		err = checkNoSymlinks(dest, name)
+		if err != nil {
+			return fmt.Errorf("entry %s %s", header.Name, err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, mode|0700)
+		case tar.TypeReg, tar.TypeRegA:
+			err = writeEntry(archive, target, mode)
+		case tar.TypeSymlink:
+			linked := filepath.Clean(filepath.Join(filepath.Dir(name), header.Linkname))
+			if filepath.IsAbs(header.Linkname) || linked == ".." || strings.HasPrefix(linked, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("symlink %s points outside the EdgeApp folder", header.Name)
+			}
+			err = os.MkdirAll(filepath.Dir(target), 0755)
+			if err == nil {
+				err = os.Symlink(header.Linkname, target)
+			}
+		default:
+			return fmt.Errorf("entry %s has an unsupported type", header.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// checkNoSymlinks : Returns an error if name, or any folder on its way from dest, is a symlink already in dest (ex: unpacked before it)
+func checkNoSymlinks(dest string, name string) error {
+
+	path := dest
+	for _, part := range strings.Split(name, string(filepath.Separator)) {
+		path = filepath.Join(path, part)
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return errors.New("goes through a symlink")
+		}
+	}
+
+	return nil
+}
+
+func writeEntry(reader io.Reader, target string, mode os.FileMode) error {
+
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
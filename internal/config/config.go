@@ -0,0 +1,381 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/diagnostics"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultConfigFileLocation : Where edgeboxctl looks for its configuration file when none is given via the -config flag
+const DefaultConfigFileLocation string = "/etc/edgeboxctl/config.yml"
+
+// DefaultInstanceName : Name of the primary edgebox instance in a host
+const DefaultInstanceName string = "edgebox"
+
+// DefaultInstanceRoot : Root folder of the primary edgebox instance in a host
+const DefaultInstanceRoot string = "/home/system/"
+
+// primaryHome : Home folder of the user running the primary instance, where cloudflared keeps its login certificate
+const primaryHome string = "/root/"
+
+// Config : Struct representing the effective configuration of the edgeboxctl daemon
+type Config struct {
+	Instance            Instance   `yaml:"instance"`
+	Release             string     `yaml:"release"`
+	Database            string     `yaml:"database"`
+	DatabaseJournalMode string     `yaml:"database_journal_mode"` // Empty keeps the journal mode the api database was created with
+	Paths               Paths      `yaml:"paths"`
+	Services            Services   `yaml:"services"`
+	ControlApi          ControlApi `yaml:"control_api"`
+	Catalog             Catalog    `yaml:"catalog"`
+}
+
+// Instance : Struct representing the identity of this edgebox instance. Default paths are derived from Root, and names of shared external resources from Name.
+type Instance struct {
+	Name string `yaml:"name"`
+	Root string `yaml:"root"`
+}
+
+// Paths : Struct representing every filesystem location edgeboxctl works with
+type Paths struct {
+	Api                    string `yaml:"api"`
+	ApiEnvFile             string `yaml:"api_env_file"`
+	CloudEnvFile           string `yaml:"cloud_env_file"`
+	EdgeApps               string `yaml:"edgeapps"`
+	EdgeAppsBackup         string `yaml:"edgeapps_backup"`
+	EdgeAppExports         string `yaml:"edgeapp_exports"`
+	EdgeAppLogs            string `yaml:"edgeapp_logs"`
+	Ws                     string `yaml:"ws"`
+	BrowserDev             string `yaml:"browserdev"`
+	BrowserDevProxy        string `yaml:"browserdev_proxy"`
+	BrowserDevPasswordFile string `yaml:"browserdev_password_file"`
+	Logger                 string `yaml:"logger"`
+	BackupPasswordFile     string `yaml:"backup_password_file"`
+	Scripts                string `yaml:"scripts"`
+	Updater                string `yaml:"updater"`
+	Cloudflared            string `yaml:"cloudflared"`
+	CloudflaredRoot        string `yaml:"cloudflared_root"`
+	CloudflaredService     string `yaml:"cloudflared_service_config"`
+	SecretKeyFile          string `yaml:"secret_key_file"`
+}
+
+// ControlApi : Struct representing where the local control API listens. The unix socket is always enabled, TCP only when Listen is set (and requires Token).
+type ControlApi struct {
+	Socket string `yaml:"socket"`
+	Listen string `yaml:"listen"`
+	Token  string `yaml:"token"`
+}
+
+// Catalog : Struct representing where EdgeApps are installed from. Index is a local file or an http(s) URL, and when PublicKey (base64 ed25519) is set, every tarball must be signed with it.
+type Catalog struct {
+	Index     string `yaml:"index"`
+	PublicKey string `yaml:"public_key"`
+}
+
+// Services : Struct representing the names of the system services and external resources managed by edgeboxctl
+type Services struct {
+	Tunnel     string `yaml:"tunnel"`
+	TunnelName string `yaml:"tunnel_name"`
+	BrowserDev string `yaml:"browserdev"`
+}
+
+type settingKind int
+
+const (
+	valueSetting settingKind = iota
+	dirSetting
+	fileSetting
+)
+
+// setting : Describes a single configuration value, the environment variable that can override it and its default
+type setting struct {
+	name         string
+	env          string
+	kind         settingKind
+	defaultValue string
+	field        func(c *Config) *string
+}
+
+// Register settings here. Environment variable names are kept compatible with the ones previously read from the project root .env file.
+// Default values can use {root} (the instance root folder), {name} (the instance name), {suffix} (empty for the primary instance, "-<name>" otherwise)
+// and {home} (the home folder of root for the primary instance, the instance root otherwise), so instances never share host-wide tunnel files or services.
+var settings = []setting{
+	{"instance.name", "EDGEBOX_INSTANCE_NAME", valueSetting, DefaultInstanceName, func(c *Config) *string { return &c.Instance.Name }},
+	{"instance.root", "EDGEBOX_ROOT", dirSetting, DefaultInstanceRoot, func(c *Config) *string { return &c.Instance.Root }},
+	{"release", "EDGEBOX_RELEASE", valueSetting, "", func(c *Config) *string { return &c.Release }},
+	{"database", "SQLITE_DATABASE", valueSetting, "", func(c *Config) *string { return &c.Database }},
+	{"database_journal_mode", "SQLITE_JOURNAL_MODE", valueSetting, "", func(c *Config) *string { return &c.DatabaseJournalMode }},
+	{"paths.api", "API_PATH", dirSetting, "{root}components/api/", func(c *Config) *string { return &c.Paths.Api }},
+	{"paths.api_env_file", "API_ENV_FILE_LOCATION", fileSetting, "{root}components/api/edgebox.env", func(c *Config) *string { return &c.Paths.ApiEnvFile }},
+	{"paths.cloud_env_file", "CLOUD_ENV_FILE_LOCATION", fileSetting, "{root}components/api/cloud.env", func(c *Config) *string { return &c.Paths.CloudEnvFile }},
+	{"paths.edgeapps", "EDGEAPPS_PATH", dirSetting, "{root}components/apps/", func(c *Config) *string { return &c.Paths.EdgeApps }},
+	{"paths.edgeapps_backup", "EDGEAPPS_BACKUP_PATH", dirSetting, "{root}components/backups/", func(c *Config) *string { return &c.Paths.EdgeAppsBackup }},
+	{"paths.edgeapp_exports", "EDGEAPP_EXPORTS_PATH", dirSetting, "{root}components/exports/", func(c *Config) *string { return &c.Paths.EdgeAppExports }},
+	{"paths.edgeapp_logs", "EDGEAPP_LOGS_PATH", dirSetting, "{root}components/logs/", func(c *Config) *string { return &c.Paths.EdgeAppLogs }},
+	{"paths.ws", "WS_PATH", dirSetting, "{root}components/ws/", func(c *Config) *string { return &c.Paths.Ws }},
+	{"paths.browserdev", "BROWSERDEV_PATH", dirSetting, "{root}components/dev/", func(c *Config) *string { return &c.Paths.BrowserDev }},
+	{"paths.browserdev_proxy", "BROWSERDEV_PROXY_PATH", dirSetting, "{root}components/dev/", func(c *Config) *string { return &c.Paths.BrowserDevProxy }},
+	{"paths.browserdev_password_file", "BROWSERDEV_PASSWORD_FILE_LOCATION", fileSetting, "/root/.config/code-server/config.yaml", func(c *Config) *string { return &c.Paths.BrowserDevPasswordFile }},
+	{"paths.logger", "LOGGER_PATH", dirSetting, "{root}components/logger/", func(c *Config) *string { return &c.Paths.Logger }},
+	{"paths.backup_password_file", "BACKUP_PASSWORD_FILE_LOCATION", fileSetting, "{root}components/backups/pw.txt", func(c *Config) *string { return &c.Paths.BackupPasswordFile }},
+	{"paths.scripts", "SCRIPTS_PATH", dirSetting, "{root}components/edgeboxctl/scripts/", func(c *Config) *string { return &c.Paths.Scripts }},
+	{"paths.updater", "UPDATER_PATH", dirSetting, "{root}components/updater/", func(c *Config) *string { return &c.Paths.Updater }},
+	{"paths.cloudflared", "CLOUDFLARED_PATH", dirSetting, "{root}.cloudflared/", func(c *Config) *string { return &c.Paths.Cloudflared }},
+	{"paths.cloudflared_root", "CLOUDFLARED_ROOT_PATH", dirSetting, "{home}.cloudflared/", func(c *Config) *string { return &c.Paths.CloudflaredRoot }},
+	{"paths.cloudflared_service_config", "CLOUDFLARED_SERVICE_CONFIG_LOCATION", fileSetting, "/etc/cloudflared{suffix}/config.yml", func(c *Config) *string { return &c.Paths.CloudflaredService }},
+	{"paths.secret_key_file", "SECRET_KEY_FILE_LOCATION", fileSetting, "/etc/edgeboxctl/{name}.key", func(c *Config) *string { return &c.Paths.SecretKeyFile }},
+	{"services.tunnel", "TUNNEL_SERVICE", valueSetting, "cloudflared{suffix}", func(c *Config) *string { return &c.Services.Tunnel }},
+	{"services.tunnel_name", "TUNNEL_NAME", valueSetting, "edgebox{suffix}", func(c *Config) *string { return &c.Services.TunnelName }},
+	{"services.browserdev", "BROWSERDEV_SERVICE", valueSetting, "code-server@root", func(c *Config) *string { return &c.Services.BrowserDev }},
+	{"control_api.socket", "CONTROL_API_SOCKET", fileSetting, "/run/edgeboxctl/{name}.sock", func(c *Config) *string { return &c.ControlApi.Socket }},
+	{"control_api.listen", "CONTROL_API_LISTEN", valueSetting, "", func(c *Config) *string { return &c.ControlApi.Listen }},
+	{"control_api.token", "CONTROL_API_TOKEN", valueSetting, "", func(c *Config) *string { return &c.ControlApi.Token }},
+	{"catalog.index", "EDGEAPPS_CATALOG_INDEX", valueSetting, "", func(c *Config) *string { return &c.Catalog.Index }},
+	{"catalog.public_key", "EDGEAPPS_CATALOG_PUBLIC_KEY", valueSetting, "", func(c *Config) *string { return &c.Catalog.PublicKey }},
+}
+
+var current *Config
+
+var instanceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Default : Returns a Config filled with the built-in default values
+func Default() *Config {
+	c := &Config{}
+	applyDefaults(c)
+	return c
+}
+
+// Load : Reads the configuration file (if it exists), applies environment overrides and validates the result. Meant to be called once at startup.
+func Load(configFileLocation string) error {
+	return LoadInstance(configFileLocation, Instance{})
+}
+
+// LoadInstance : Same as Load, but any non empty field in instance (coming from command line flags) takes precedence over the file and environment
+func LoadInstance(configFileLocation string, instance Instance) error {
+
+	c := &Config{}
+
+	if configFileLocation != "" {
+		content, err := ioutil.ReadFile(configFileLocation)
+		if err == nil {
+			err = yaml.UnmarshalStrict(content, c)
+			if err != nil {
+				return fmt.Errorf("error parsing config file %s: %s", configFileLocation, err)
+			}
+		} else if os.IsNotExist(err) {
+			log.Printf("Config file %s not found. Using defaults.", configFileLocation)
+		} else {
+			return fmt.Errorf("error reading config file %s: %s", configFileLocation, err)
+		}
+	}
+
+	applyEnv(c)
+
+	if instance.Name != "" {
+		c.Instance.Name = instance.Name
+	}
+	if instance.Root != "" {
+		c.Instance.Root = instance.Root
+	}
+
+	applyDefaults(c)
+
+	err := c.Validate()
+	if err != nil {
+		return err
+	}
+
+	current = c
+
+	return nil
+}
+
+// Get : Returns the loaded configuration, or the defaults (with environment overrides) if Load was not called yet
+func Get() *Config {
+	if current == nil {
+		c := &Config{}
+		applyEnv(c)
+		applyDefaults(c)
+		current = c
+	}
+
+	return current
+}
+
+// IsPrimaryInstance : Returns true when this is the instance living in the default root, which keeps the historical resource names
+func (c *Config) IsPrimaryInstance() bool {
+	return filepath.Clean(c.Instance.Root) == filepath.Clean(DefaultInstanceRoot)
+}
+
+// Set : Replaces the effective configuration. Useful for tests and tools embedding edgeboxctl packages.
+func Set(c *Config) {
+	current = c
+}
+
+// Validate : Checks every configured value, returning a single error listing all problems found
+func (c *Config) Validate() error {
+
+	var problems []string
+
+	for _, s := range settings {
+		value := *s.field(c)
+
+		if s.kind == valueSetting {
+			continue
+		}
+
+		if value == "" {
+			problems = append(problems, s.name+" must not be empty")
+			continue
+		}
+
+		if !filepath.IsAbs(value) {
+			problems = append(problems, s.name+" must be an absolute path (got "+value+")")
+			continue
+		}
+
+		info, err := os.Stat(value)
+		if err != nil {
+			// Missing paths are allowed, they are created by the components that own them.
+			continue
+		}
+
+		if s.kind == dirSetting && !info.IsDir() {
+			problems = append(problems, s.name+" must be a directory (got file "+value+")")
+		}
+
+		if s.kind == fileSetting && info.IsDir() {
+			problems = append(problems, s.name+" must be a file (got directory "+value+")")
+		}
+	}
+
+	if !instanceNamePattern.MatchString(c.Instance.Name) {
+		problems = append(problems, "instance.name must only contain lowercase letters, numbers, - and _ (got "+c.Instance.Name+")")
+	}
+
+	// An instance in the default root would share the database, EdgeApps and tunnel of the primary one
+	if c.Instance.Name != DefaultInstanceName && c.IsPrimaryInstance() {
+		problems = append(problems, "instance.root must be set to another folder than "+DefaultInstanceRoot+" for instance "+c.Instance.Name)
+	}
+
+	if c.ControlApi.Listen != "" {
+		host, _, err := net.SplitHostPort(c.ControlApi.Listen)
+		if err != nil {
+			problems = append(problems, "control_api.listen must be a host:port address (got "+c.ControlApi.Listen+")")
+		} else if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			problems = append(problems, "control_api.listen must be a localhost address (got "+c.ControlApi.Listen+")")
+		}
+		if c.ControlApi.Token == "" {
+			problems = append(problems, "control_api.token is required when control_api.listen is set")
+		}
+	}
+
+	if c.Catalog.Index != "" && !filepath.IsAbs(c.Catalog.Index) && !strings.HasPrefix(c.Catalog.Index, "http://") && !strings.HasPrefix(c.Catalog.Index, "https://") {
+		problems = append(problems, "catalog.index must be an absolute path or an http(s) URL (got "+c.Catalog.Index+")")
+	}
+
+	if c.Catalog.PublicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(c.Catalog.PublicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			problems = append(problems, "catalog.public_key must be a base64 encoded ed25519 public key")
+		}
+	}
+
+	if c.Database != "" && !filepath.IsAbs(c.Database) {
+		problems = append(problems, "database must be an absolute path (got "+c.Database+")")
+	}
+
+	switch strings.ToUpper(c.DatabaseJournalMode) {
+	case "", "DELETE", "TRUNCATE", "PERSIST", "WAL":
+	default:
+		problems = append(problems, "database_journal_mode must be one of DELETE, TRUNCATE, PERSIST or WAL (got "+c.DatabaseJournalMode+")")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
+
+// String : Returns the configuration in the same YAML format used by the config file, with secrets masked
+func (c *Config) String() string {
+	masked := *c
+	if masked.ControlApi.Token != "" {
+		masked.ControlApi.Token = "********"
+	}
+	out, err := yaml.Marshal(masked)
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
+
+// applyEnv : Overrides values with the ones found in the project root .env file, and then with the process environment
+func applyEnv(c *Config) {
+
+	// Read whole of .env file to map, ignoring if it does not exist.
+	dotEnv, err := godotenv.Read()
+	if err != nil {
+		dotEnv = map[string]string{}
+	}
+
+	for _, s := range settings {
+		if value := dotEnv[s.env]; value != "" {
+			*s.field(c) = value
+		}
+		if value := os.Getenv(s.env); value != "" {
+			*s.field(c) = value
+		}
+	}
+}
+
+// applyDefaults : Fills every empty value with its default, expanding the instance placeholders, and normalizes directories
+func applyDefaults(c *Config) {
+
+	if c.Instance.Name == "" {
+		c.Instance.Name = DefaultInstanceName
+	}
+	if c.Instance.Root == "" {
+		c.Instance.Root = DefaultInstanceRoot
+	}
+	if !strings.HasSuffix(c.Instance.Root, "/") {
+		c.Instance.Root = c.Instance.Root + "/"
+	}
+	if c.Release == "" {
+		c.Release = diagnostics.Version
+	}
+
+	suffix := ""
+	home := primaryHome
+	if !c.IsPrimaryInstance() {
+		suffix = "-" + c.Instance.Name
+		home = c.Instance.Root
+	}
+
+	placeholders := strings.NewReplacer("{root}", c.Instance.Root, "{name}", c.Instance.Name, "{suffix}", suffix, "{home}", home)
+
+	for _, s := range settings {
+		value := s.field(c)
+		if *value == "" {
+			*value = placeholders.Replace(s.defaultValue)
+		}
+		// Directories always end with a slash, since paths are built by concatenation across the codebase
+		if s.kind == dirSetting && *value != "" && !strings.HasSuffix(*value, "/") {
+			*value = *value + "/"
+		}
+	}
+}
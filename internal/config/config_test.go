@@ -0,0 +1,140 @@
+// +build unit
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadDefaults(t *testing.T) {
+	err := Load("")
+	if err != nil {
+		t.Log("Expected defaults to be valid but got", err)
+		t.Fail()
+	}
+
+	if Get().Paths.Ws != "/home/system/components/ws/" {
+		t.Log("Expected /home/system/components/ws/ but got", Get().Paths.Ws)
+		t.Fail()
+	}
+}
+
+func TestLoadFileAndEnv(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "edgeboxctl-config")
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "config.yml")
+	ioutil.WriteFile(configFile, []byte("paths:\n  edgeapps: "+dir+"\n  ws: /srv/ws\n"), 0644)
+
+	os.Setenv("WS_PATH", "/opt/ws")
+	defer os.Unsetenv("WS_PATH")
+
+	err := Load(configFile)
+	if err != nil {
+		t.Log("Expected config to load but got", err)
+		t.FailNow()
+	}
+
+	if Get().Paths.EdgeApps != dir+"/" {
+		t.Log("Expected "+dir+"/ but got", Get().Paths.EdgeApps)
+		t.Fail()
+	}
+
+	if Get().Paths.Ws != "/opt/ws/" {
+		t.Log("Expected environment to take precedence, got", Get().Paths.Ws)
+		t.Fail()
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "edgeboxctl-config")
+	defer os.RemoveAll(dir)
+
+	c := Default()
+	c.Paths.Ws = "relative/ws/"
+	c.Paths.ApiEnvFile = dir
+
+	err := c.Validate()
+	if err == nil {
+		t.Log("Expected validation errors but got none")
+		t.FailNow()
+	}
+
+	if !strings.Contains(err.Error(), "paths.ws") || !strings.Contains(err.Error(), "paths.api_env_file") {
+		t.Log("Expected errors for paths.ws and paths.api_env_file, got", err)
+		t.Fail()
+	}
+}
+
+func TestLoadUnknownKey(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "edgeboxctl-config")
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "config.yml")
+	ioutil.WriteFile(configFile, []byte("pahts:\n  ws: /srv/ws\n"), 0644)
+
+	if Load(configFile) == nil {
+		t.Log("Expected an error for an unknown configuration key")
+		t.Fail()
+	}
+}
+
+func TestLoadInstance(t *testing.T) {
+	err := LoadInstance("", Instance{Name: "staging", Root: "/srv/edgebox/staging"})
+	if err != nil {
+		t.Log("Expected instance config to load but got", err)
+		t.FailNow()
+	}
+
+	if Get().Paths.EdgeApps != "/srv/edgebox/staging/components/apps/" {
+		t.Log("Expected paths to be derived from the instance root, got", Get().Paths.EdgeApps)
+		t.Fail()
+	}
+
+	if Get().Services.TunnelName != "edgebox-staging" {
+		t.Log("Expected tunnel name edgebox-staging but got", Get().Services.TunnelName)
+		t.Fail()
+	}
+
+	if LoadInstance("", Instance{Name: "Not Valid"}) == nil {
+		t.Log("Expected an error for an invalid instance name")
+		t.Fail()
+	}
+
+	if LoadInstance("", Instance{Name: "staging"}) == nil {
+		t.Log("Expected an error for an instance other than the primary one in the default root")
+		t.Fail()
+	}
+}
+
+func TestLoadInstanceTunnel(t *testing.T) {
+	err := LoadInstance("", Instance{})
+	if err != nil {
+		t.Fatal("Expected the primary instance config to load but got", err)
+	}
+	primary := *Get()
+
+	err = LoadInstance("", Instance{Name: "staging", Root: "/srv/edgebox/staging"})
+	if err != nil {
+		t.Fatal("Expected instance config to load but got", err)
+	}
+	staging := *Get()
+
+	if primary.Paths.CloudflaredRoot != "/root/.cloudflared/" || primary.Paths.CloudflaredService != "/etc/cloudflared/config.yml" || primary.Services.Tunnel != "cloudflared" {
+		t.Error("Expected the primary instance to keep the host cloudflared service and files, got", primary.Paths, primary.Services)
+	}
+
+	if staging.Paths.CloudflaredRoot != "/srv/edgebox/staging/.cloudflared/" {
+		t.Error("Expected the login certificate folder to be in the instance root, got", staging.Paths.CloudflaredRoot)
+	}
+	if staging.Paths.CloudflaredService != "/etc/cloudflared-staging/config.yml" {
+		t.Error("Expected the service config to be named after the instance, got", staging.Paths.CloudflaredService)
+	}
+	if staging.Services.Tunnel != "cloudflared-staging" {
+		t.Error("Expected the tunnel service to be named after the instance, got", staging.Services.Tunnel)
+	}
+}
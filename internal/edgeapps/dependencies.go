@@ -0,0 +1,216 @@
+package edgeapps
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+// dependencyGraph : Dependencies of every EdgeApp available in the system, by ID
+type dependencyGraph map[string]ManifestDependencies
+
+func getDependencyGraph() dependencyGraph {
+
+	graph := dependencyGraph{}
+
+	files, err := ioutil.ReadDir(utils.GetPath(utils.EdgeAppsPath))
+	if err != nil {
+		log.Println(err)
+		return graph
+	}
+
+	for _, f := range files {
+		if f.IsDir() && Exists(f.Name()) {
+			graph[f.Name()] = getManifestOrLegacy(f.Name()).Dependencies
+		}
+	}
+
+	return graph
+}
+
+// sortByDependencies : Orders IDs so every EdgeApp comes after its dependencies (required, or optional) among IDs, keeping the given order otherwise.
+// Returns an error naming the EdgeApps involved when dependencies are circular.
+func (graph dependencyGraph) sortByDependencies(IDs []string) ([]string, error) {
+
+	const visiting, visited = 1, 2
+
+	included := map[string]bool{}
+	for _, ID := range IDs {
+		included[ID] = true
+	}
+
+	sorted := []string{}
+	state := map[string]int{}
+
+	var visit func(ID string, path []string) error
+	visit = func(ID string, path []string) error {
+		switch state[ID] {
+		case visiting:
+			return fmt.Errorf("circular dependency between EdgeApps: %s", strings.Join(append(path, ID), " -> "))
+		case visited:
+			return nil
+		}
+
+		state[ID] = visiting
+		path = append(append([]string{}, path...), ID)
+		dependencies := graph[ID]
+		for _, dependency := range append(dependencies.Required, dependencies.Optional...) {
+			if !included[dependency] {
+				continue
+			}
+			if err := visit(dependency, path); err != nil {
+				return err
+			}
+		}
+		state[ID] = visited
+
+		sorted = append(sorted, ID)
+		return nil
+	}
+
+	for _, ID := range IDs {
+		if err := visit(ID, []string{}); err != nil {
+			return IDs, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// sortByDependenciesOrLog : Same as sortByDependencies, logging circular dependencies and keeping the given order for them
+func (graph dependencyGraph) sortByDependenciesOrLog(IDs []string) []string {
+	sorted, err := graph.sortByDependencies(IDs)
+	if err != nil {
+		log.Println(err)
+	}
+	return sorted
+}
+
+// requiredClosure : Returns IDs and the required dependencies of them for which include returns true, recursively
+func (graph dependencyGraph) requiredClosure(IDs []string, include func(ID string) bool) []string {
+
+	closure := append([]string{}, IDs...)
+	found := map[string]bool{}
+	for _, ID := range IDs {
+		found[ID] = true
+	}
+
+	for i := 0; i < len(closure); i++ {
+		for _, dependency := range graph[closure[i]].Required {
+			if !found[dependency] && include(dependency) {
+				found[dependency] = true
+				closure = append(closure, dependency)
+			}
+		}
+	}
+
+	return closure
+}
+
+// ResolveInstall : Returns the EdgeApps to install for installing IDs, adding the required dependencies not installed yet, dependencies first.
+// Fails when an EdgeApp or one of its required dependencies is not available, or when dependencies are circular.
+func ResolveInstall(IDs []string) ([]string, error) {
+
+	graph := getDependencyGraph()
+
+	for _, ID := range IDs {
+		if _, found := graph[ID]; !found {
+			return nil, fmt.Errorf("EdgeApp %s not found", ID)
+		}
+	}
+
+	var missing []string
+	toInstall := graph.requiredClosure(IDs, func(dependency string) bool {
+		if _, found := graph[dependency]; !found {
+			missing = append(missing, dependency)
+			return false
+		}
+		return !IsEdgeAppInstalled(dependency)
+	})
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("required EdgeApps not available: %s", strings.Join(missing, ", "))
+	}
+
+	return graph.sortByDependencies(toInstall)
+}
+
+// GetDependents : Returns the installed EdgeApps requiring ID, directly or through other EdgeApps, in the order to remove or stop them (dependents of dependents first)
+func GetDependents(ID string) []string {
+
+	graph := getDependencyGraph()
+
+	dependents := []string{}
+	found := map[string]bool{ID: true}
+	for i := -1; i < len(dependents); i++ {
+		required := ID
+		if i >= 0 {
+			required = dependents[i]
+		}
+		for candidate, dependencies := range graph {
+			if found[candidate] || !IsEdgeAppInstalled(candidate) {
+				continue
+			}
+			for _, dependency := range dependencies.Required {
+				if dependency == required {
+					found[candidate] = true
+					dependents = append(dependents, candidate)
+					break
+				}
+			}
+		}
+	}
+
+	sort.Strings(dependents)
+	sorted := graph.sortByDependenciesOrLog(dependents)
+	for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+		sorted[i], sorted[j] = sorted[j], sorted[i]
+	}
+
+	return sorted
+}
+
+// RunEdgeAppAndDependencies : Starts the installed required dependencies of an EdgeApp, then the EdgeApp, returning its most current status
+func RunEdgeAppAndDependencies(ID string) EdgeAppStatus {
+
+	graph := getDependencyGraph()
+	toStart := graph.requiredClosure([]string{ID}, IsEdgeAppInstalled)
+
+	for _, dependency := range graph.sortByDependenciesOrLog(toStart) {
+		if dependency != ID {
+			log.Printf("Starting %s, required by %s", dependency, ID)
+			RunEdgeApp(dependency)
+		}
+	}
+
+	return RunEdgeApp(ID)
+}
+
+// StopEdgeAppAndDependents : Stops the installed EdgeApps requiring an EdgeApp, then the EdgeApp, returning its most current status
+func StopEdgeAppAndDependents(ID string) EdgeAppStatus {
+
+	for _, dependent := range GetDependents(ID) {
+		log.Printf("Stopping %s, which requires %s", dependent, ID)
+		StopEdgeApp(dependent)
+	}
+
+	return StopEdgeApp(ID)
+}
+
+// sortedEdgeAppIDs : Returns the IDs of every EdgeApp in the system, dependencies first
+func sortedEdgeAppIDs() []string {
+
+	graph := getDependencyGraph()
+
+	IDs := make([]string, 0, len(graph))
+	for ID := range graph {
+		IDs = append(IDs, ID)
+	}
+	sort.Strings(IDs)
+
+	return graph.sortByDependenciesOrLog(IDs)
+}
@@ -0,0 +1,103 @@
+// +build unit
+
+package edgeapps
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeTestEdgeApp(t *testing.T, dir string, ID string, manifest string, installed bool) {
+	writeTestFile(t, filepath.Join(dir, ID, "edgebox-compose.yml"), "services: {}\n")
+	writeTestFile(t, filepath.Join(dir, ID, "edgeapp.yml"), manifest)
+	if installed {
+		writeTestFile(t, filepath.Join(dir, ID, ".run"), "")
+	}
+}
+
+func TestResolveInstall(t *testing.T) {
+	dir, cleanup := setupTestEdgeApps(t)
+	defer cleanup()
+
+	writeTestEdgeApp(t, dir, "nextcloud", "dependencies:\n  required: [mariadb, smtp]\n  optional: [redis]\n", false)
+	writeTestEdgeApp(t, dir, "mariadb", "", false)
+	writeTestEdgeApp(t, dir, "smtp", "dependencies:\n  required: [mariadb]\n", true)
+	writeTestEdgeApp(t, dir, "redis", "", false)
+
+	IDs, err := ResolveInstall([]string{"nextcloud"})
+	if err != nil || !reflect.DeepEqual(IDs, []string{"mariadb", "nextcloud"}) {
+		t.Log("Expected the required dependency not installed to come first, got", IDs, err)
+		t.Fail()
+	}
+
+	IDs, err = ResolveInstall([]string{"nextcloud", "redis"})
+	if err != nil || !reflect.DeepEqual(IDs, []string{"mariadb", "redis", "nextcloud"}) {
+		t.Log("Expected optional dependencies installed together to come first, got", IDs, err)
+		t.Fail()
+	}
+
+	writeTestEdgeApp(t, dir, "wordpress", "dependencies:\n  required: [mysql]\n", false)
+	if _, err := ResolveInstall([]string{"wordpress"}); err == nil || !strings.Contains(err.Error(), "mysql") {
+		t.Log("Expected an error naming the missing dependency, got", err)
+		t.Fail()
+	}
+
+	if _, err := ResolveInstall([]string{"missing"}); err == nil {
+		t.Log("Expected an error for an EdgeApp not available")
+		t.Fail()
+	}
+
+	writeTestEdgeApp(t, dir, "a", "dependencies:\n  required: [b]\n", false)
+	writeTestEdgeApp(t, dir, "b", "dependencies:\n  required: [a]\n", false)
+	if _, err := ResolveInstall([]string{"a"}); err == nil || !strings.Contains(err.Error(), "a -> b -> a") {
+		t.Log("Expected an error for circular dependencies, got", err)
+		t.Fail()
+	}
+}
+
+func TestGetDependents(t *testing.T) {
+	dir, cleanup := setupTestEdgeApps(t)
+	defer cleanup()
+
+	writeTestEdgeApp(t, dir, "mariadb", "", true)
+	writeTestEdgeApp(t, dir, "smtp", "dependencies:\n  required: [mariadb]\n", true)
+	writeTestEdgeApp(t, dir, "nextcloud", "dependencies:\n  required: [smtp]\n", true)
+	writeTestEdgeApp(t, dir, "wordpress", "dependencies:\n  required: [mariadb]\n", false)
+	writeTestEdgeApp(t, dir, "gitea", "dependencies:\n  optional: [mariadb]\n", true)
+
+	dependents := GetDependents("mariadb")
+	if !reflect.DeepEqual(dependents, []string{"nextcloud", "smtp"}) {
+		t.Log("Expected installed apps requiring mariadb, dependents of dependents first, got", dependents)
+		t.Fail()
+	}
+
+	if dependents := GetDependents("nextcloud"); len(dependents) != 0 {
+		t.Log("Expected no dependents, got", dependents)
+		t.Fail()
+	}
+
+	IDs := sortedEdgeAppIDs()
+	position := map[string]int{}
+	for i, ID := range IDs {
+		position[ID] = i
+	}
+	if position["mariadb"] > position["smtp"] || position["smtp"] > position["nextcloud"] || position["mariadb"] > position["gitea"] {
+		t.Log("Expected dependencies to come first, got", IDs)
+		t.Fail()
+	}
+}
+
+func TestParseManifestDependencies(t *testing.T) {
+	invalid := map[string]string{
+		"invalid ID": "dependencies:\n  required: [../etc]\n",
+		"duplicated": "dependencies:\n  required: [mariadb]\n  optional: [mariadb]\n",
+	}
+	for name, content := range invalid {
+		if _, err := ParseManifest("app", []byte(content)); err == nil {
+			t.Log("Expected an error for", name)
+			t.Fail()
+		}
+	}
+}
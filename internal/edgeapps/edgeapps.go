@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"sync"
 	"time"
 	
 	"github.com/joho/godotenv"
@@ -12,6 +13,8 @@ import (
 	"github.com/edgebox-iot/edgeboxctl/internal/system"
 	"github.com/edgebox-iot/edgeboxctl/internal/utils"
 	"github.com/edgebox-iot/edgeboxctl/internal/diagnostics"
+	"github.com/edgebox-iot/edgeboxctl/internal/events"
+	"github.com/edgebox-iot/edgeboxctl/internal/options"
 )
 
 // EdgeApp : Struct representing an EdgeApp in the system
@@ -19,6 +22,10 @@ type EdgeApp struct {
 	ID                 string           `json:"id"`
 	Name               string           `json:"name"`
 	Description        string           `json:"description"`
+	Version            string           `json:"version"`
+	InstalledVersion   string           `json:"installed_version"` // Version installed or last upgraded to, differs from version when the definition was replaced by hand
+	Icon               string           `json:"icon"`
+	Categories         []string         `json:"categories"`
 	Experimental	   bool             `json:"experimental"`
 	Status             EdgeAppStatus    `json:"status"`
 	Services           []EdgeAppService `json:"services"`
@@ -28,6 +35,10 @@ type EdgeApp struct {
 	Options			   []EdgeAppOption  `json:"options"`
 	NeedsConfig		   bool             `json:"needs_config"`
 	Login              EdgeAppLogin	 	`json:"login"`
+	Dependencies       ManifestDependencies `json:"dependencies"`
+	Health             []HealthCheckResult  `json:"health"`
+	Watchdog           WatchdogState        `json:"watchdog"`
+	Limits             ManifestLimits       `json:"limits"`
 }
 
 // MaybeEdgeApp : Boolean flag for validation of edgeapp existance
@@ -49,13 +60,16 @@ type EdgeAppService struct {
 }
 
 type EdgeAppOption struct {
-	Key             string `json:"key"`
-	Value           string `json:"value"`
-	DefaultValue    string `json:"default_value"`
-	Format          string `json:"format"`
-	Description     string `json:"description"`
-	IsSecret        bool   `json:"is_secret"`
-	IsInstallLocked bool   `json:"is_install_locked"`
+	Key             string   `json:"key"`
+	Value           string   `json:"value"`
+	DefaultValue    string   `json:"default_value"`
+	Format          string   `json:"format"`
+	Description     string   `json:"description"`
+	IsSecret        bool     `json:"is_secret"`
+	IsInstallLocked bool     `json:"is_install_locked"`
+	IsGenerated     bool     `json:"is_generated"` // An empty value is generated on install
+	Values          []string `json:"values,omitempty"`  // Allowed values, for enum options
+	Pattern         string   `json:"pattern,omitempty"` // Regular expression to match, for regex options
 }
 
 type EdgeAppLogin struct {
@@ -75,6 +89,10 @@ const postInstallFilename = "/edgebox-postinstall.done"
 const myEdgeAppServiceEnvFilename = "/myedgeapp.env"
 const defaultContainerOperationSleepTime time.Duration = time.Second * 10
 
+var lastKnownStatuses = map[string]EdgeAppStatus{}
+var lastKnownServices = map[string][]EdgeAppService{}
+var lastKnownStatusesMutex sync.Mutex
+
 // GetEdgeApp : Returns a EdgeApp struct with the current application information
 func GetEdgeApp(ID string) MaybeEdgeApp {
 
@@ -87,124 +105,39 @@ func GetEdgeApp(ID string) MaybeEdgeApp {
 	if !os.IsNotExist(err) {
 		// File exists. Start digging!
 
-		edgeAppName := ID
-		edgeAppDescription := ""
-		edgeAppExperimental := false
-		edgeAppOptions := []EdgeAppOption{}
-
-		edgeAppEnv, err := godotenv.Read(utils.GetPath(utils.EdgeAppsPath) + ID + envFilename)
-
-		if err != nil {
-			log.Println("Error loading .env file for edgeapp " + edgeAppName)
-		} else {
-			if edgeAppEnv["EDGEAPP_NAME"] != "" {
-				edgeAppName = edgeAppEnv["EDGEAPP_NAME"]
-			}
-			if edgeAppEnv["EDGEAPP_DESCRIPTION"] != "" {
-				edgeAppDescription = edgeAppEnv["EDGEAPP_DESCRIPTION"]
-			}
-			if edgeAppEnv["EDGEAPP_EXPERIMENTAL"] == "true" {
-				edgeAppExperimental = true
-			}
-		}
+		manifest := getManifestOrLegacy(ID)
 
 		needsConfig := false
-		hasFilledOptions := false
-		edgeAppOptionsTemplate, err := godotenv.Read(utils.GetPath(utils.EdgeAppsPath) + ID + optionsTemplateFilename)
-		if err != nil {
-			log.Println("Error loading options template file for edgeapp " + edgeAppName)
-		} else {
-			// Try to read the edgeAppOptionsEnv file
-			edgeAppOptionsEnv, err := godotenv.Read(utils.GetPath(utils.EdgeAppsPath) + ID + optionsEnvFilename)
-			if err != nil {
-				log.Println("Error loading options env file for edgeapp " + edgeAppName)
-			} else {
-				hasFilledOptions = true
-			}
+		edgeAppOptions := []EdgeAppOption{}
 
-			for key, value := range edgeAppOptionsTemplate {
-				
-				optionFilledValue := ""
-				if hasFilledOptions {
-					// Check if key exists in edgeAppOptionsEnv
-					optionFilledValue = edgeAppOptionsEnv[key]
-				}
-
-				format := ""
-				defaultValue := ""
-				description := ""
-				installLocked := false
-
-				// Parse value to separate by | and get the format, installLocked, description and default value
-				// Format is the first element
-				// InstallLocked is the second element
-				// Description is the third element
-				// Default value is the fourth element
-
-				valueSlices := strings.Split(value, "|")
-				if len(valueSlices) > 0 {
-					format = valueSlices[0]
-				}
-				if len(valueSlices) > 1 {
-					installLocked = valueSlices[1] == "true"
-				}
-				if len(valueSlices) > 2 {
-					description = valueSlices[2]
-				}
-				if len(valueSlices) > 3 {
-					defaultValue = valueSlices[3]
-				}
-
-				// // If value contains ">|", then get everything that is to the right of it as the description
-				// // and get everything between "<>" as the format
-				// if strings.Contains(value, ">|") {
-				// 	description = strings.Split(value, ">|")[1]
-				// 	// Check if description has default value. That would be everything that is to the right of the last "|"
-				// 	if strings.Contains(description, "|") {
-				// 		defaultValue = strings.Split(description, "|")[1]
-				// 		description = strings.Split(description, "|")[0]
-				// 	}
-
-				// 	value = strings.Split(value, ">|")[0]
-				// 	// Remove the initial < from value
-				// 	value = strings.TrimPrefix(value, "<")
-				// } else {
-				// 	// Trim initial < and final > from value
-				// 	value = strings.TrimPrefix(value, "<")
-				// 	value = strings.TrimSuffix(value, ">")
-				// }
-
-				isSecret := false
-
-				// Check if the lowercased key string contains the letters "pass", "secret", "key"
-				lowercaseKey := strings.ToLower(key)
-				// check if lowercaseInput contains "pass", "key", or "secret", or "token"
-				if strings.Contains(lowercaseKey, "pass") ||
-					strings.Contains(lowercaseKey, "key") ||
-					strings.Contains(lowercaseKey, "secret") ||
-					strings.Contains(lowercaseKey, "token") {
-					isSecret = true
-				}
-
-				currentOption := EdgeAppOption{
-					Key:             key,
-					Value:           optionFilledValue,
-					DefaultValue:    defaultValue,
-					Description:     description,
-					Format:          format,
-					IsSecret:        isSecret,
-					IsInstallLocked: installLocked,
-				}
-				edgeAppOptions = append(edgeAppOptions, currentOption)
-
-				if optionFilledValue == "" {
-					needsConfig = true
-				}
+		// Filled values are in the edgeapp.env file, which does not exist until options are set
+		edgeAppOptionsEnv, err := godotenv.Read(utils.GetPath(utils.EdgeAppsPath) + ID + optionsEnvFilename)
+		if err != nil && len(manifest.Options) > 0 {
+			log.Println("Error loading options env file for edgeapp " + manifest.Name)
+		}
 
+		for _, option := range manifest.Options {
+
+			optionFilledValue := edgeAppOptionsEnv[option.Key]
+
+			edgeAppOptions = append(edgeAppOptions, EdgeAppOption{
+				Key:             option.Key,
+				Value:           optionFilledValue,
+				DefaultValue:    option.Default,
+				Description:     option.Description,
+				Format:          option.Type,
+				IsSecret:        option.IsSecret(),
+				IsInstallLocked: option.InstallLocked,
+				IsGenerated:     option.Generate != nil,
+				Values:          option.Values,
+				Pattern:         option.Pattern,
+			})
+
+			if optionFilledValue == "" && option.Generate == nil {
+				needsConfig = true
 			}
 		}
 
-
 		edgeAppInternetAccessible := false
 		edgeAppInternetURL := ""
 
@@ -236,9 +169,13 @@ func GetEdgeApp(ID string) MaybeEdgeApp {
 		result = MaybeEdgeApp{
 			EdgeApp: EdgeApp{
 				ID:                 ID,
-				Name:               edgeAppName,
-				Description:        edgeAppDescription,
-				Experimental:       edgeAppExperimental,
+				Name:               manifest.Name,
+				Description:        manifest.Description,
+				Version:            manifest.Version,
+				InstalledVersion:   GetInstalledVersion(ID),
+				Icon:               manifest.Icon,
+				Categories:         manifest.Categories,
+				Experimental:       manifest.Experimental,
 				Status:             GetEdgeAppStatus(ID),
 				Services:           GetEdgeAppServices(ID),
 				InternetAccessible: edgeAppInternetAccessible,
@@ -247,6 +184,10 @@ func GetEdgeApp(ID string) MaybeEdgeApp {
 				Options: 		    edgeAppOptions,
 				NeedsConfig:        needsConfig,
 				Login:				EdgeAppLogin{edgeAppBasicAuthEnabled, edgeAppBasicAuthUsername, edgeAppBasicAuthPassword},
+				Dependencies:       manifest.Dependencies,
+				Health:             GetEdgeAppHealth(ID),
+				Watchdog:           GetWatchdogState(ID),
+				Limits:             GetEdgeAppLimits(ID),
 				
 			},
 			Valid: true,
@@ -280,30 +221,17 @@ func writeAppRunnableFiles(ID string) bool {
 			log.Fatal("Runnable file for EdgeApp could not be created!")
 			return false
 		}
+		recordInstalledVersion(ID)
 
 		// Check the block default apps option
-        blockDefaultAppsOption := utils.ReadOption("DASHBOARD_BLOCK_DEFAULT_APPS_PUBLIC_ACCESS")
-        if blockDefaultAppsOption != "yes" {
+        blockDefaultApps, _ := options.GetBool("DASHBOARD_BLOCK_DEFAULT_APPS_PUBLIC_ACCESS")
+        if !blockDefaultApps {
             // Create myedgeapp.env file with default network URL
             envFilePath := edgeAppPath + ID + myEdgeAppServiceEnvFilename
-            
-			var networkURL string
-			domainName := utils.ReadOption("DOMAIN_NAME")
-
-			if domainName != "" {
-				networkURL = ID + "." + domainName
-			} else if diagnostics.GetReleaseVersion() == diagnostics.CLOUD_VERSION {
-				cluster := utils.ReadOption("CLUSTER") 
-				username := utils.ReadOption("USERNAME")
-				if cluster != "" && username != "" {
-					networkURL = username + "-" + ID + "." + cluster
-				}
-			} else {
-				networkURL = ID + "." + system.GetHostname() + ".local" // default 
-			}
+			domainName, _ := options.GetString("DOMAIN_NAME")
+			networkURL := defaultInternetURL(ID, domainName)
 			
-            env, _ := godotenv.Unmarshal("INTERNET_URL=" + networkURL)
-            err = godotenv.Write(env, envFilePath)
+            err = utils.MergeEnvFile(envFilePath, map[string]string{"INTERNET_URL": networkURL}, 0644)
             if err != nil {
                 log.Printf("Error creating myedgeapp.env file: %s", err)
                 // result = false
@@ -313,13 +241,84 @@ func writeAppRunnableFiles(ID string) bool {
 	return true
 }
 
+// defaultInternetURL : Returns the URL an EdgeApp gets by default when installed, under the given domain name (if any)
+func defaultInternetURL(ID string, domainName string) string {
+
+	if domainName != "" {
+		return ID + "." + domainName
+	}
+
+	if diagnostics.GetReleaseVersion() == diagnostics.CLOUD_VERSION {
+		cluster, _ := options.GetString("CLUSTER")
+		username, _ := options.GetString("USERNAME")
+		if cluster != "" && username != "" {
+			return username + "-" + ID + "." + cluster
+		}
+		return ""
+	}
+
+	return ID + "." + system.GetHostname() + ".local" // default
+}
+
+// UpdateDefaultInternetURLs : Moves the installed EdgeApps still on their default URL under oldDomainName to the default URL under newDomainName.
+// EdgeApps with a custom URL, or without one, are left untouched. Returns the IDs of the updated EdgeApps.
+func UpdateDefaultInternetURLs(oldDomainName string, newDomainName string) []string {
+
+	var updated []string
+
+	edgeAppsPath := utils.GetPath(utils.EdgeAppsPath)
+	files, err := ioutil.ReadDir(edgeAppsPath)
+	if err != nil {
+		log.Printf("Error listing EdgeApps: %s", err)
+		return updated
+	}
+
+	for _, f := range files {
+		ID := f.Name()
+		if !f.IsDir() || !IsEdgeAppInstalled(ID) {
+			continue
+		}
+
+		envFilePath := edgeAppsPath + ID + myEdgeAppServiceEnvFilename
+		env, err := godotenv.Read(envFilePath)
+		if err != nil || env["INTERNET_URL"] == "" || env["INTERNET_URL"] != defaultInternetURL(ID, oldDomainName) {
+			continue
+		}
+
+		newURL := defaultInternetURL(ID, newDomainName)
+		if newURL == "" || newURL == env["INTERNET_URL"] {
+			continue
+		}
+
+		log.Printf("Moving EdgeApp %s from %s to %s", ID, env["INTERNET_URL"], newURL)
+		err = utils.MergeEnvFile(envFilePath, map[string]string{"INTERNET_URL": newURL}, 0644)
+		if err != nil {
+			log.Printf("Error writing myedgeapp.env file of %s: %s", ID, err)
+			continue
+		}
+		updated = append(updated, ID)
+	}
+
+	if len(updated) > 0 {
+		buildFrameworkContainers()
+	}
+
+	return updated
+}
+
 func SetEdgeAppInstalled(ID string) bool {
 
 	result := true
 
+	err := fillGeneratedOptions(ID)
+	if err != nil {
+		log.Println(err)
+	}
+
 	if writeAppRunnableFiles(ID) {
 		
 		buildFrameworkContainers()
+		runHook(ID, HOOK_POST_INSTALL)
 
 	} else {
 
@@ -337,11 +336,19 @@ func SetEdgeAppBulkInstalled(IDs []string) bool {
 	result := true
 
 	for _, ID := range IDs {
+		err := fillGeneratedOptions(ID)
+		if err != nil {
+			log.Println(err)
+		}
 		writeAppRunnableFiles(ID)
 	}
 
 	buildFrameworkContainers()
 
+	for _, ID := range IDs {
+		runHook(ID, HOOK_POST_INSTALL)
+	}
+
 	return result
 
 }
@@ -349,6 +356,8 @@ func SetEdgeAppBulkInstalled(IDs []string) bool {
 
 func SetEdgeAppNotInstalled(ID string) bool {
 
+	runHook(ID, HOOK_PRE_REMOVE)
+
 	// Stop the app first
 	StopEdgeApp(ID)
 
@@ -391,6 +400,24 @@ func SetEdgeAppNotInstalled(ID string) bool {
 		log.Println(err)
 	}
 
+	// Not written for EdgeApps without a version
+	err = os.Remove(utils.GetPath(utils.EdgeAppsPath) + ID + versionFilename)
+	if err != nil && !os.IsNotExist(err) {
+		result = false
+		log.Println(err)
+	}
+
+	// Only written for EdgeApps with limits
+	err = os.Remove(utils.GetPath(utils.EdgeAppsPath) + ID + limitsEnvFilename)
+	if err == nil || os.IsNotExist(err) {
+		err = restoreComposeFile(ID)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		result = false
+		log.Println(err)
+	}
+
+	resetWatchdog(ID, true)
 	buildFrameworkContainers()
 
 	return result
@@ -435,10 +462,13 @@ func GetEdgeAppStatus(ID string) EdgeAppStatus {
 	// - All services running = EdgeApp running
 	// - Some services running = Problem detected, needs restart
 	// - No service running = EdgeApp is off
+	// - All services running, some health checks failing = EdgeApp degraded
+	// - All services running, every health check failing = EdgeApp unhealthy
 
 	runningServices := 0
 
 	status := EdgeAppStatus{0, "off"}
+	var services []EdgeAppService
 
 	if !IsEdgeAppInstalled(ID) {
 
@@ -446,7 +476,7 @@ func GetEdgeAppStatus(ID string) EdgeAppStatus {
 
 	} else {
 
-		services := GetEdgeAppServices(ID)
+		services = GetEdgeAppServices(ID)
 		for _, edgeAppService := range services {
 			if edgeAppService.IsRunning {
 				runningServices++
@@ -458,15 +488,60 @@ func GetEdgeAppStatus(ID string) EdgeAppStatus {
 		}
 
 		if runningServices == len(services) {
-			status = EdgeAppStatus{1, "on"}
+			status = applyHealth(EdgeAppStatus{1, "on"}, GetEdgeAppHealth(ID))
 		}
 
 	}
 
+	recordEdgeAppStatus(ID, status, services)
+
 	return status
 
 }
 
+// recordEdgeAppStatus : Remembers the latest status and services of an EdgeApp, publishing an event when the status changed since the previous check
+func recordEdgeAppStatus(ID string, status EdgeAppStatus, services []EdgeAppService) {
+
+	lastKnownStatusesMutex.Lock()
+	previousStatus, known := lastKnownStatuses[ID]
+	lastKnownStatuses[ID] = status
+	lastKnownServices[ID] = services
+	lastKnownStatusesMutex.Unlock()
+
+	if known && previousStatus != status {
+		log.Printf("EdgeApp %s status changed from %s to %s", ID, previousStatus.Description, status.Description)
+		events.Publish(events.APP_STATUS_CHANGED, map[string]interface{}{
+			"id":       ID,
+			"previous": previousStatus,
+			"status":   status,
+		})
+	}
+}
+
+// GetLastKnownStatuses : Returns the status of every EdgeApp as of its last check, without querying docker
+func GetLastKnownStatuses() map[string]EdgeAppStatus {
+	lastKnownStatusesMutex.Lock()
+	defer lastKnownStatusesMutex.Unlock()
+
+	statuses := make(map[string]EdgeAppStatus, len(lastKnownStatuses))
+	for ID, status := range lastKnownStatuses {
+		statuses[ID] = status
+	}
+	return statuses
+}
+
+// GetLastKnownServices : Returns the services of every EdgeApp as of its last check, without querying docker
+func GetLastKnownServices() map[string][]EdgeAppService {
+	lastKnownStatusesMutex.Lock()
+	defer lastKnownStatusesMutex.Unlock()
+
+	services := make(map[string][]EdgeAppService, len(lastKnownServices))
+	for ID, appServices := range lastKnownServices {
+		services[ID] = append([]EdgeAppService(nil), appServices...)
+	}
+	return services
+}
+
 // GetEdgeAppServices : Returns a
 func GetEdgeAppServices(ID string) []EdgeAppService {
 	wsPath := utils.GetPath(utils.WsPath)
@@ -476,26 +551,19 @@ func GetEdgeAppServices(ID string) []EdgeAppService {
 	serviceSlices = utils.DeleteEmptySlices(serviceSlices)
 	var edgeAppServices []EdgeAppService
 
-	for _, serviceID := range serviceSlices {
-		shouldBeRunning := false
-		isRunning := false
-
-		// Is service "runnable" when .run lockfile in the app folder
-		_, err := os.Stat(utils.GetPath(utils.EdgeAppsPath) + ID + runnableFilename)
-		if !os.IsNotExist(err) {
-			shouldBeRunning = true
-		}
-
-		// Check if the service is actually running
-		if shouldBeRunning {
-			cmdArgs = []string{"-f", wsPath + "/docker-compose.yml", "exec", "-T", serviceID, "echo", "'Service Check'"}
-			cmdResult := utils.Exec(wsPath, "docker", append([]string{"compose"}, cmdArgs...))
-			if cmdResult != "" {
-				isRunning = true
-			}
+	// Is service "runnable" when .run lockfile in the app folder
+	runningServices := map[string]bool{}
+	_, err := os.Stat(utils.GetPath(utils.EdgeAppsPath) + ID + runnableFilename)
+	if !os.IsNotExist(err) && len(serviceSlices) > 0 {
+		// Check which services are actually running, without running anything inside their containers
+		cmdArgs = append([]string{"-f", wsPath + "/docker-compose.yml", "ps", "--services", "--status", "running"}, serviceSlices...)
+		for _, serviceID := range strings.Split(utils.Exec(wsPath, "docker", append([]string{"compose"}, cmdArgs...)), "\n") {
+			runningServices[strings.TrimSpace(serviceID)] = true
 		}
+	}
 
-		edgeAppServices = append(edgeAppServices, EdgeAppService{ID: serviceID, IsRunning: isRunning})
+	for _, serviceID := range serviceSlices {
+		edgeAppServices = append(edgeAppServices, EdgeAppService{ID: serviceID, IsRunning: runningServices[serviceID]})
 	}
 
 	return edgeAppServices
@@ -508,6 +576,9 @@ func RunEdgeApp(ID string) EdgeAppStatus {
 	services := GetEdgeAppServices(ID)
 	cmdArgs := []string{}
 
+	runHook(ID, HOOK_PRE_START)
+	resetWatchdog(ID, false)
+
 	for _, service := range services {
 
 		cmdArgs = []string{"-f", wsPath + "/docker-compose.yml", "start", service.ID}
@@ -532,6 +603,9 @@ func StopEdgeApp(ID string) EdgeAppStatus {
 		utils.Exec(wsPath, "docker", append([]string{"compose"}, cmdArgs...))
 	}
 
+	runHook(ID, HOOK_POST_STOP)
+	clearEdgeAppHealth(ID)
+
 	// Wait for it to settle up before continuing...
 	time.Sleep(defaultContainerOperationSleepTime)
 
@@ -539,12 +613,12 @@ func StopEdgeApp(ID string) EdgeAppStatus {
 
 }
 
-// StopAllEdgeApps: Stops all EdgeApps and returns a count of how many were stopped
+// StopAllEdgeApps: Stops all EdgeApps (dependents before their dependencies) and returns a count of how many were stopped
 func StopAllEdgeApps() int {
-	edgeApps := GetEdgeApps()
+	IDs := sortedEdgeAppIDs()
 	appCount := 0
-	for _, edgeApp := range edgeApps {
-		StopEdgeApp(edgeApp.ID)
+	for i := len(IDs) - 1; i >= 0; i-- {
+		StopEdgeApp(IDs[i])
 		appCount++
 	}
 
@@ -552,12 +626,11 @@ func StopAllEdgeApps() int {
 
 }
 
-// StartAllEdgeApps: Starts all EdgeApps and returns a count of how many were started
-func StartAllEdgeApps() int {	
-	edgeApps := GetEdgeApps()
+// StartAllEdgeApps: Starts all EdgeApps (dependencies before their dependents) and returns a count of how many were started
+func StartAllEdgeApps() int {
 	appCount := 0
-	for _, edgeApp := range edgeApps {
-		RunEdgeApp(edgeApp.ID)
+	for _, ID := range sortedEdgeAppIDs() {
+		RunEdgeApp(ID)
 		appCount++
 	}
 
@@ -576,8 +649,10 @@ func EnableOnline(ID string, InternetURL string) MaybeEdgeApp {
 	if maybeEdgeApp.Valid { // We're only going to do this operation if the EdgeApp actually exists.
 		// Create the myedgeapp.env file and add the InternetURL entry to it
 		envFilePath := utils.GetPath(utils.EdgeAppsPath) + ID + myEdgeAppServiceEnvFilename
-		env, _ := godotenv.Unmarshal("INTERNET_URL=" + InternetURL)
-		_ = godotenv.Write(env, envFilePath)
+		err := utils.MergeEnvFile(envFilePath, map[string]string{"INTERNET_URL": InternetURL}, 0644)
+		if err != nil {
+			log.Printf("Error writing myedgeapp.env file of %s: %s", ID, err)
+		}
 	}
 
 	buildFrameworkContainers()
@@ -607,8 +682,10 @@ func DisableOnline(ID string) MaybeEdgeApp {
 func EnablePublicDashboard(InternetURL string) bool {
 
 	envFilePath := utils.GetPath(utils.ApiPath) + myEdgeAppServiceEnvFilename
-	env, _ := godotenv.Unmarshal("INTERNET_URL=" + InternetURL)
-	_ = godotenv.Write(env, envFilePath)
+	err := utils.MergeEnvFile(envFilePath, map[string]string{"INTERNET_URL": InternetURL}, 0644)
+	if err != nil {
+		log.Printf("Error writing myedgeapp.env file of the dashboard: %s", err)
+	}
 
 	buildFrameworkContainers()
 
@@ -638,6 +715,8 @@ func IsPublicDashboard() bool {
 func buildFrameworkContainers() {
 
 	wsPath := utils.GetPath(utils.WsPath)
+	applyAllLimits()
+
 	cmdArgs := []string{wsPath + "ws", "--build"}
 	utils.ExecAndStream(wsPath, "sh", cmdArgs)
 
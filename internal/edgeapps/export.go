@@ -0,0 +1,188 @@
+package edgeapps
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+const exportExtension string = ".tar.zst"
+
+// ExportEdgeApp : Archives the folder of an EdgeApp (definition, options, login, online access and appdata) as a tar.zst file, with its containers stopped
+// while doing so. The archive is written to dest, or to the exports folder when empty. Returns the path of the archive.
+func ExportEdgeApp(ID string, dest string) (string, error) {
+
+	if !Exists(ID) {
+		return "", fmt.Errorf("EdgeApp %s not found", ID)
+	}
+
+	if dest == "" {
+		name := ID
+		if version := GetInstalledVersion(ID); version != "" {
+			name += "-" + version
+		}
+		dest = utils.GetPath(utils.EdgeAppExportsPath) + name + "-" + time.Now().Format("20060102-150405") + exportExtension
+	}
+
+	err := os.MkdirAll(filepath.Dir(dest), 0700)
+	if err != nil {
+		return "", err
+	}
+
+	if IsEdgeAppInstalled(ID) {
+		wasRunning := GetEdgeAppStatus(ID).Description == "on"
+		log.Printf("Stopping %s to export it", ID)
+		StopEdgeApp(ID)
+		if wasRunning {
+			defer RunEdgeApp(ID)
+		}
+	}
+
+	// Options and logins are in the archive, so it is only readable by root
+	temporary := dest + ".tmp"
+	err = runTar("--zstd", "-cf", temporary, "-C", utils.GetPath(utils.EdgeAppsPath), ID)
+	if err == nil {
+		err = os.Chmod(temporary, 0600)
+	}
+	if err == nil {
+		err = os.Rename(temporary, dest)
+	}
+	if err != nil {
+		os.Remove(temporary)
+		return "", fmt.Errorf("error exporting %s: %s", ID, err)
+	}
+
+	log.Printf("Exported %s to %s", ID, dest)
+	return dest, nil
+}
+
+// ImportEdgeApp : Unpacks an archive made by ExportEdgeApp into the EdgeApps folder, replacing the EdgeApp with the same ID only when replace is true.
+// EdgeApps exported while installed are installed again, with the imported data. Returns the ID of the imported EdgeApp.
+func ImportEdgeApp(archive string, replace bool) (string, error) {
+
+	ID, err := readArchiveID(archive)
+	if err != nil {
+		return "", fmt.Errorf("invalid EdgeApp archive %s: %s", archive, err)
+	}
+
+	wasInstalled := IsEdgeAppInstalled(ID)
+	if Exists(ID) && !replace {
+		return ID, fmt.Errorf("EdgeApp %s already exists, import it with replace to overwrite it", ID)
+	}
+
+	staging, err := ioutil.TempDir(utils.GetPath(utils.EdgeAppsPath), "."+ID+".import")
+	if err != nil {
+		return ID, err
+	}
+	defer os.RemoveAll(staging)
+
+	err = runTar("--zstd", "-xf", archive, "-C", staging)
+	if err != nil {
+		return ID, fmt.Errorf("error unpacking %s: %s", archive, err)
+	}
+	if _, err := os.Stat(filepath.Join(staging, ID) + configFilename); err != nil {
+		return ID, fmt.Errorf("invalid EdgeApp archive %s: no %s", archive, strings.TrimPrefix(configFilename, "/"))
+	}
+
+	appPath := utils.GetPath(utils.EdgeAppsPath) + ID
+	replaced := ""
+	if Exists(ID) {
+		if wasInstalled {
+			StopEdgeApp(ID)
+		}
+		replaced = utils.GetPath(utils.EdgeAppsPath) + "." + ID + ".replaced"
+		err = os.RemoveAll(replaced)
+		if err == nil {
+			err = os.Rename(appPath, replaced)
+		}
+		if err != nil {
+			restoreReplacedEdgeApp(ID, "", wasInstalled)
+			return ID, fmt.Errorf("error replacing %s: %s", ID, err)
+		}
+	}
+
+	err = os.Rename(filepath.Join(staging, ID), appPath)
+	if err != nil {
+		restoreReplacedEdgeApp(ID, replaced, wasInstalled)
+		return ID, fmt.Errorf("error replacing %s: %s", ID, err)
+	}
+
+	// The replaced EdgeApp is only removed once the imported one is in place
+	if replaced != "" {
+		os.RemoveAll(replaced)
+	}
+
+	if wasInstalled || IsEdgeAppInstalled(ID) {
+		buildFrameworkContainers()
+	}
+
+	log.Printf("Imported %s from %s", ID, archive)
+	return ID, nil
+}
+
+// restoreReplacedEdgeApp : Puts back an EdgeApp moved aside by a failed import (when replaced is not empty), running it again when it was installed
+func restoreReplacedEdgeApp(ID string, replaced string, wasInstalled bool) {
+	if replaced != "" {
+		err := os.Rename(replaced, utils.GetPath(utils.EdgeAppsPath)+ID)
+		if err != nil {
+			log.Printf("Error restoring %s after a failed import, it was kept in %s: %s", ID, replaced, err)
+			return
+		}
+	}
+	if wasInstalled {
+		RunEdgeApp(ID)
+	}
+}
+
+// readArchiveID : Returns the ID of the EdgeApp in an archive, checking every entry is inside its folder
+func readArchiveID(archive string) (string, error) {
+
+	var output bytes.Buffer
+	cmd := exec.Command("tar", "--zstd", "-tf", archive)
+	cmd.Stdout = &output
+	err := cmd.Run()
+	if err != nil {
+		return "", err
+	}
+
+	ID := ""
+	scanner := bufio.NewScanner(&output)
+	for scanner.Scan() {
+		entry := scanner.Text()
+		cleaned := path.Clean(entry)
+		if path.IsAbs(entry) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+			return "", fmt.Errorf("entry %s is outside the EdgeApp folder", entry)
+		}
+
+		folder := strings.SplitN(cleaned, "/", 2)[0]
+		if ID == "" {
+			ID = folder
+		} else if folder != ID {
+			return "", fmt.Errorf("archive has more than one EdgeApp (%s and %s)", ID, folder)
+		}
+	}
+
+	if !edgeAppIDPattern.MatchString(ID) {
+		return "", fmt.Errorf("%s is not a valid EdgeApp ID", ID)
+	}
+
+	return ID, nil
+}
+
+func runTar(args ...string) error {
+	output, err := exec.Command("tar", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// +build unit
+
+package edgeapps
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/config"
+)
+
+func TestExportImportEdgeApp(t *testing.T) {
+	if exec.Command("tar", "--zstd", "--version").Run() != nil {
+		t.Skip("tar with zstd support is not available")
+	}
+
+	dir, cleanup := setupTestEdgeApps(t)
+	defer cleanup()
+	config.Get().Paths.EdgeAppExports = filepath.Join(dir, ".exports") + "/"
+
+	writeTestFile(t, filepath.Join(dir, "app", "edgebox-compose.yml"), "services: {}\n")
+	writeTestFile(t, filepath.Join(dir, "app", "edgeapp.env"), "ADMIN_USER=admin\n")
+	writeTestFile(t, filepath.Join(dir, "app", "auth.env"), "USERNAME=\"admin\"\n")
+	writeTestFile(t, filepath.Join(dir, "app", "appdata", "data"), "rows")
+
+	archive, err := ExportEdgeApp("app", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(archive, filepath.Join(dir, ".exports", "app-")) || !strings.HasSuffix(archive, ".tar.zst") {
+		t.Log("Expected the archive in the exports folder, got", archive)
+		t.Fail()
+	}
+	if info, _ := os.Stat(archive); info == nil || info.Mode().Perm() != 0600 {
+		t.Log("Expected the archive to be readable by its owner only")
+		t.Fail()
+	}
+
+	if _, err := ImportEdgeApp(archive, false); err == nil {
+		t.Log("Expected an existing EdgeApp not to be replaced without confirmation")
+		t.Fail()
+	}
+
+	writeTestFile(t, filepath.Join(dir, "app", "appdata", "data"), "changed")
+	writeTestFile(t, filepath.Join(dir, "app", "appdata", "new"), "")
+
+	ID, err := ImportEdgeApp(archive, true)
+	if err != nil || ID != "app" {
+		t.Fatal("Expected the archive to be imported, got", ID, err)
+	}
+	if readTestFile(filepath.Join(dir, "app", "appdata", "data")) != "rows" || readTestFile(filepath.Join(dir, "app", "auth.env")) != "USERNAME=\"admin\"\n" {
+		t.Log("Expected the exported files to be back")
+		t.Fail()
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app", "appdata", "new")); !os.IsNotExist(err) {
+		t.Log("Expected files created after the export to be gone")
+		t.Fail()
+	}
+
+	os.RemoveAll(filepath.Join(dir, "app"))
+	if ID, err := ImportEdgeApp(archive, false); err != nil || !Exists(ID) {
+		t.Log("Expected the archive to be imported as a new EdgeApp, got", err)
+		t.Fail()
+	}
+}
+
+func TestImportInvalidArchive(t *testing.T) {
+	if exec.Command("tar", "--zstd", "--version").Run() != nil {
+		t.Skip("tar with zstd support is not available")
+	}
+
+	dir, cleanup := setupTestEdgeApps(t)
+	defer cleanup()
+
+	writeTestFile(t, filepath.Join(dir, "src", "one", "edgebox-compose.yml"), "")
+	writeTestFile(t, filepath.Join(dir, "src", "two", "edgebox-compose.yml"), "")
+	writeTestFile(t, filepath.Join(dir, "src", "three", "README"), "")
+	writeTestFile(t, filepath.Join(dir, "src", "file.txt"), "")
+
+	cases := map[string][]string{
+		"two EdgeApps":    {"one", "two"},
+		"no compose file": {"three"},
+		"not a folder":    {"file.txt"},
+	}
+	for name, entries := range cases {
+		archive := filepath.Join(dir, name+".tar.zst")
+		output, err := exec.Command("tar", append([]string{"--zstd", "-cf", archive, "-C", filepath.Join(dir, "src")}, entries...)...).CombinedOutput()
+		if err != nil {
+			t.Fatal(string(output))
+		}
+		if _, err := ImportEdgeApp(archive, true); err == nil {
+			t.Log("Expected an error for", name)
+			t.Fail()
+		}
+	}
+}
+
+func TestRestoreReplacedEdgeApp(t *testing.T) {
+	dir, cleanup := setupTestEdgeApps(t)
+	defer cleanup()
+
+	replaced := filepath.Join(dir, ".app.replaced")
+	writeTestFile(t, filepath.Join(replaced, "edgebox-compose.yml"), "services:\n  app:\n    image: app\n")
+
+	restoreReplacedEdgeApp("app", replaced, false)
+
+	if readTestFile(filepath.Join(dir, "app", "edgebox-compose.yml")) == "" {
+		t.Fatal("Expected the replaced EdgeApp to be put back")
+	}
+	if _, err := os.Stat(replaced); !os.IsNotExist(err) {
+		t.Fatal("Expected the replaced folder to be moved, got", err)
+	}
+}
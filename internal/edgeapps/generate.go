@@ -0,0 +1,147 @@
+package edgeapps
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+const alphanumericCharset string = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func (g *ManifestGenerate) setDefaults() {
+	if g == nil {
+		return
+	}
+	if g.Type == "" {
+		g.Type = GENERATE_STRING
+	}
+	if g.Length == 0 && g.Type != GENERATE_UUID {
+		g.Length = defaultGenerateLength
+	}
+	if g.Charset == "" && g.Type == GENERATE_STRING {
+		g.Charset = alphanumericCharset
+	}
+}
+
+// problems : Returns what is wrong with how the value of option key is generated
+func (g ManifestGenerate) problems(key string) []string {
+
+	var problems []string
+
+	switch g.Type {
+	case GENERATE_STRING:
+		if len([]rune(g.Charset)) < 2 {
+			problems = append(problems, "option "+key+" needs a generate charset of at least 2 characters")
+		}
+	case GENERATE_HEX, GENERATE_BASE64, GENERATE_UUID:
+		if g.Charset != "" {
+			problems = append(problems, "option "+key+" can only have a generate charset with the string type")
+		}
+	default:
+		problems = append(problems, "option "+key+" has unknown generate type "+g.Type)
+	}
+
+	if g.Type != GENERATE_UUID && (g.Length < 1 || g.Length > maxGenerateLength) {
+		problems = append(problems, "option "+key+" generate length must be between 1 and "+strconv.Itoa(maxGenerateLength))
+	}
+
+	return problems
+}
+
+// parseLegacyGenerate : Parses the generate field of edgeapp.template.env, as type[:length]. Returns nil (logging why) when it is not valid.
+func parseLegacyGenerate(spec string) *ManifestGenerate {
+
+	generate := &ManifestGenerate{}
+	parts := strings.SplitN(spec, ":", 2)
+	generate.Type = parts[0]
+	if len(parts) > 1 {
+		length, err := strconv.Atoi(parts[1])
+		if err != nil {
+			log.Printf("Ignoring generate %s of options template: invalid length", spec)
+			return nil
+		}
+		generate.Length = length
+	}
+
+	generate.setDefaults()
+	if problems := generate.problems("template"); len(problems) > 0 {
+		log.Printf("Ignoring generate %s of options template: %s", spec, strings.Join(problems, ", "))
+		return nil
+	}
+
+	return generate
+}
+
+// GenerateValue : Returns a new random value, made with a cryptographically secure generator
+func (g ManifestGenerate) GenerateValue() (string, error) {
+
+	switch g.Type {
+	case GENERATE_HEX:
+		bytes, err := randomBytes((g.Length + 1) / 2)
+		return hex.EncodeToString(bytes)[:g.Length], err
+	case GENERATE_BASE64:
+		bytes, err := randomBytes((g.Length*3 + 3) / 4)
+		return base64.RawURLEncoding.EncodeToString(bytes)[:g.Length], err
+	case GENERATE_UUID:
+		bytes, err := randomBytes(16)
+		bytes[6] = (bytes[6] & 0x0f) | 0x40 // Version 4
+		bytes[8] = (bytes[8] & 0x3f) | 0x80 // RFC 4122 variant
+		return fmt.Sprintf("%x-%x-%x-%x-%x", bytes[0:4], bytes[4:6], bytes[6:8], bytes[8:10], bytes[10:]), err
+	case GENERATE_STRING:
+		charset := []rune(g.Charset)
+		value := make([]rune, g.Length)
+		for i := range value {
+			index, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+			if err != nil {
+				return "", err
+			}
+			value[i] = charset[index.Int64()]
+		}
+		return string(value), nil
+	}
+
+	return "", fmt.Errorf("unknown generate type %s", g.Type)
+}
+
+func randomBytes(length int) ([]byte, error) {
+	bytes := make([]byte, length)
+	_, err := rand.Read(bytes)
+	return bytes, err
+}
+
+// fillGeneratedOptions : Generates a value for every option of the EdgeApp marked to be generated which has no value yet, writing them to its edgeapp.env file
+func fillGeneratedOptions(ID string) error {
+
+	manifest := getManifestOrLegacy(ID)
+	envPath := utils.GetPath(utils.EdgeAppsPath) + ID + optionsEnvFilename
+	current, _ := godotenv.Read(envPath)
+
+	generated := map[string]string{}
+	for _, option := range manifest.Options {
+		if option.Generate == nil || current[option.Key] != "" {
+			continue
+		}
+
+		value, err := option.Generate.GenerateValue()
+		if err != nil {
+			return fmt.Errorf("error generating option %s of %s: %s", option.Key, ID, err)
+		}
+		generated[option.Key] = value
+	}
+
+	if len(generated) == 0 {
+		return nil
+	}
+
+	log.Printf("Generated %d option values for %s", len(generated), ID)
+	return utils.MergeEnvFile(envPath, generated, 0600)
+}
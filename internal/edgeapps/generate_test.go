@@ -0,0 +1,113 @@
+// +build unit
+
+package edgeapps
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/joho/godotenv"
+)
+
+func TestGenerateValue(t *testing.T) {
+	cases := []struct {
+		generate ManifestGenerate
+		pattern  string
+	}{
+		{ManifestGenerate{Type: GENERATE_STRING, Length: 32, Charset: alphanumericCharset}, `^[a-zA-Z0-9]{32}$`},
+		{ManifestGenerate{Type: GENERATE_STRING, Length: 8, Charset: "ab"}, `^[ab]{8}$`},
+		{ManifestGenerate{Type: GENERATE_HEX, Length: 7}, `^[0-9a-f]{7}$`},
+		{ManifestGenerate{Type: GENERATE_BASE64, Length: 10}, `^[A-Za-z0-9_-]{10}$`},
+		{ManifestGenerate{Type: GENERATE_UUID}, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+	}
+
+	for _, c := range cases {
+		first, err := c.generate.GenerateValue()
+		second, _ := c.generate.GenerateValue()
+		if err != nil || !regexp.MustCompile(c.pattern).MatchString(first) {
+			t.Log("Expected a", c.generate.Type, "value matching", c.pattern, "got", first, err)
+			t.Fail()
+		}
+		if first == second && c.generate.Charset != "ab" {
+			t.Log("Expected different values on every call, got", first, "twice")
+			t.Fail()
+		}
+	}
+}
+
+func TestParseManifestGenerate(t *testing.T) {
+	manifest, err := ParseManifest("app", []byte("options:\n  - key: DB_PASSWORD\n    generate: {}\n  - key: APP_ID\n    generate:\n      type: uuid\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generate := manifest.Options[0].Generate
+	if generate.Type != GENERATE_STRING || generate.Length != defaultGenerateLength || generate.Charset != alphanumericCharset {
+		t.Log("Expected generate defaults, got", generate)
+		t.Fail()
+	}
+
+	invalid := map[string]string{
+		"unknown type":      "options:\n  - key: A\n    generate:\n      type: password\n",
+		"charset with hex":  "options:\n  - key: A\n    generate:\n      type: hex\n      charset: abc\n",
+		"short charset":     "options:\n  - key: A\n    generate:\n      charset: a\n",
+		"length":            "options:\n  - key: A\n    generate:\n      length: -1\n",
+		"not a string type": "options:\n  - key: A\n    type: int\n    generate: {}\n",
+	}
+	for name, content := range invalid {
+		if _, err := ParseManifest("app", []byte(content)); err == nil {
+			t.Log("Expected an error for", name)
+			t.Fail()
+		}
+	}
+}
+
+func TestFillGeneratedOptions(t *testing.T) {
+	dir, cleanup := setupTestEdgeApps(t)
+	defer cleanup()
+
+	writeTestFile(t, filepath.Join(dir, "app", "edgeapp.yml"), "options:\n  - key: DB_PASSWORD\n    generate: {}\n  - key: SECRET_KEY\n    generate:\n      type: hex\n      length: 64\n  - key: ADMIN_USER\n")
+	writeTestFile(t, filepath.Join(dir, "app", "edgeapp.env"), "SECRET_KEY=chosen\n")
+
+	err := fillGeneratedOptions("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, _ := godotenv.Read(filepath.Join(dir, "app", "edgeapp.env"))
+	if len(env["DB_PASSWORD"]) != defaultGenerateLength || env["SECRET_KEY"] != "chosen" {
+		t.Log("Expected only empty generated options to be filled, got", env)
+		t.Fail()
+	}
+	if _, found := env["ADMIN_USER"]; found {
+		t.Log("Expected options not generated to be left alone, got", env)
+		t.Fail()
+	}
+
+	password := env["DB_PASSWORD"]
+	fillGeneratedOptions("app")
+	env, _ = godotenv.Read(filepath.Join(dir, "app", "edgeapp.env"))
+	if env["DB_PASSWORD"] != password {
+		t.Log("Expected generated values to be kept on reinstall")
+		t.Fail()
+	}
+}
+
+func TestLegacyGenerate(t *testing.T) {
+	dir, cleanup := setupTestEdgeApps(t)
+	defer cleanup()
+
+	writeTestFile(t, filepath.Join(dir, "legacy", "edgeapp.template.env"), "DB_PASSWORD=string|true|Database password||hex:16\nTOKEN=string|false|Token||color\n")
+
+	manifest := getLegacyManifest("legacy")
+	if manifest.Options[0].Generate == nil || manifest.Options[0].Generate.Type != GENERATE_HEX || manifest.Options[0].Generate.Length != 16 {
+		t.Log("Expected the generate field of the template to be read, got", manifest.Options[0].Generate)
+		t.Fail()
+	}
+	if manifest.Options[1].Generate != nil || !strings.HasPrefix(manifest.Options[1].Key, "TOKEN") {
+		t.Log("Expected an invalid generate field to be ignored, got", manifest.Options[1].Generate)
+		t.Fail()
+	}
+}
@@ -0,0 +1,299 @@
+package edgeapps
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+const defaultHealthCheckInterval time.Duration = time.Second * 30
+const defaultHealthCheckTimeout time.Duration = time.Second * 5
+const healthCheckMessageMaxLength int = 200
+
+// healthChecksTick : How often due health checks and the watchdog run, each check still running only as often as its interval says
+const healthChecksTick time.Duration = time.Second * 5
+
+// HealthCheckResult : Struct representing the last result of a health check of an EdgeApp
+type HealthCheckResult struct {
+	Service   string `json:"service"`
+	Type      string `json:"type"`
+	Healthy   bool   `json:"healthy"`
+	Message   string `json:"message"`
+	CheckedAt int64  `json:"checked_at"` // Unix time
+}
+
+var lastHealthResults = map[string][]HealthCheckResult{}
+var lastHealthResultsMutex sync.Mutex
+var startHealthChecksOnce sync.Once
+
+// GetEdgeAppHealth : Returns the last result of every health check of an EdgeApp, in the order of its manifest. Checks not ran yet are left out.
+func GetEdgeAppHealth(ID string) []HealthCheckResult {
+	lastHealthResultsMutex.Lock()
+	defer lastHealthResultsMutex.Unlock()
+
+	results := []HealthCheckResult{}
+	for _, result := range lastHealthResults[ID] {
+		if result.CheckedAt != 0 {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// clearEdgeAppHealth : Forgets the health check results of an EdgeApp, so a stopped or rebuilt EdgeApp is not judged by them
+func clearEdgeAppHealth(ID string) {
+	lastHealthResultsMutex.Lock()
+	delete(lastHealthResults, ID)
+	lastHealthResultsMutex.Unlock()
+}
+
+// StartHealthChecks : Runs the health checks and then the watchdog every few seconds in the background, so slow checks or restarts don't hold the tasks back
+func StartHealthChecks() {
+	startHealthChecksOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(healthChecksTick)
+			defer ticker.Stop()
+			for range ticker.C {
+				RunHealthChecks()
+				RunWatchdog()
+			}
+		}()
+	})
+}
+
+// RunHealthChecks : Runs the health checks of every running EdgeApp whose interval elapsed since they last ran, updating their status.
+// EdgeApps without health checks are skipped before asking docker anything, their status being kept up to date by the tasks.
+func RunHealthChecks() {
+	for _, ID := range sortedEdgeAppIDs() {
+		if !IsEdgeAppInstalled(ID) || !hasHealthChecks(ID) {
+			clearEdgeAppHealth(ID)
+			continue
+		}
+		checkEdgeAppHealth(ID, false)
+		GetEdgeAppStatus(ID)
+	}
+}
+
+func hasHealthChecks(ID string) bool {
+	manifest, err := GetManifest(ID)
+	return err == nil && len(manifest.HealthChecks) > 0
+}
+
+// checkEdgeAppHealth : Runs the health checks of an EdgeApp (all of them when force is true, otherwise the ones due), concurrently, when every service is running
+func checkEdgeAppHealth(ID string, force bool) {
+
+	manifest, err := GetManifest(ID)
+	if err != nil || len(manifest.HealthChecks) == 0 {
+		clearEdgeAppHealth(ID)
+		return
+	}
+
+	for _, service := range GetEdgeAppServices(ID) {
+		if !service.IsRunning {
+			clearEdgeAppHealth(ID)
+			return
+		}
+	}
+
+	lastHealthResultsMutex.Lock()
+	previous := lastHealthResults[ID]
+	lastHealthResultsMutex.Unlock()
+	if len(previous) != len(manifest.HealthChecks) {
+		previous = make([]HealthCheckResult, len(manifest.HealthChecks))
+	}
+
+	results := make([]HealthCheckResult, len(manifest.HealthChecks))
+	var wg sync.WaitGroup
+	now := time.Now()
+	for i, check := range manifest.HealthChecks {
+		results[i] = previous[i]
+		if !force && now.Sub(time.Unix(previous[i].CheckedAt, 0)) < check.interval() {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, check ManifestHealthCheck) {
+			defer wg.Done()
+			results[i] = runHealthCheck(check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		if result.CheckedAt != 0 && !result.Healthy && !containsResult(previous, result) {
+			log.Printf("Health check %s of %s (%s) failed: %s", result.Type, ID, result.Service, result.Message)
+		}
+	}
+
+	lastHealthResultsMutex.Lock()
+	lastHealthResults[ID] = results
+	lastHealthResultsMutex.Unlock()
+}
+
+func containsResult(results []HealthCheckResult, result HealthCheckResult) bool {
+	for _, r := range results {
+		if r.Service == result.Service && r.Type == result.Type && r.Healthy == result.Healthy && r.Message == result.Message {
+			return true
+		}
+	}
+	return false
+}
+
+// applyHealth : Returns the status of an EdgeApp with every service running, given its last health check results. Without results, it stays on.
+func applyHealth(status EdgeAppStatus, results []HealthCheckResult) EdgeAppStatus {
+
+	failed := 0
+	for _, result := range results {
+		if !result.Healthy {
+			failed++
+		}
+	}
+
+	switch {
+	case failed == 0:
+		return status
+	case failed == len(results):
+		return EdgeAppStatus{4, "unhealthy"}
+	default:
+		return EdgeAppStatus{3, "degraded"}
+	}
+}
+
+func (c ManifestHealthCheck) interval() time.Duration {
+	interval, err := time.ParseDuration(c.Interval)
+	if err != nil || interval <= 0 {
+		return defaultHealthCheckInterval
+	}
+	return interval
+}
+
+func (c ManifestHealthCheck) timeout() time.Duration {
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil || timeout <= 0 {
+		return defaultHealthCheckTimeout
+	}
+	return timeout
+}
+
+// runHealthCheck : Runs a single health check against the container of its service
+func runHealthCheck(check ManifestHealthCheck) HealthCheckResult {
+
+	ctx, cancel := context.WithTimeout(context.Background(), check.timeout())
+	defer cancel()
+
+	var err error
+	switch check.Type {
+	case HEALTH_CHECK_HTTP, HEALTH_CHECK_TCP:
+		var host string
+		host, err = getServiceAddress(ctx, check.Service)
+		if err == nil && check.Type == HEALTH_CHECK_HTTP {
+			err = checkHTTP(ctx, host, check)
+		} else if err == nil {
+			err = checkTCP(ctx, host, check)
+		}
+	case HEALTH_CHECK_COMMAND:
+		err = checkCommand(ctx, check)
+	default:
+		err = fmt.Errorf("unknown type %s", check.Type)
+	}
+
+	result := HealthCheckResult{
+		Service:   check.Service,
+		Type:      check.Type,
+		Healthy:   err == nil,
+		Message:   "ok",
+		CheckedAt: time.Now().Unix(),
+	}
+	if err != nil {
+		result.Message = err.Error()
+		if len(result.Message) > healthCheckMessageMaxLength {
+			result.Message = result.Message[:healthCheckMessageMaxLength] + "..."
+		}
+	}
+
+	return result
+}
+
+// checkHTTP : Requests the path of the check from host, expecting its status code
+func checkHTTP(ctx context.Context, host string, check ManifestHealthCheck) error {
+
+	url := "http://" + net.JoinHostPort(host, strconv.Itoa(check.Port)) + check.Path
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	// Redirects are answers too, ex: to a login page
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	if response.StatusCode != check.ExpectedStatus {
+		return fmt.Errorf("%s responded %s, expected %d", check.Path, response.Status, check.ExpectedStatus)
+	}
+
+	return nil
+}
+
+// checkTCP : Opens a connection to the port of the check on host
+func checkTCP(ctx context.Context, host string, check ManifestHealthCheck) error {
+
+	var dialer net.Dialer
+	connection, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(check.Port)))
+	if err != nil {
+		return err
+	}
+
+	return connection.Close()
+}
+
+// checkCommand : Runs the command of the check with sh in the service container, expecting it to exit with 0
+func checkCommand(ctx context.Context, check ManifestHealthCheck) error {
+
+	wsPath := utils.GetPath(utils.WsPath)
+	cmd := exec.CommandContext(ctx, "docker", "compose", "-f", wsPath+"/docker-compose.yml", "exec", "-T", check.Service, "sh", "-c", check.Command)
+	cmd.Dir = wsPath
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return fmt.Errorf("timed out after %s", check.timeout())
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// getServiceAddress : Returns the IP address of the container of a service in the docker network, as edgeboxctl reaches it from the host
+func getServiceAddress(ctx context.Context, service string) (string, error) {
+
+	wsPath := utils.GetPath(utils.WsPath)
+	output, err := exec.CommandContext(ctx, "docker", "compose", "-f", wsPath+"/docker-compose.yml", "ps", "-q", service).Output()
+	containerID := strings.TrimSpace(string(output))
+	if err != nil || containerID == "" {
+		return "", fmt.Errorf("no container found for service %s", service)
+	}
+
+	output, err = exec.CommandContext(ctx, "docker", "inspect", "-f", "{{range .NetworkSettings.Networks}}{{.IPAddress}} {{end}}", containerID).Output()
+	if err != nil {
+		return "", fmt.Errorf("error inspecting container of service %s: %s", service, err)
+	}
+	addresses := strings.Fields(string(output))
+	if len(addresses) == 0 {
+		return "", fmt.Errorf("container of service %s has no address", service)
+	}
+
+	return addresses[0], nil
+}
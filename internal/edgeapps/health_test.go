@@ -0,0 +1,126 @@
+// +build unit
+
+package edgeapps
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testServerAddress(t *testing.T, server *httptest.Server) (string, int) {
+	parsed, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(parsed.Port())
+	return parsed.Hostname(), port
+}
+
+func TestCheckHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/status":
+			w.WriteHeader(http.StatusOK)
+		case "/login":
+			http.Redirect(w, r, "/status", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	host, port := testServerAddress(t, server)
+
+	cases := []struct {
+		path           string
+		expectedStatus int
+		healthy        bool
+	}{
+		{"/status", 200, true},
+		{"/login", 302, true},
+		{"/login", 200, false},
+		{"/broken", 200, false},
+	}
+
+	for _, c := range cases {
+		err := checkHTTP(context.Background(), host, ManifestHealthCheck{Type: HEALTH_CHECK_HTTP, Path: c.path, Port: port, ExpectedStatus: c.expectedStatus})
+		if (err == nil) != c.healthy {
+			t.Errorf("Expected %s expecting %d to be healthy: %t, got %v", c.path, c.expectedStatus, c.healthy, err)
+		}
+	}
+}
+
+func TestCheckHTTPTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+	}))
+	defer server.Close()
+	host, port := testServerAddress(t, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	err := checkHTTP(ctx, host, ManifestHealthCheck{Type: HEALTH_CHECK_HTTP, Path: "/", Port: port, ExpectedStatus: 200})
+	if err == nil {
+		t.Error("Expected a slow response to fail the check")
+	}
+}
+
+func TestCheckTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	err = checkTCP(context.Background(), "127.0.0.1", ManifestHealthCheck{Type: HEALTH_CHECK_TCP, Port: port})
+	if err != nil {
+		t.Error("Expected an open port to pass the check, got", err)
+	}
+
+	listener.Close()
+	err = checkTCP(context.Background(), "127.0.0.1", ManifestHealthCheck{Type: HEALTH_CHECK_TCP, Port: port})
+	if err == nil {
+		t.Error("Expected a closed port to fail the check")
+	}
+}
+
+func TestApplyHealth(t *testing.T) {
+	on := EdgeAppStatus{1, "on"}
+	healthy := HealthCheckResult{Healthy: true}
+	failing := HealthCheckResult{Healthy: false}
+
+	cases := []struct {
+		results  []HealthCheckResult
+		expected string
+	}{
+		{nil, "on"},
+		{[]HealthCheckResult{healthy, healthy}, "on"},
+		{[]HealthCheckResult{healthy, failing}, "degraded"},
+		{[]HealthCheckResult{failing, failing}, "unhealthy"},
+	}
+
+	for _, c := range cases {
+		status := applyHealth(on, c.results)
+		if status.Description != c.expected {
+			t.Errorf("Expected %v to be %s, got %s", c.results, c.expected, status.Description)
+		}
+	}
+}
+
+func TestHealthCheckDurations(t *testing.T) {
+	check := ManifestHealthCheck{Interval: "1m", Timeout: "2s"}
+	if check.interval() != time.Minute || check.timeout() != time.Second*2 {
+		t.Error("Expected the declared interval and timeout, got", check.interval(), check.timeout())
+	}
+
+	check = ManifestHealthCheck{}
+	if check.interval() != defaultHealthCheckInterval || check.timeout() != defaultHealthCheckTimeout {
+		t.Error("Expected the default interval and timeout, got", check.interval(), check.timeout())
+	}
+}
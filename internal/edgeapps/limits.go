@@ -0,0 +1,262 @@
+package edgeapps
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v2"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+const limitsEnvFilename = "/limits.env"
+const limitsOriginalFilename = "/edgebox-compose.original.yml"
+
+// Restart policies of the containers of an EdgeApp
+const (
+	RESTART_NO             string = "no"
+	RESTART_ALWAYS         string = "always"
+	RESTART_ON_FAILURE     string = "on-failure"
+	RESTART_UNLESS_STOPPED string = "unless-stopped"
+)
+
+// ManifestLimits : Struct representing the resources every container of an EdgeApp can use at most. Empty values are not limited.
+type ManifestLimits struct {
+	CPUs      string `yaml:"cpus" json:"cpus"`             // CPU quota in cores, ex: 1.5
+	CPUShares string `yaml:"cpu_shares" json:"cpu_shares"` // Relative CPU weight when CPUs are busy, 1024 being the docker default
+	Memory    string `yaml:"memory" json:"memory"`         // Ex: 512M, 2G
+	Pids      string `yaml:"pids" json:"pids"`             // Processes and threads
+	Restart   string `yaml:"restart" json:"restart"`       // no, always, on-failure or unless-stopped
+}
+
+// limitsEnvKeys : Keys of the limits.env file, for each field of ManifestLimits
+var limitsEnvKeys = []string{"CPUS", "CPU_SHARES", "MEMORY", "PIDS", "RESTART"}
+
+func (l *ManifestLimits) fields() []*string {
+	return []*string{&l.CPUs, &l.CPUShares, &l.Memory, &l.Pids, &l.Restart}
+}
+
+// IsEmpty : Returns true when nothing is limited
+func (l ManifestLimits) IsEmpty() bool {
+	return l == ManifestLimits{}
+}
+
+// problems : Returns what is wrong with the limits, naming fields after prefix
+func (l ManifestLimits) problems(prefix string) []string {
+
+	var problems []string
+
+	if l.CPUs != "" {
+		if cpus, err := strconv.ParseFloat(l.CPUs, 64); err != nil || cpus <= 0 {
+			problems = append(problems, prefix+"cpus must be a number of cores above 0, ex: 1.5 (got "+l.CPUs+")")
+		}
+	}
+	if l.CPUShares != "" {
+		if shares, err := strconv.Atoi(l.CPUShares); err != nil || shares < 2 || shares > 262144 {
+			problems = append(problems, prefix+"cpu_shares must be between 2 and 262144 (got "+l.CPUShares+")")
+		}
+	}
+	if l.Memory != "" && !sizePattern.MatchString(l.Memory) {
+		problems = append(problems, prefix+"memory must be a size, ex: 512M (got "+l.Memory+")")
+	}
+	if l.Pids != "" {
+		if pids, err := strconv.Atoi(l.Pids); err != nil || pids < 1 {
+			problems = append(problems, prefix+"pids must be a number above 0 (got "+l.Pids+")")
+		}
+	}
+	switch l.Restart {
+	case "", RESTART_NO, RESTART_ALWAYS, RESTART_ON_FAILURE, RESTART_UNLESS_STOPPED:
+	default:
+		problems = append(problems, prefix+"restart must be no, always, on-failure or unless-stopped (got "+l.Restart+")")
+	}
+
+	return problems
+}
+
+// GetEdgeAppLimits : Returns the limits of an EdgeApp, being the ones of its manifest replaced by the ones set in the device
+func GetEdgeAppLimits(ID string) ManifestLimits {
+
+	limits := getManifestOrLegacy(ID).Limits
+
+	values, err := godotenv.Read(utils.GetPath(utils.EdgeAppsPath) + ID + limitsEnvFilename)
+	if err != nil {
+		return limits
+	}
+
+	for i, field := range limits.fields() {
+		if value := values[limitsEnvKeys[i]]; value != "" {
+			*field = value
+		}
+	}
+
+	return limits
+}
+
+// SetEdgeAppLimits : Validates and saves limits set in the device for an EdgeApp, merging them into its compose file. Empty values fall back to the manifest.
+// Containers are only limited once rebuilt.
+func SetEdgeAppLimits(ID string, limits ManifestLimits) error {
+
+	if problems := limits.problems(""); len(problems) > 0 {
+		return fmt.Errorf("invalid limits: %s", strings.Join(problems, ", "))
+	}
+
+	values := map[string]string{}
+	for i, field := range limits.fields() {
+		values[limitsEnvKeys[i]] = *field
+	}
+
+	err := utils.WriteEnvFile(utils.GetPath(utils.EdgeAppsPath)+ID+limitsEnvFilename, values, 0644)
+	if err != nil {
+		return err
+	}
+
+	return applyLimits(ID)
+}
+
+// applyLimits : Merges the limits of an EdgeApp into each service of its edgebox-compose.yml, the only compose file ws builds from. The file of the
+// definition is kept aside while the EdgeApp is limited, so limits are always merged into it, and put back once nothing is limited.
+func applyLimits(ID string) error {
+
+	limits := GetEdgeAppLimits(ID)
+	if limits.IsEmpty() {
+		return restoreComposeFile(ID)
+	}
+
+	composePath := utils.GetPath(utils.EdgeAppsPath) + ID + configFilename
+	originalPath := utils.GetPath(utils.EdgeAppsPath) + ID + limitsOriginalFilename
+
+	original, err := ioutil.ReadFile(originalPath)
+	if os.IsNotExist(err) {
+		original, err = ioutil.ReadFile(composePath)
+		if err == nil {
+			err = ioutil.WriteFile(originalPath, original, 0644)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	content, err := mergeLimits(limits, original)
+	if err != nil {
+		return fmt.Errorf("error parsing %s of %s: %s", strings.TrimPrefix(configFilename, "/"), ID, err)
+	}
+
+	return ioutil.WriteFile(composePath, content, 0644)
+}
+
+// restoreComposeFile : Puts the edgebox-compose.yml of the EdgeApp definition back in place, when limits were merged into it
+func restoreComposeFile(ID string) error {
+	err := os.Rename(utils.GetPath(utils.EdgeAppsPath)+ID+limitsOriginalFilename, utils.GetPath(utils.EdgeAppsPath)+ID+configFilename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// applyAllLimits : Merges the limits of every installed EdgeApp into its compose file, so they are applied by the next build
+func applyAllLimits() {
+	for _, ID := range sortedEdgeAppIDs() {
+		if !IsEdgeAppInstalled(ID) {
+			continue
+		}
+		err := applyLimits(ID)
+		if err != nil {
+			log.Printf("Error applying limits of %s: %s", ID, err)
+		}
+	}
+}
+
+// mergeLimits : Returns the compose file with the limits set on every service, replacing the settings of the file
+func mergeLimits(limits ManifestLimits, compose []byte) ([]byte, error) {
+
+	settings := yaml.MapSlice{}
+	if limits.CPUs != "" {
+		cpus, _ := strconv.ParseFloat(limits.CPUs, 64)
+		settings = append(settings, yaml.MapItem{Key: "cpus", Value: cpus})
+	}
+	if limits.CPUShares != "" {
+		shares, _ := strconv.Atoi(limits.CPUShares)
+		settings = append(settings, yaml.MapItem{Key: "cpu_shares", Value: shares})
+	}
+	if limits.Memory != "" {
+		settings = append(settings, yaml.MapItem{Key: "mem_limit", Value: strings.ToLower(limits.Memory)})
+	}
+	if limits.Pids != "" {
+		pids, _ := strconv.Atoi(limits.Pids)
+		settings = append(settings, yaml.MapItem{Key: "pids_limit", Value: pids})
+	}
+	if limits.Restart != "" {
+		settings = append(settings, yaml.MapItem{Key: "restart", Value: limits.Restart})
+	}
+
+	// Nested mappings are decoded as MapSlice too, keeping the order of the file
+	var document yaml.MapSlice
+	err := yaml.Unmarshal(compose, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range document {
+		if document[i].Key != "services" {
+			continue
+		}
+		services, _ := document[i].Value.(yaml.MapSlice)
+		for j := range services {
+			service, _ := services[j].Value.(yaml.MapSlice)
+			services[j].Value = setItems(service, settings)
+		}
+	}
+
+	content, err := yaml.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte("# Generated by edgeboxctl from edgebox-compose.original.yml and the EdgeApp limits, changes are overwritten\n"), content...), nil
+}
+
+// setItems : Returns the mapping with the items set, replacing the ones with the same key
+func setItems(mapping yaml.MapSlice, items yaml.MapSlice) yaml.MapSlice {
+	for _, item := range items {
+		found := false
+		for i := range mapping {
+			if mapping[i].Key == item.Key {
+				mapping[i].Value = item.Value
+				found = true
+			}
+		}
+		if !found {
+			mapping = append(mapping, item)
+		}
+	}
+	return mapping
+}
+
+// readComposeServices : Returns the services of the edgebox-compose.yml file of an EdgeApp
+func readComposeServices(ID string) ([]string, error) {
+
+	content, err := ioutil.ReadFile(utils.GetPath(utils.EdgeAppsPath) + ID + configFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	compose := struct {
+		Services yaml.MapSlice `yaml:"services"`
+	}{}
+	err = yaml.Unmarshal(content, &compose)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s of %s: %s", strings.TrimPrefix(configFilename, "/"), ID, err)
+	}
+
+	services := []string{}
+	for _, service := range compose.Services {
+		services = append(services, fmt.Sprint(service.Key))
+	}
+
+	return services, nil
+}
@@ -0,0 +1,96 @@
+// +build unit
+
+package edgeapps
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseManifestLimits(t *testing.T) {
+	manifest, err := ParseManifest("app", []byte("limits:\n  cpus: 1.5\n  cpu_shares: 512\n  memory: 1G\n  pids: 200\n  restart: unless-stopped\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ManifestLimits{CPUs: "1.5", CPUShares: "512", Memory: "1G", Pids: "200", Restart: RESTART_UNLESS_STOPPED}
+	if manifest.Limits != expected {
+		t.Fatal("Expected the limits to be read, got", manifest.Limits)
+	}
+
+	cases := map[string]string{
+		"cpus":       "limits:\n  cpus: 0\n",
+		"cpu_shares": "limits:\n  cpu_shares: 1\n",
+		"memory":     "limits:\n  memory: lots\n",
+		"pids":       "limits:\n  pids: -1\n",
+		"restart":    "limits:\n  restart: sometimes\n",
+	}
+
+	for field, content := range cases {
+		_, err := ParseManifest("app", []byte(content))
+		if err == nil || !strings.Contains(err.Error(), "limits."+field) {
+			t.Errorf("Expected an invalid %s limit to be reported, got %v", field, err)
+		}
+	}
+}
+
+func TestEdgeAppLimits(t *testing.T) {
+	dir, cleanup := setupTestEdgeApps(t)
+	defer cleanup()
+
+	compose := "services:\n  app:\n    image: app\n    restart: \"no\"\n  app-db:\n    image: db\n"
+	writeTestFile(t, filepath.Join(dir, "app", "edgebox-compose.yml"), compose)
+	writeTestFile(t, filepath.Join(dir, "app", "edgeapp.yml"), "limits:\n  memory: 512M\n  restart: always\n")
+
+	err := SetEdgeAppLimits("app", ManifestLimits{CPUs: "2", Memory: "1G"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limits := GetEdgeAppLimits("app")
+	if limits != (ManifestLimits{CPUs: "2", Memory: "1G", Restart: RESTART_ALWAYS}) {
+		t.Fatal("Expected the limits set in the device to replace the ones of the manifest, got", limits)
+	}
+
+	merged := readTestFile(filepath.Join(dir, "app", "edgebox-compose.yml"))
+	for _, expected := range []string{"  app:\n    image: app\n    restart: always\n    cpus: 2\n    mem_limit: 1g\n", "  app-db:\n    image: db\n    cpus: 2\n"} {
+		if !strings.Contains(merged, expected) {
+			t.Errorf("Expected the limits to be merged into every service, replacing its settings, got:\n%s", merged)
+		}
+	}
+	if original := readTestFile(filepath.Join(dir, "app", "edgebox-compose.original.yml")); original != compose {
+		t.Error("Expected the compose file of the definition to be kept aside, got:\n" + original)
+	}
+
+	// Limits are merged into the file of the definition again, not into the merged one
+	err = SetEdgeAppLimits("app", ManifestLimits{Pids: "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged := readTestFile(filepath.Join(dir, "app", "edgebox-compose.yml")); strings.Contains(merged, "cpus") || !strings.Contains(merged, "pids_limit: 100\n") {
+		t.Error("Expected the previous limits to be replaced, got:\n" + merged)
+	}
+	SetEdgeAppLimits("app", ManifestLimits{CPUs: "2", Memory: "1G"})
+
+	err = SetEdgeAppLimits("app", ManifestLimits{Pids: "many"})
+	if err == nil || !strings.Contains(err.Error(), "pids") {
+		t.Fatal("Expected invalid limits to be refused, got", err)
+	}
+	if GetEdgeAppLimits("app").CPUs != "2" {
+		t.Fatal("Expected refused limits not to be saved")
+	}
+
+	writeTestFile(t, filepath.Join(dir, "app", "edgeapp.yml"), "name: App\n")
+	err = SetEdgeAppLimits("app", ManifestLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored := readTestFile(filepath.Join(dir, "app", "edgebox-compose.yml")); restored != compose {
+		t.Fatal("Expected the compose file of the definition to be put back when nothing is limited, got:\n" + restored)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app", "edgebox-compose.original.yml")); !os.IsNotExist(err) {
+		t.Fatal("Expected no compose file to be kept aside when nothing is limited")
+	}
+}
@@ -0,0 +1,232 @@
+package edgeapps
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+const defaultLogsTail int = 200
+const maxLogsTail int = 10000
+const logsTimeout time.Duration = time.Minute
+const logsExtension string = ".log"
+const redactedLogValue string = "********"
+
+// maxLogsFiles : Logs files kept in the logs folder, older ones being removed whenever one is written
+const maxLogsFiles int = 20
+
+// minRedactedLength : Secret values shorter than this are not searched for in logs, as they would match unrelated text
+const minRedactedLength int = 4
+
+// MAX_INLINE_LOGS_SIZE : Bytes of logs returned in a task result, larger logs are written to a file instead
+const MAX_INLINE_LOGS_SIZE int = 64 * 1024
+
+// LogsRequest : Struct representing which logs of an EdgeApp to collect
+type LogsRequest struct {
+	Services []string // Every service of the EdgeApp when empty
+	Tail     int      // Lines of each service, from the end, defaults to 200
+	Since    string   // RFC 3339 timestamp, unix timestamp or duration (ex: 10m), everything when empty
+}
+
+// Logs : Struct representing the logs collected from an EdgeApp, either inline or written to a file
+type Logs struct {
+	ID       string   `json:"id"`
+	Services []string `json:"services"`
+	Logs     string   `json:"logs,omitempty"`
+	Path     string   `json:"path,omitempty"`
+	Size     int      `json:"size"`
+}
+
+var secretAssignmentPattern = regexp.MustCompile(`(?i)((?:pass(?:word|wd)?|secret|token|api[_-]?key)[A-Za-z0-9_]*["']?\s*[:=]\s*["']?)[^\s"'&,;]+`)
+var bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+
+// GetEdgeAppLogs : Collects the container logs of an EdgeApp with timestamps, redacting its secrets. Logs are written to a file in the logs folder
+// when toFile is true or they are larger than MAX_INLINE_LOGS_SIZE.
+func GetEdgeAppLogs(ID string, request LogsRequest, toFile bool) (Logs, error) {
+
+	if !Exists(ID) {
+		return Logs{}, fmt.Errorf("EdgeApp %s not found", ID)
+	}
+
+	services, err := readComposeServices(ID)
+	if err != nil {
+		return Logs{}, err
+	}
+	args, err := composeLogsArgs(services, request)
+	if err != nil {
+		return Logs{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), logsTimeout)
+	defer cancel()
+
+	wsPath := utils.GetPath(utils.WsPath)
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", append([]string{"compose", "-f", wsPath + "/docker-compose.yml"}, args...)...)
+	cmd.Dir = wsPath
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err = cmd.Run()
+	content := redactLogs(output.String(), getEdgeAppSecrets(ID))
+	if err != nil {
+		return Logs{}, fmt.Errorf("error collecting logs of %s: %s: %s", ID, err, strings.TrimSpace(content))
+	}
+
+	logs := Logs{ID: ID, Services: servicesOrAll(services, request), Size: len(content)}
+
+	if !toFile && len(content) <= MAX_INLINE_LOGS_SIZE {
+		logs.Logs = content
+		return logs, nil
+	}
+
+	logsPath := utils.GetPath(utils.EdgeAppLogsPath)
+	err = os.MkdirAll(logsPath, 0700)
+	if err != nil {
+		return Logs{}, err
+	}
+	// Unique even when collected twice in the same second, and only readable by root
+	file, err := ioutil.TempFile(logsPath, ID+"-"+time.Now().Format("20060102-150405")+"-*"+logsExtension)
+	if err != nil {
+		return Logs{}, err
+	}
+	_, err = file.WriteString(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return Logs{}, err
+	}
+	logs.Path = file.Name()
+
+	pruneLogsFiles(logsPath, logs.Path)
+
+	return logs, nil
+}
+
+// pruneLogsFiles : Removes the oldest logs files of the logs folder beyond maxLogsFiles, never the one just written
+func pruneLogsFiles(logsPath string, written string) {
+
+	entries, err := ioutil.ReadDir(logsPath)
+	if err != nil {
+		log.Printf("Error pruning logs files: %s", err)
+		return
+	}
+
+	var files []os.FileInfo
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && strings.HasSuffix(entry.Name(), logsExtension) && logsPath+entry.Name() != written {
+			files = append(files, entry)
+		}
+	}
+
+	// Newest first, keeping room for the one just written
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().After(files[j].ModTime()) })
+	for i := maxLogsFiles - 1; i < len(files); i++ {
+		err := os.Remove(logsPath + files[i].Name())
+		if err != nil {
+			log.Printf("Error removing logs file %s: %s", files[i].Name(), err)
+		}
+	}
+}
+
+func servicesOrAll(services []string, request LogsRequest) []string {
+	if len(request.Services) == 0 {
+		return services
+	}
+	return request.Services
+}
+
+// composeLogsArgs : Returns the arguments of docker compose logs for the request, checking its services belong to the EdgeApp
+func composeLogsArgs(services []string, request LogsRequest) ([]string, error) {
+
+	known := map[string]bool{}
+	for _, service := range services {
+		known[service] = true
+	}
+	for _, service := range request.Services {
+		if !known[service] {
+			return nil, fmt.Errorf("service %s not found, expected one of %s", service, strings.Join(services, ", "))
+		}
+	}
+
+	tail := request.Tail
+	if tail == 0 {
+		tail = defaultLogsTail
+	}
+	if tail < 0 || tail > maxLogsTail {
+		return nil, fmt.Errorf("tail must be between 1 and %d lines", maxLogsTail)
+	}
+
+	args := []string{"logs", "--no-color", "--timestamps", "--tail", strconv.Itoa(tail)}
+	if request.Since != "" {
+		if !isLogsSince(request.Since) {
+			return nil, fmt.Errorf("since must be a timestamp or a duration, ex: 2024-12-08T10:15:00Z or 10m (got %s)", request.Since)
+		}
+		args = append(args, "--since", request.Since)
+	}
+
+	return append(args, servicesOrAll(services, request)...), nil
+}
+
+func isLogsSince(since string) bool {
+	if _, err := time.Parse(time.RFC3339, since); err == nil {
+		return true
+	}
+	if _, err := strconv.ParseInt(since, 10, 64); err == nil {
+		return true
+	}
+	duration, err := time.ParseDuration(since)
+	return err == nil && duration > 0
+}
+
+// getEdgeAppSecrets : Returns the values of the secret options and the login password of an EdgeApp
+func getEdgeAppSecrets(ID string) []string {
+
+	var secrets []string
+
+	values, _ := godotenv.Read(utils.GetPath(utils.EdgeAppsPath) + ID + optionsEnvFilename)
+	for _, option := range getManifestOrLegacy(ID).Options {
+		if option.IsSecret() && values[option.Key] != "" {
+			secrets = append(secrets, values[option.Key])
+		}
+	}
+
+	login, _ := godotenv.Read(utils.GetPath(utils.EdgeAppsPath) + ID + authEnvFilename)
+	if login["PASSWORD"] != "" {
+		secrets = append(secrets, login["PASSWORD"])
+	}
+
+	return secrets
+}
+
+// redactLogs : Replaces the given secrets, and values assigned to keys or headers looking like credentials, ex: password=..., Authorization: Bearer ...
+func redactLogs(logs string, secrets []string) string {
+
+	// Longer first, so a secret containing another is replaced whole
+	sorted := append([]string{}, secrets...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, secret := range sorted {
+		if len(secret) >= minRedactedLength {
+			logs = strings.ReplaceAll(logs, secret, redactedLogValue)
+		}
+	}
+
+	logs = secretAssignmentPattern.ReplaceAllString(logs, "${1}"+redactedLogValue)
+	logs = bearerPattern.ReplaceAllString(logs, "${1}"+redactedLogValue)
+
+	return logs
+}
@@ -0,0 +1,429 @@
+package edgeapps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v2"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+const manifestFilename = "/edgeapp.yml"
+const hookTimeout time.Duration = time.Minute * 5
+
+// Option types of the manifest. Legacy options keep whatever format their template declares.
+const (
+	OPTION_STRING string = "string"
+	OPTION_INT    string = "int"
+	OPTION_BOOL   string = "bool"
+	OPTION_URL    string = "url"
+	OPTION_EMAIL  string = "email"
+	OPTION_PORT   string = "port"
+	OPTION_ENUM   string = "enum"
+	OPTION_REGEX  string = "regex"
+)
+
+// Types of values generated for options on install
+const (
+	GENERATE_STRING string = "string" // Characters of the charset, alphanumeric by default
+	GENERATE_HEX    string = "hex"
+	GENERATE_BASE64 string = "base64" // URL safe alphabet, without padding
+	GENERATE_UUID   string = "uuid"
+)
+
+const defaultGenerateLength int = 32
+const maxGenerateLength int = 4096
+
+// Health check types of the manifest
+const (
+	HEALTH_CHECK_HTTP    string = "http"
+	HEALTH_CHECK_TCP     string = "tcp"
+	HEALTH_CHECK_COMMAND string = "command"
+)
+
+// Hooks of the manifest, ran from the EdgeApp folder
+const (
+	HOOK_POST_INSTALL string = "post_install"
+	HOOK_PRE_REMOVE   string = "pre_remove"
+	HOOK_PRE_START    string = "pre_start"
+	HOOK_POST_STOP    string = "post_stop"
+)
+
+// Manifest : Struct representing the edgeapp.yml manifest of an EdgeApp, describing it to edgeboxctl and the dashboard
+type Manifest struct {
+	Name         string                `yaml:"name"`
+	Description  string                `yaml:"description"`
+	Version      string                `yaml:"version"`
+	Icon         string                `yaml:"icon"`
+	Experimental bool                  `yaml:"experimental"`
+	Categories   []string              `yaml:"categories"`
+	Options      []ManifestOption      `yaml:"options"`
+	Resources    ManifestResources     `yaml:"resources"`
+	Limits       ManifestLimits        `yaml:"limits"`
+	HealthChecks []ManifestHealthCheck `yaml:"health_checks"`
+	Hooks        ManifestHooks         `yaml:"hooks"`
+	Dependencies ManifestDependencies  `yaml:"dependencies"`
+	Legacy       bool                  `yaml:"-"` // Read from edgebox.env and edgeapp.template.env, as there is no edgeapp.yml
+}
+
+// ManifestOption : Struct representing a configurable option of an EdgeApp, written to its edgeapp.env file
+type ManifestOption struct {
+	Key           string            `yaml:"key"`
+	Type          string            `yaml:"type"`
+	Description   string            `yaml:"description"`
+	Default       string            `yaml:"default"`
+	Secret        *bool             `yaml:"secret"` // When not given, guessed from the key (pass, key, secret, token)
+	InstallLocked bool              `yaml:"install_locked"`
+	Values        []string          `yaml:"values"`   // Allowed values of enum options
+	Pattern       string            `yaml:"pattern"`  // Regular expression values of regex options must match
+	Generate      *ManifestGenerate `yaml:"generate"` // When set, an empty value is generated on install
+	matcher       *regexp.Regexp    // Pattern of regex options, compiled when parsing to match whole values
+}
+
+// ManifestGenerate : Struct representing how to generate a random value for an option, ex: a database password
+type ManifestGenerate struct {
+	Type    string `yaml:"type"`    // Defaults to string
+	Length  int    `yaml:"length"`  // Characters of the value, defaults to 32. Not used by uuid.
+	Charset string `yaml:"charset"` // Characters string values are made of
+}
+
+// ManifestResources : Struct representing the resources an EdgeApp needs from the device
+type ManifestResources struct {
+	Memory        string   `yaml:"memory" json:"memory"`   // Ex: 512M, 2G
+	Storage       string   `yaml:"storage" json:"storage"` // Ex: 10G
+	Architectures []string `yaml:"architectures" json:"architectures"`
+}
+
+// ManifestHealthCheck : Struct representing how to tell a service of an EdgeApp works, beyond its container running
+type ManifestHealthCheck struct {
+	Service        string `yaml:"service"`
+	Type           string `yaml:"type"`
+	Path           string `yaml:"path"`            // http
+	Port           int    `yaml:"port"`            // http and tcp
+	ExpectedStatus int    `yaml:"expected_status"` // http, defaults to 200
+	Command        string `yaml:"command"`         // command, ran in the service container
+	Interval       string `yaml:"interval"`        // Ex: 30s
+	Timeout        string `yaml:"timeout"`         // Ex: 5s
+}
+
+// ManifestDependencies : Struct representing the other EdgeApps (by ID) an EdgeApp uses
+type ManifestDependencies struct {
+	Required []string `yaml:"required" json:"required"` // Installed and started before the EdgeApp
+	Optional []string `yaml:"optional" json:"optional"` // Started before the EdgeApp when installed
+}
+
+// ManifestHooks : Struct representing scripts (relative to the EdgeApp folder) ran at points of the EdgeApp lifecycle
+type ManifestHooks struct {
+	PostInstall string `yaml:"post_install"`
+	PreRemove   string `yaml:"pre_remove"`
+	PreStart    string `yaml:"pre_start"`
+	PostStop    string `yaml:"post_stop"`
+}
+
+var optionKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+var edgeAppIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+var sizePattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?[KMGT]?$`)
+
+// GetManifest : Returns the manifest of an EdgeApp, from its edgeapp.yml file or, when it has none, from the legacy edgebox.env and edgeapp.template.env files
+func GetManifest(ID string) (Manifest, error) {
+
+	content, err := ioutil.ReadFile(utils.GetPath(utils.EdgeAppsPath) + ID + manifestFilename)
+	if os.IsNotExist(err) {
+		return getLegacyManifest(ID), nil
+	}
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	return ParseManifest(ID, content)
+}
+
+// getManifestOrLegacy : Returns the manifest of an EdgeApp, using the legacy files when its edgeapp.yml is invalid
+func getManifestOrLegacy(ID string) Manifest {
+	manifest, err := GetManifest(ID)
+	if err != nil {
+		log.Printf("%s. Using edgebox.env and edgeapp.template.env instead.", err)
+		return getLegacyManifest(ID)
+	}
+	return manifest
+}
+
+// ParseManifest : Parses and validates the contents of an edgeapp.yml file. Unknown fields are errors, so typos do not go unnoticed.
+func ParseManifest(ID string, content []byte) (Manifest, error) {
+
+	manifest := Manifest{}
+	err := yaml.UnmarshalStrict(content, &manifest)
+	if err != nil {
+		return manifest, fmt.Errorf("error parsing manifest of %s: %s", ID, err)
+	}
+
+	if manifest.Name == "" {
+		manifest.Name = ID
+	}
+	for i := range manifest.Options {
+		if manifest.Options[i].Type == "" {
+			manifest.Options[i].Type = OPTION_STRING
+		}
+		manifest.Options[i].Generate.setDefaults()
+		if manifest.Options[i].Type == OPTION_REGEX && manifest.Options[i].Pattern != "" {
+			manifest.Options[i].matcher, _ = compileOptionPattern(manifest.Options[i].Pattern)
+		}
+	}
+	for i := range manifest.HealthChecks {
+		if manifest.HealthChecks[i].Type == HEALTH_CHECK_HTTP && manifest.HealthChecks[i].ExpectedStatus == 0 {
+			manifest.HealthChecks[i].ExpectedStatus = 200
+		}
+	}
+
+	err = manifest.Validate()
+	if err != nil {
+		return manifest, fmt.Errorf("invalid manifest of %s: %s", ID, err)
+	}
+
+	return manifest, nil
+}
+
+// compileOptionPattern : Compiles the pattern of a regex option, anchored so values must match it whole
+func compileOptionPattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// Validate : Checks every field of the manifest, returning a single error listing all problems found
+func (m Manifest) Validate() error {
+
+	var problems []string
+
+	keys := map[string]bool{}
+	for _, option := range m.Options {
+		if !optionKeyPattern.MatchString(option.Key) {
+			problems = append(problems, "option key "+strconv.Quote(option.Key)+" is not a valid environment variable name")
+		}
+		if keys[option.Key] {
+			problems = append(problems, "option "+option.Key+" is declared twice")
+		}
+		keys[option.Key] = true
+
+		switch option.Type {
+		case OPTION_STRING, OPTION_INT, OPTION_BOOL, OPTION_URL, OPTION_EMAIL, OPTION_PORT:
+		case OPTION_ENUM:
+			if len(option.Values) == 0 {
+				problems = append(problems, "option "+option.Key+" is an enum without values")
+			}
+		case OPTION_REGEX:
+			if _, err := compileOptionPattern(option.Pattern); option.Pattern == "" || err != nil {
+				problems = append(problems, "option "+option.Key+" needs a valid pattern")
+			}
+		default:
+			problems = append(problems, "option "+option.Key+" has unknown type "+option.Type)
+		}
+
+		if option.Generate != nil {
+			if option.Type != OPTION_STRING {
+				problems = append(problems, "option "+option.Key+" can only be generated when of type string")
+			}
+			problems = append(problems, option.Generate.problems(option.Key)...)
+		}
+	}
+
+	if m.Resources.Memory != "" && !sizePattern.MatchString(m.Resources.Memory) {
+		problems = append(problems, "resources.memory must be a size, ex: 512M (got "+m.Resources.Memory+")")
+	}
+	if m.Resources.Storage != "" && !sizePattern.MatchString(m.Resources.Storage) {
+		problems = append(problems, "resources.storage must be a size, ex: 10G (got "+m.Resources.Storage+")")
+	}
+
+	problems = append(problems, m.Limits.problems("limits.")...)
+
+	for i, check := range m.HealthChecks {
+		name := "health check " + strconv.Itoa(i+1)
+		if check.Service == "" {
+			problems = append(problems, name+" needs a service")
+		}
+		switch check.Type {
+		case HEALTH_CHECK_HTTP:
+			if !strings.HasPrefix(check.Path, "/") {
+				problems = append(problems, name+" needs a path starting with /")
+			}
+			if check.Port < 1 || check.Port > 65535 {
+				problems = append(problems, name+" needs a valid port")
+			}
+		case HEALTH_CHECK_TCP:
+			if check.Port < 1 || check.Port > 65535 {
+				problems = append(problems, name+" needs a valid port")
+			}
+		case HEALTH_CHECK_COMMAND:
+			if check.Command == "" {
+				problems = append(problems, name+" needs a command")
+			}
+		default:
+			problems = append(problems, name+" has unknown type "+check.Type)
+		}
+		for field, value := range map[string]string{"interval": check.Interval, "timeout": check.Timeout} {
+			if _, err := time.ParseDuration(value); value != "" && err != nil {
+				problems = append(problems, name+" "+field+" must be a duration, ex: 30s (got "+value+")")
+			}
+		}
+	}
+
+	dependencies := map[string]bool{}
+	for _, dependency := range append(m.Dependencies.Required, m.Dependencies.Optional...) {
+		if !edgeAppIDPattern.MatchString(dependency) {
+			problems = append(problems, "dependency "+strconv.Quote(dependency)+" is not a valid EdgeApp ID")
+		}
+		if dependencies[dependency] {
+			problems = append(problems, "dependency "+dependency+" is declared twice")
+		}
+		dependencies[dependency] = true
+	}
+
+	for hook, script := range m.hookScripts() {
+		if script != "" && (filepath.IsAbs(script) || strings.HasPrefix(filepath.Clean(script), "..")) {
+			problems = append(problems, "hook "+hook+" must be a path inside the EdgeApp folder (got "+script+")")
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New(strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+func (m Manifest) hookScripts() map[string]string {
+	return map[string]string{
+		HOOK_POST_INSTALL: m.Hooks.PostInstall,
+		HOOK_PRE_REMOVE:   m.Hooks.PreRemove,
+		HOOK_PRE_START:    m.Hooks.PreStart,
+		HOOK_POST_STOP:    m.Hooks.PostStop,
+	}
+}
+
+// IsSecret : Returns true if the option value must be hidden, as declared or guessed from the key
+func (o ManifestOption) IsSecret() bool {
+	if o.Secret != nil {
+		return *o.Secret
+	}
+
+	lowercaseKey := strings.ToLower(o.Key)
+	return strings.Contains(lowercaseKey, "pass") ||
+		strings.Contains(lowercaseKey, "key") ||
+		strings.Contains(lowercaseKey, "secret") ||
+		strings.Contains(lowercaseKey, "token")
+}
+
+// splitLegacyList : Splits a comma separated value of edgebox.env, ignoring empty items
+func splitLegacyList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if strings.TrimSpace(item) != "" {
+			items = append(items, strings.TrimSpace(item))
+		}
+	}
+	return items
+}
+
+// getLegacyManifest : Builds a manifest from the edgebox.env (name, description, experimental, dependencies) and edgeapp.template.env files.
+// Template values are packed as format|installLocked|description|default|generate, generate being type[:length] (ex: hex:64).
+func getLegacyManifest(ID string) Manifest {
+
+	manifest := Manifest{Name: ID, Legacy: true}
+	edgeAppPath := utils.GetPath(utils.EdgeAppsPath) + ID
+
+	edgeAppEnv, err := godotenv.Read(edgeAppPath + envFilename)
+	if err != nil {
+		log.Println("Error loading .env file for edgeapp " + ID)
+	} else {
+		if edgeAppEnv["EDGEAPP_NAME"] != "" {
+			manifest.Name = edgeAppEnv["EDGEAPP_NAME"]
+		}
+		manifest.Description = edgeAppEnv["EDGEAPP_DESCRIPTION"]
+		manifest.Experimental = edgeAppEnv["EDGEAPP_EXPERIMENTAL"] == "true"
+		manifest.Dependencies.Required = splitLegacyList(edgeAppEnv["EDGEAPP_DEPENDENCIES"])
+		manifest.Dependencies.Optional = splitLegacyList(edgeAppEnv["EDGEAPP_OPTIONAL_DEPENDENCIES"])
+	}
+
+	edgeAppOptionsTemplate, err := godotenv.Read(edgeAppPath + optionsTemplateFilename)
+	if err != nil {
+		log.Println("Error loading options template file for edgeapp " + manifest.Name)
+		return manifest
+	}
+
+	// Env files have no order, sorting keeps the options list stable
+	keys := make([]string, 0, len(edgeAppOptionsTemplate))
+	for key := range edgeAppOptionsTemplate {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		option := ManifestOption{Key: key}
+
+		valueSlices := strings.Split(edgeAppOptionsTemplate[key], "|")
+		option.Type = valueSlices[0]
+		if len(valueSlices) > 1 {
+			option.InstallLocked = valueSlices[1] == "true"
+		}
+		if len(valueSlices) > 2 {
+			option.Description = valueSlices[2]
+		}
+		if len(valueSlices) > 3 {
+			option.Default = valueSlices[3]
+		}
+		if len(valueSlices) > 4 && valueSlices[4] != "" {
+			option.Generate = parseLegacyGenerate(valueSlices[4])
+		}
+
+		manifest.Options = append(manifest.Options, option)
+	}
+
+	return manifest
+}
+
+// runHook : Runs a hook script of the EdgeApp manifest (if it declares one) from the EdgeApp folder, with its options in the environment.
+// Hooks failing are logged, but do not stop the operation they are part of.
+func runHook(ID string, hook string) {
+
+	manifest, err := GetManifest(ID)
+	if err != nil {
+		log.Printf("Not running %s hook of %s: %s", hook, ID, err)
+		return
+	}
+
+	script := manifest.hookScripts()[hook]
+	if script == "" {
+		return
+	}
+
+	edgeAppPath := utils.GetPath(utils.EdgeAppsPath) + ID
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", filepath.Join(edgeAppPath, script))
+	cmd.Dir = edgeAppPath
+	cmd.Env = append(os.Environ(), "EDGEAPP_ID="+ID, "EDGEAPP_PATH="+edgeAppPath)
+
+	edgeAppOptionsEnv, _ := godotenv.Read(edgeAppPath + optionsEnvFilename)
+	for key, value := range edgeAppOptionsEnv {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+
+	log.Printf("Running %s hook of %s", hook, ID)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Printf("Hook %s of %s failed: %s\n%s", hook, ID, err, output)
+	}
+}
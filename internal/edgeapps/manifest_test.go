@@ -0,0 +1,173 @@
+// +build unit
+
+package edgeapps
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/config"
+)
+
+const testManifest = `
+name: Nextcloud
+description: Files, calendars and contacts
+version: 28.0.1
+icon: icon.png
+categories: [files, productivity]
+options:
+  - key: NEXTCLOUD_ADMIN_USER
+    description: Username of the admin account
+    default: admin
+    install_locked: true
+  - key: NEXTCLOUD_ADMIN_PASSWORD
+    type: string
+  - key: NEXTCLOUD_THEME
+    type: enum
+    values: [light, dark]
+    secret: false
+resources:
+  memory: 512M
+  storage: 10G
+  architectures: [arm64, amd64]
+health_checks:
+  - service: nextcloud
+    type: http
+    path: /status.php
+    port: 80
+    interval: 30s
+hooks:
+  post_install: hooks/post-install.sh
+`
+
+func setupTestEdgeApps(t *testing.T) (string, func()) {
+	dir, _ := ioutil.TempDir("", "edgeboxctl-edgeapps")
+
+	c := config.Default()
+	c.Paths.EdgeApps = dir + "/"
+	config.Set(c)
+
+	return dir, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func writeTestFile(t *testing.T, path string, content string) {
+	os.MkdirAll(filepath.Dir(path), 0755)
+	err := ioutil.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	manifest, err := ParseManifest("nextcloud", []byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if manifest.Name != "Nextcloud" || manifest.Version != "28.0.1" || len(manifest.Categories) != 2 {
+		t.Log("Unexpected metadata", manifest)
+		t.Fail()
+	}
+
+	if len(manifest.Options) != 3 || manifest.Options[0].Type != OPTION_STRING || !manifest.Options[0].InstallLocked {
+		t.Log("Expected options to default to the string type, got", manifest.Options)
+		t.Fail()
+	}
+
+	if manifest.Options[0].IsSecret() || !manifest.Options[1].IsSecret() || manifest.Options[2].IsSecret() {
+		t.Log("Expected secrets to be guessed from the key unless declared")
+		t.Fail()
+	}
+
+	if manifest.HealthChecks[0].ExpectedStatus != 200 {
+		t.Log("Expected http health checks to expect 200 by default, got", manifest.HealthChecks[0].ExpectedStatus)
+		t.Fail()
+	}
+}
+
+func TestParseInvalidManifest(t *testing.T) {
+	cases := map[string]string{
+		"unknown field":       "name: App\ndescriptoin: typo\n",
+		"invalid option key":  "options:\n  - key: not-valid\n",
+		"unknown option type": "options:\n  - key: A\n    type: color\n",
+		"enum without values": "options:\n  - key: A\n    type: enum\n",
+		"regex pattern":       "options:\n  - key: A\n    type: regex\n    pattern: '['\n",
+		"duplicated option":   "options:\n  - key: A\n  - key: A\n",
+		"resource size":       "resources:\n  memory: lots\n",
+		"health check port":   "health_checks:\n  - service: app\n    type: tcp\n",
+		"health check type":   "health_checks:\n  - service: app\n    type: ping\n",
+		"hook outside":        "hooks:\n  pre_remove: ../../bin/rm\n",
+	}
+
+	for name, content := range cases {
+		_, err := ParseManifest("app", []byte(content))
+		if err == nil {
+			t.Log("Expected an error for", name)
+			t.Fail()
+		}
+	}
+}
+
+func TestGetManifest(t *testing.T) {
+	dir, cleanup := setupTestEdgeApps(t)
+	defer cleanup()
+
+	writeTestFile(t, filepath.Join(dir, "nextcloud", "edgeapp.yml"), testManifest)
+	writeTestFile(t, filepath.Join(dir, "nextcloud", "edgebox.env"), "EDGEAPP_NAME=Ignored\n")
+
+	manifest, err := GetManifest("nextcloud")
+	if err != nil || manifest.Legacy || manifest.Name != "Nextcloud" {
+		t.Log("Expected edgeapp.yml to take precedence, got", manifest, err)
+		t.Fail()
+	}
+
+	writeTestFile(t, filepath.Join(dir, "legacy", "edgebox.env"), "EDGEAPP_NAME=Legacy App\nEDGEAPP_DESCRIPTION=An older app\nEDGEAPP_EXPERIMENTAL=true\n")
+	writeTestFile(t, filepath.Join(dir, "legacy", "edgeapp.template.env"), "SECRET_KEY=string|true|Secret key|\nPORT=int|false|Port|8080\n")
+
+	manifest, err = GetManifest("legacy")
+	if err != nil || !manifest.Legacy {
+		t.Fatal("Expected the legacy files to be used, got", manifest, err)
+	}
+	if manifest.Name != "Legacy App" || manifest.Description != "An older app" || !manifest.Experimental {
+		t.Log("Unexpected legacy metadata", manifest)
+		t.Fail()
+	}
+
+	expected := []ManifestOption{
+		{Key: "PORT", Type: "int", Description: "Port", Default: "8080"},
+		{Key: "SECRET_KEY", Type: "string", Description: "Secret key", InstallLocked: true},
+	}
+	if len(manifest.Options) != len(expected) {
+		t.Fatal("Expected", expected, "got", manifest.Options)
+	}
+	for i := range expected {
+		option := manifest.Options[i]
+		if option.Key != expected[i].Key || option.Type != expected[i].Type || option.Description != expected[i].Description || option.Default != expected[i].Default || option.InstallLocked != expected[i].InstallLocked {
+			t.Log("Expected", expected[i], "got", option)
+			t.Fail()
+		}
+	}
+}
+
+func TestRunHook(t *testing.T) {
+	dir, cleanup := setupTestEdgeApps(t)
+	defer cleanup()
+
+	writeTestFile(t, filepath.Join(dir, "app", "edgeapp.yml"), "hooks:\n  post_install: hooks/post-install.sh\n")
+	writeTestFile(t, filepath.Join(dir, "app", "edgeapp.env"), "GREETING=hello\n")
+	writeTestFile(t, filepath.Join(dir, "app", "hooks", "post-install.sh"), "echo \"$EDGEAPP_ID $GREETING\" > hook.out\n")
+
+	runHook("app", HOOK_POST_INSTALL)
+	runHook("app", HOOK_PRE_REMOVE) // Not declared, nothing to run
+
+	output, err := ioutil.ReadFile(filepath.Join(dir, "app", "hook.out"))
+	if err != nil || strings.TrimSpace(string(output)) != "app hello" {
+		t.Log("Expected the hook to run from the EdgeApp folder with its options, got", string(output), err)
+		t.Fail()
+	}
+}
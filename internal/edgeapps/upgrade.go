@@ -0,0 +1,217 @@
+package edgeapps
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/events"
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+const versionFilename = "/.version"
+const upgradeHealthTimeout time.Duration = time.Minute * 3
+const upgradeHealthInterval time.Duration = time.Second * 10
+
+// deviceStateFiles : Files of an EdgeApp folder created in the device, instead of coming with its definition. They are carried over on upgrades.
+var deviceStateFiles = []string{
+	optionsEnvFilename,
+	authEnvFilename,
+	myEdgeAppServiceEnvFilename,
+	runnableFilename,
+	postInstallFilename,
+	versionFilename,
+	limitsEnvFilename,
+	appdataFoldername,
+}
+
+// GetInstalledVersion : Returns the version an EdgeApp was installed or last upgraded with, or "" when unknown
+func GetInstalledVersion(ID string) string {
+	content, err := ioutil.ReadFile(utils.GetPath(utils.EdgeAppsPath) + ID + versionFilename)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// recordInstalledVersion : Writes the version of the EdgeApp definition in place as its installed version
+func recordInstalledVersion(ID string) {
+	version := getManifestOrLegacy(ID).Version
+	if version == "" {
+		return
+	}
+	err := ioutil.WriteFile(utils.GetPath(utils.EdgeAppsPath)+ID+versionFilename, []byte(version+"\n"), 0644)
+	if err != nil {
+		log.Printf("Error recording installed version of %s: %s", ID, err)
+	}
+}
+
+// UpgradeEdgeApp : Replaces the definition of an installed EdgeApp with the one staged in a folder (in the same filesystem as the EdgeApps folder),
+// keeping its options, login, online access and appdata. The EdgeApp is stopped and its folder snapshotted first, and when it is not running
+// after the rebuild, the previous definition and data are restored. Returns the version upgraded to.
+func UpgradeEdgeApp(ID string, staged string) (string, error) {
+
+	if !IsEdgeAppInstalled(ID) {
+		return "", fmt.Errorf("EdgeApp %s is not installed", ID)
+	}
+
+	manifest, err := readStagedManifest(ID, staged)
+	if err != nil {
+		return "", err
+	}
+	previousVersion := GetInstalledVersion(ID)
+	if manifest.Version != "" && manifest.Version == previousVersion {
+		return "", fmt.Errorf("EdgeApp %s is already at version %s", ID, previousVersion)
+	}
+
+	log.Printf("Upgrading %s from %s to %s", ID, describeVersion(previousVersion), describeVersion(manifest.Version))
+	StopEdgeApp(ID)
+
+	snapshot, err := snapshotEdgeApp(ID)
+	if err != nil {
+		return "", fmt.Errorf("error snapshotting %s, nothing was changed: %s", ID, err)
+	}
+
+	err = swapDefinition(ID, staged)
+	if err == nil {
+		err = fillGeneratedOptions(ID)
+	}
+	if err == nil {
+		buildFrameworkContainers()
+		err = waitUntilRunning(ID, upgradeHealthTimeout)
+	}
+
+	if err != nil {
+		log.Printf("Upgrade of %s failed, rolling back: %s", ID, err)
+		StopEdgeApp(ID)
+		rollbackErr := restoreSnapshot(ID, snapshot)
+		buildFrameworkContainers()
+		if rollbackErr != nil {
+			return "", fmt.Errorf("upgrade of %s failed (%s), and rolling back failed too, the snapshot is kept at %s: %s", ID, err, snapshot, rollbackErr)
+		}
+		events.Publish(events.APP_ROLLED_BACK, map[string]interface{}{"id": ID, "version": previousVersion, "error": err.Error()})
+		return "", fmt.Errorf("upgrade of %s failed, rolled back to %s: %s", ID, describeVersion(previousVersion), err)
+	}
+
+	recordInstalledVersion(ID)
+	os.RemoveAll(snapshot)
+	events.Publish(events.APP_UPGRADED, map[string]interface{}{"id": ID, "previous": previousVersion, "version": manifest.Version})
+
+	return manifest.Version, nil
+}
+
+func describeVersion(version string) string {
+	if version == "" {
+		return "an unknown version"
+	}
+	return version
+}
+
+// readStagedManifest : Returns the manifest of a staged EdgeApp definition, after checking it is one
+func readStagedManifest(ID string, staged string) (Manifest, error) {
+
+	if _, err := os.Stat(staged + configFilename); err != nil {
+		return Manifest{}, fmt.Errorf("staged definition of %s has no %s", ID, strings.TrimPrefix(configFilename, "/"))
+	}
+
+	content, err := ioutil.ReadFile(staged + manifestFilename)
+	if os.IsNotExist(err) {
+		return Manifest{Legacy: true}, nil
+	}
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	return ParseManifest(ID, content)
+}
+
+// snapshotEdgeApp : Copies the whole EdgeApp folder, including its appdata, next to it. Copies are cheap on filesystems supporting reflinks.
+func snapshotEdgeApp(ID string) (string, error) {
+
+	appPath := utils.GetPath(utils.EdgeAppsPath) + ID
+	snapshot := utils.GetPath(utils.EdgeAppsPath) + "." + ID + ".snapshot"
+
+	err := os.RemoveAll(snapshot)
+	if err != nil {
+		return snapshot, err
+	}
+
+	output, err := exec.Command("cp", "-a", "--reflink=auto", appPath, snapshot).CombinedOutput()
+	if err != nil {
+		os.RemoveAll(snapshot)
+		return snapshot, fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return snapshot, nil
+}
+
+// swapDefinition : Puts the staged definition in place of the EdgeApp folder, moving the device state files of the previous one into it
+func swapDefinition(ID string, staged string) error {
+
+	appPath := utils.GetPath(utils.EdgeAppsPath) + ID
+	previous := utils.GetPath(utils.EdgeAppsPath) + "." + ID + ".previous"
+
+	err := os.RemoveAll(previous)
+	if err == nil {
+		err = os.Rename(appPath, previous)
+	}
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(previous)
+
+	err = os.Rename(staged, appPath)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range deviceStateFiles {
+		if _, err := os.Lstat(previous + file); os.IsNotExist(err) {
+			continue
+		}
+		err = os.RemoveAll(appPath + file)
+		if err == nil {
+			err = os.Rename(previous+file, appPath+file)
+		}
+		if err != nil {
+			return fmt.Errorf("error moving %s to the new definition: %s", filepath.Base(file), err)
+		}
+	}
+
+	return nil
+}
+
+// restoreSnapshot : Puts a snapshot back in place of the EdgeApp folder, which must be stopped
+func restoreSnapshot(ID string, snapshot string) error {
+
+	appPath := utils.GetPath(utils.EdgeAppsPath) + ID
+
+	err := os.RemoveAll(appPath)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(snapshot, appPath)
+}
+
+// waitUntilRunning : Waits for every service of an EdgeApp to be running and its health checks to pass, failing after timeout
+func waitUntilRunning(ID string, timeout time.Duration) error {
+
+	deadline := time.Now().Add(timeout)
+	for {
+		checkEdgeAppHealth(ID, true)
+		status := GetEdgeAppStatus(ID)
+		if status.Description == "on" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("EdgeApp is %s after %s", status.Description, timeout)
+		}
+		time.Sleep(upgradeHealthInterval)
+	}
+}
@@ -0,0 +1,98 @@
+// +build unit
+
+package edgeapps
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readTestFile(path string) string {
+	content, _ := ioutil.ReadFile(path)
+	return string(content)
+}
+
+func TestSwapDefinition(t *testing.T) {
+	dir, cleanup := setupTestEdgeApps(t)
+	defer cleanup()
+
+	writeTestFile(t, filepath.Join(dir, "app", "edgebox-compose.yml"), "version: old\n")
+	writeTestFile(t, filepath.Join(dir, "app", "edgeapp.yml"), "version: 1.0.0\n")
+	writeTestFile(t, filepath.Join(dir, "app", "removed.sh"), "")
+	writeTestFile(t, filepath.Join(dir, "app", "edgeapp.env"), "ADMIN_USER=admin\n")
+	writeTestFile(t, filepath.Join(dir, "app", ".run"), "")
+	writeTestFile(t, filepath.Join(dir, "app", "appdata", "db", "data"), "rows")
+
+	staged := filepath.Join(dir, ".app.staged")
+	writeTestFile(t, filepath.Join(staged, "edgebox-compose.yml"), "version: new\n")
+	writeTestFile(t, filepath.Join(staged, "edgeapp.yml"), "version: 2.0.0\n")
+
+	recordInstalledVersion("app")
+	if GetInstalledVersion("app") != "1.0.0" {
+		t.Fatal("Expected the installed version to be recorded, got", GetInstalledVersion("app"))
+	}
+
+	manifest, err := readStagedManifest("app", staged)
+	if err != nil || manifest.Version != "2.0.0" {
+		t.Fatal("Expected the staged manifest to be read, got", manifest, err)
+	}
+
+	snapshot, err := snapshotEdgeApp("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = swapDefinition("app", staged)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if readTestFile(filepath.Join(dir, "app", "edgebox-compose.yml")) != "version: new\n" {
+		t.Log("Expected the new definition to be in place")
+		t.Fail()
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app", "removed.sh")); !os.IsNotExist(err) {
+		t.Log("Expected files of the previous definition to be gone")
+		t.Fail()
+	}
+	if readTestFile(filepath.Join(dir, "app", "appdata", "db", "data")) != "rows" || readTestFile(filepath.Join(dir, "app", "edgeapp.env")) != "ADMIN_USER=admin\n" || !IsEdgeAppInstalled("app") {
+		t.Log("Expected appdata, options and the installed state to be carried over")
+		t.Fail()
+	}
+	if GetInstalledVersion("app") != "1.0.0" {
+		t.Log("Expected the installed version to change only once the upgrade succeeds, got", GetInstalledVersion("app"))
+		t.Fail()
+	}
+
+	// The new version changes its data, then fails
+	writeTestFile(t, filepath.Join(dir, "app", "appdata", "db", "data"), "migrated rows")
+
+	err = restoreSnapshot("app", snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if readTestFile(filepath.Join(dir, "app", "edgebox-compose.yml")) != "version: old\n" || readTestFile(filepath.Join(dir, "app", "appdata", "db", "data")) != "rows" {
+		t.Log("Expected the previous definition and data to be restored")
+		t.Fail()
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Log("Expected only the EdgeApp folder to be left, got", len(files), "entries")
+		t.Fail()
+	}
+}
+
+func TestReadStagedManifestWithoutDefinition(t *testing.T) {
+	dir, cleanup := setupTestEdgeApps(t)
+	defer cleanup()
+
+	writeTestFile(t, filepath.Join(dir, ".staged", "edgeapp.yml"), "version: 2.0.0\n")
+	if _, err := readStagedManifest("app", filepath.Join(dir, ".staged")); err == nil {
+		t.Log("Expected an error for a staged folder without edgebox-compose.yml")
+		t.Fail()
+	}
+}
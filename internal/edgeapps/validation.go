@@ -0,0 +1,118 @@
+package edgeapps
+
+import (
+	"net/mail"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+// OptionError : Struct representing why a value was refused for an option of an EdgeApp
+type OptionError struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+// Exists : Returns true if ID is an EdgeApp available in the system
+func Exists(ID string) bool {
+	if ID == "" || strings.ContainsAny(ID, "/\\") || strings.HasPrefix(ID, ".") {
+		return false
+	}
+	_, err := os.Stat(utils.GetPath(utils.EdgeAppsPath) + ID + configFilename)
+	return err == nil
+}
+
+// ValidateOptionValues : Checks values to set for options of an EdgeApp against its manifest, returning one error per refused field (sorted by key).
+// Empty values are always accepted, they leave the option to be configured.
+func ValidateOptionValues(ID string, values map[string]string) []OptionError {
+
+	errors := []OptionError{}
+
+	manifest := getManifestOrLegacy(ID)
+	declared := map[string]ManifestOption{}
+	for _, option := range manifest.Options {
+		declared[option.Key] = option
+	}
+
+	installed := IsEdgeAppInstalled(ID)
+	current, _ := godotenv.Read(utils.GetPath(utils.EdgeAppsPath) + ID + optionsEnvFilename)
+
+	for key, value := range values {
+		option, found := declared[key]
+		if !found {
+			errors = append(errors, OptionError{key, "unknown option"})
+			continue
+		}
+
+		// The dashboard sends every option, so an unchanged value is fine
+		if option.InstallLocked && installed && value != current[key] {
+			errors = append(errors, OptionError{key, "can not be changed once the EdgeApp is installed"})
+			continue
+		}
+
+		message := validateOptionValue(option, value)
+		if message != "" {
+			errors = append(errors, OptionError{key, message})
+		}
+	}
+
+	sort.Slice(errors, func(i, j int) bool { return errors[i].Key < errors[j].Key })
+	return errors
+}
+
+// validateOptionValue : Returns why value is not valid for the option type, or "" if it is. Unknown types (from legacy templates) accept any value.
+func validateOptionValue(option ManifestOption, value string) string {
+
+	if value == "" {
+		return ""
+	}
+
+	if strings.ContainsAny(value, "\r\n") {
+		return "must be a single line"
+	}
+
+	switch option.Type {
+	case OPTION_INT:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return "must be an integer"
+		}
+	case OPTION_BOOL:
+		if value != "true" && value != "false" {
+			return "must be true or false"
+		}
+	case OPTION_URL:
+		parsed, err := url.Parse(value)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return "must be a URL, ex: https://example.com"
+		}
+	case OPTION_EMAIL:
+		address, err := mail.ParseAddress(value)
+		if err != nil || address.Address != value {
+			return "must be an email address"
+		}
+	case OPTION_PORT:
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return "must be a port number, between 1 and 65535"
+		}
+	case OPTION_ENUM:
+		for _, allowed := range option.Values {
+			if value == allowed {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(option.Values, ", ")
+	case OPTION_REGEX:
+		if option.matcher == nil || !option.matcher.MatchString(value) {
+			return "must match " + option.Pattern
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,128 @@
+// +build unit
+
+package edgeapps
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+const testValidationManifest = `
+options:
+  - key: ADMIN_USER
+    install_locked: true
+  - key: WORKERS
+    type: int
+  - key: DEBUG
+    type: bool
+  - key: PUBLIC_URL
+    type: url
+  - key: ADMIN_EMAIL
+    type: email
+  - key: HTTP_PORT
+    type: port
+  - key: THEME
+    type: enum
+    values: [light, dark]
+  - key: SUBDOMAIN
+    type: regex
+    pattern: '^[a-z0-9-]+$'
+  - key: REGION
+    type: regex
+    pattern: 'eu|us'
+`
+
+func TestValidateOptionValues(t *testing.T) {
+	dir, cleanup := setupTestEdgeApps(t)
+	defer cleanup()
+
+	writeTestFile(t, filepath.Join(dir, "app", "edgebox-compose.yml"), "services: {}\n")
+	writeTestFile(t, filepath.Join(dir, "app", "edgeapp.yml"), testValidationManifest)
+
+	valid := map[string]string{
+		"ADMIN_USER":  "admin",
+		"WORKERS":     "4",
+		"DEBUG":       "false",
+		"PUBLIC_URL":  "https://cloud.example.com",
+		"ADMIN_EMAIL": "admin@example.com",
+		"HTTP_PORT":   "8080",
+		"THEME":       "dark",
+		"SUBDOMAIN":   "my-cloud",
+		"REGION":      "eu",
+	}
+	if errors := ValidateOptionValues("app", valid); len(errors) != 0 {
+		t.Log("Expected valid values to be accepted, got", errors)
+		t.Fail()
+	}
+
+	invalid := map[string]string{
+		"WORKERS":     "four",
+		"DEBUG":       "yes",
+		"PUBLIC_URL":  "cloud.example.com",
+		"ADMIN_EMAIL": "Admin <admin@example.com>",
+		"HTTP_PORT":   "70000",
+		"THEME":       "blue",
+		"SUBDOMAIN":   "My Cloud",
+		"REGION":      "europe",
+		"ADMIN_USER":  "admin\nINJECTED=1",
+		"UNKNOWN":     "value",
+	}
+	errors := ValidateOptionValues("app", invalid)
+	if len(errors) != len(invalid) {
+		t.Fatal("Expected one error per invalid value, got", errors)
+	}
+	for i := 1; i < len(errors); i++ {
+		if errors[i-1].Key > errors[i].Key {
+			t.Log("Expected errors to be sorted by key, got", errors)
+			t.Fail()
+		}
+	}
+
+	if errors := ValidateOptionValues("app", map[string]string{"WORKERS": ""}); len(errors) != 0 {
+		t.Log("Expected empty values to be accepted, got", errors)
+		t.Fail()
+	}
+}
+
+func TestValidateInstallLockedOption(t *testing.T) {
+	dir, cleanup := setupTestEdgeApps(t)
+	defer cleanup()
+
+	writeTestFile(t, filepath.Join(dir, "app", "edgebox-compose.yml"), "services: {}\n")
+	writeTestFile(t, filepath.Join(dir, "app", "edgeapp.yml"), testValidationManifest)
+	writeTestFile(t, filepath.Join(dir, "app", "edgeapp.env"), "ADMIN_USER=admin\n")
+	writeTestFile(t, filepath.Join(dir, "app", ".run"), "")
+
+	if !IsEdgeAppInstalled("app") {
+		t.Fatal("Expected the EdgeApp to be installed")
+	}
+
+	if errors := ValidateOptionValues("app", map[string]string{"ADMIN_USER": "admin"}); len(errors) != 0 {
+		t.Log("Expected an unchanged install locked option to be accepted, got", errors)
+		t.Fail()
+	}
+
+	errors := ValidateOptionValues("app", map[string]string{"ADMIN_USER": "root"})
+	if len(errors) != 1 || errors[0].Key != "ADMIN_USER" {
+		t.Log("Expected a changed install locked option to be refused, got", errors)
+		t.Fail()
+	}
+}
+
+func TestExists(t *testing.T) {
+	dir, cleanup := setupTestEdgeApps(t)
+	defer cleanup()
+
+	writeTestFile(t, filepath.Join(dir, "app", "edgebox-compose.yml"), "services: {}\n")
+
+	if !Exists("app") {
+		t.Log("Expected app to exist")
+		t.Fail()
+	}
+	for _, ID := range []string{"", "missing", "../app", ".", "app/"} {
+		if Exists(ID) {
+			t.Log("Expected", ID, "not to exist")
+			t.Fail()
+		}
+	}
+}
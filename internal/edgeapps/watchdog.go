@@ -0,0 +1,254 @@
+package edgeapps
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/events"
+	"github.com/edgebox-iot/edgeboxctl/internal/options"
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+// WATCHDOG_MAX_RESTARTS : Restarts of a failing EdgeApp after which the watchdog gives up on it, until it works again or is started by hand
+const WATCHDOG_MAX_RESTARTS int = 5
+
+const watchdogOption string = "EDGEAPPS_WATCHDOG"
+const watchdogSource options.Source = "watchdog"
+
+// watchdogBackoff : Time an EdgeApp has to be failing before its first restart, doubled before every following one
+const watchdogBackoff time.Duration = time.Second * 30
+const watchdogMaxBackoff time.Duration = time.Minute * 30
+
+// watchdogStableTime : Time an EdgeApp restarted by the watchdog has to keep working to be considered recovered
+const watchdogStableTime time.Duration = time.Minute * 10
+
+// WatchdogState : Struct representing what the watchdog did about an EdgeApp
+type WatchdogState struct {
+	Restarts      int    `json:"restarts"`       // Since the EdgeApp last worked
+	TotalRestarts int    `json:"total_restarts"` // Since the EdgeApp was installed
+	FailingSince  int64  `json:"failing_since"`  // Unix time, 0 while working
+	LastRestart   int64  `json:"last_restart"`   // Unix time
+	LastReason    string `json:"last_reason"`
+	GaveUp        bool   `json:"gave_up"`
+}
+
+type watchdogAction int
+
+const (
+	watchdogWait watchdogAction = iota
+	watchdogRestart
+	watchdogGiveUp
+)
+
+var watchdogStates map[string]WatchdogState
+var watchdogStatesMutex sync.Mutex
+
+// loadWatchdogStates : Reads the states saved in the EDGEAPPS_WATCHDOG option the first time they are needed. Needs watchdogStatesMutex.
+func loadWatchdogStates() {
+	if watchdogStates != nil {
+		return
+	}
+	watchdogStates = map[string]WatchdogState{}
+	err := options.GetJSON(watchdogOption, &watchdogStates)
+	if err != nil || watchdogStates == nil {
+		watchdogStates = map[string]WatchdogState{}
+	}
+}
+
+// saveWatchdogStates : Writes the states to the EDGEAPPS_WATCHDOG option. Needs watchdogStatesMutex.
+func saveWatchdogStates() {
+	err := watchdogSource.SetJSON(watchdogOption, watchdogStates)
+	if err != nil {
+		log.Printf("Error saving watchdog state: %s", err)
+	}
+}
+
+// GetWatchdogState : Returns what the watchdog did about an EdgeApp
+func GetWatchdogState(ID string) WatchdogState {
+	watchdogStatesMutex.Lock()
+	defer watchdogStatesMutex.Unlock()
+
+	loadWatchdogStates()
+	return watchdogStates[ID]
+}
+
+// resetWatchdog : Gives an EdgeApp a fresh start with the watchdog, ex: when started by hand. Only total restarts are kept, unless forget is true.
+func resetWatchdog(ID string, forget bool) {
+	watchdogStatesMutex.Lock()
+	defer watchdogStatesMutex.Unlock()
+
+	loadWatchdogStates()
+	state, found := watchdogStates[ID]
+	if !found {
+		return
+	}
+
+	if forget {
+		delete(watchdogStates, ID)
+	} else {
+		watchdogStates[ID] = WatchdogState{TotalRestarts: state.TotalRestarts, LastRestart: state.LastRestart, LastReason: state.LastReason}
+	}
+	saveWatchdogStates()
+}
+
+// RunWatchdog : Restarts the services of installed EdgeApps in error or unhealthy, as of their last status check, waiting longer before every restart
+// of the same EdgeApp. Gives up after WATCHDOG_MAX_RESTARTS, publishing an event.
+func RunWatchdog() {
+	watchdogStatesMutex.Lock()
+	defer watchdogStatesMutex.Unlock()
+
+	loadWatchdogStates()
+	statuses := GetLastKnownStatuses()
+	changed := false
+	now := time.Now()
+
+	for _, ID := range sortedEdgeAppIDs() {
+		if !IsEdgeAppInstalled(ID) {
+			if _, found := watchdogStates[ID]; found {
+				delete(watchdogStates, ID)
+				changed = true
+			}
+			continue
+		}
+
+		status, known := statuses[ID]
+		if !known {
+			continue
+		}
+
+		state := watchdogStates[ID]
+		previous := state
+		action := state.observe(status, now)
+
+		switch action {
+		case watchdogRestart:
+			state.LastReason = restartFailingServices(ID, status)
+			state.Restarts++
+			state.TotalRestarts++
+			state.LastRestart = now.Unix()
+			events.Publish(events.APP_WATCHDOG_RESTARTED, map[string]interface{}{"id": ID, "restarts": state.Restarts, "reason": state.LastReason})
+		case watchdogGiveUp:
+			log.Printf("Watchdog gave up on %s after %d restarts, last reason: %s", ID, state.Restarts, state.LastReason)
+			events.Publish(events.APP_WATCHDOG_GAVE_UP, map[string]interface{}{"id": ID, "restarts": state.Restarts, "reason": state.LastReason})
+		}
+
+		if previous.FailingSince != 0 && state.FailingSince == 0 && previous.Restarts > 0 {
+			log.Printf("EdgeApp %s recovered after %d watchdog restarts", ID, previous.Restarts)
+		}
+
+		if state != previous {
+			watchdogStates[ID] = state
+			changed = true
+		}
+	}
+
+	if changed {
+		saveWatchdogStates()
+	}
+}
+
+// observe : Updates the state with the latest status of the EdgeApp, returning what the watchdog should do about it
+func (s *WatchdogState) observe(status EdgeAppStatus, now time.Time) watchdogAction {
+
+	switch status.Description {
+	case "error", "unhealthy":
+	case "on", "degraded":
+		if s.FailingSince != 0 && (s.Restarts == 0 || now.Sub(time.Unix(s.LastRestart, 0)) >= watchdogStableTime) {
+			s.FailingSince = 0
+			s.Restarts = 0
+			s.GaveUp = false
+		}
+		return watchdogWait
+	default:
+		// Stopped or not installed, nothing to recover
+		s.FailingSince = 0
+		s.Restarts = 0
+		s.GaveUp = false
+		return watchdogWait
+	}
+
+	if s.FailingSince == 0 {
+		s.FailingSince = now.Unix()
+	}
+	if s.GaveUp {
+		return watchdogWait
+	}
+
+	since := time.Unix(s.FailingSince, 0)
+	if s.Restarts > 0 {
+		since = time.Unix(s.LastRestart, 0)
+	}
+	if now.Sub(since) < restartBackoff(s.Restarts) {
+		return watchdogWait
+	}
+
+	if s.Restarts >= WATCHDOG_MAX_RESTARTS {
+		s.GaveUp = true
+		return watchdogGiveUp
+	}
+
+	return watchdogRestart
+}
+
+// restartBackoff : Time to wait after restarts restarts, before the next one
+func restartBackoff(restarts int) time.Duration {
+	backoff := watchdogBackoff
+	for i := 0; i < restarts && backoff < watchdogMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > watchdogMaxBackoff {
+		backoff = watchdogMaxBackoff
+	}
+	return backoff
+}
+
+// restartFailingServices : Restarts the services of an EdgeApp that are not running (error) or failing their health checks (unhealthy),
+// or all of them when none can be told apart. Returns the reason of the restart.
+func restartFailingServices(ID string, status EdgeAppStatus) string {
+
+	failing := map[string]bool{}
+	var reasons []string
+
+	if status.Description == "unhealthy" {
+		for _, result := range GetEdgeAppHealth(ID) {
+			if !result.Healthy {
+				failing[result.Service] = true
+				reasons = append(reasons, result.Service+" "+result.Type+" health check: "+result.Message)
+			}
+		}
+	} else {
+		for _, service := range GetLastKnownServices()[ID] {
+			if !service.IsRunning {
+				failing[service.ID] = true
+				reasons = append(reasons, service.ID+" not running")
+			}
+		}
+	}
+
+	services := []string{}
+	for service := range failing {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	if len(services) == 0 {
+		for _, service := range GetEdgeAppServices(ID) {
+			services = append(services, service.ID)
+		}
+		reasons = append(reasons, "EdgeApp "+status.Description)
+	}
+
+	reason := strings.Join(reasons, ", ")
+	log.Printf("Watchdog restarting %s of %s: %s", strings.Join(services, ", "), ID, reason)
+
+	wsPath := utils.GetPath(utils.WsPath)
+	cmdArgs := append([]string{"compose", "-f", wsPath + "/docker-compose.yml", "restart"}, services...)
+	utils.Exec(wsPath, "docker", cmdArgs)
+
+	// Judged again by fresh checks, not by the ones that caused the restart
+	clearEdgeAppHealth(ID)
+
+	return reason
+}
@@ -0,0 +1,82 @@
+// +build unit
+
+package edgeapps
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRestartBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		0:  watchdogBackoff,
+		1:  watchdogBackoff * 2,
+		3:  watchdogBackoff * 8,
+		20: watchdogMaxBackoff,
+	}
+
+	for restarts, expected := range cases {
+		if backoff := restartBackoff(restarts); backoff != expected {
+			t.Errorf("Expected a backoff of %s after %d restarts, got %s", expected, restarts, backoff)
+		}
+	}
+}
+
+func TestWatchdogObserve(t *testing.T) {
+	failing := EdgeAppStatus{2, "error"}
+	on := EdgeAppStatus{1, "on"}
+	now := time.Unix(1700000000, 0)
+
+	state := WatchdogState{}
+	if state.observe(failing, now) != watchdogWait || state.FailingSince != now.Unix() {
+		t.Fatal("Expected a newly failing EdgeApp to be given time to settle, got", state)
+	}
+
+	restarts := 0
+	for restarts < WATCHDOG_MAX_RESTARTS {
+		now = now.Add(restartBackoff(restarts) - time.Second)
+		if state.observe(failing, now) != watchdogWait {
+			t.Fatal("Expected to wait for the backoff before restart", restarts+1)
+		}
+		now = now.Add(time.Second)
+		if state.observe(failing, now) != watchdogRestart {
+			t.Fatal("Expected restart", restarts+1, "once the backoff elapsed")
+		}
+		restarts++
+		state.Restarts = restarts
+		state.LastRestart = now.Unix()
+	}
+
+	now = now.Add(restartBackoff(restarts))
+	if state.observe(failing, now) != watchdogGiveUp || !state.GaveUp {
+		t.Fatal("Expected to give up after", WATCHDOG_MAX_RESTARTS, "restarts, got", state)
+	}
+	now = now.Add(time.Hour)
+	if state.observe(EdgeAppStatus{4, "unhealthy"}, now) != watchdogWait {
+		t.Fatal("Expected no more restarts once given up")
+	}
+
+	if state.observe(on, now); state.GaveUp || state.Restarts != 0 || state.FailingSince != 0 {
+		t.Fatal("Expected an EdgeApp working again to be recovered, got", state)
+	}
+}
+
+func TestWatchdogObserveStableTime(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	state := WatchdogState{Restarts: 2, FailingSince: now.Add(-time.Hour).Unix(), LastRestart: now.Unix()}
+
+	state.observe(EdgeAppStatus{1, "on"}, now.Add(time.Minute))
+	if state.Restarts != 2 {
+		t.Fatal("Expected restarts to be kept until the EdgeApp works for a while, got", state)
+	}
+
+	state.observe(EdgeAppStatus{1, "on"}, now.Add(watchdogStableTime))
+	if state.Restarts != 0 || state.FailingSince != 0 {
+		t.Fatal("Expected the EdgeApp to be recovered after working for a while, got", state)
+	}
+
+	state = WatchdogState{Restarts: 1, FailingSince: now.Unix(), GaveUp: true}
+	if state.observe(EdgeAppStatus{0, "off"}, now) != watchdogWait || state.Restarts != 0 || state.GaveUp {
+		t.Fatal("Expected a stopped EdgeApp to be left alone and reset, got", state)
+	}
+}
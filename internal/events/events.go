@@ -0,0 +1,138 @@
+package events
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event : Struct representing something that happened inside edgeboxctl, delivered to every subscriber
+type Event struct {
+	Type string      `json:"type"`
+	Time int64       `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// Event types published by edgeboxctl. Subscribers can match them with patterns like "task.*" (see Match).
+const (
+	TASK_CREATED           string = "task.created"
+	TASK_STARTED           string = "task.started"
+	TASK_FINISHED          string = "task.finished"
+	TASK_FAILED            string = "task.failed"
+	APP_STATUS_CHANGED     string = "app.status_changed"
+	APP_UPGRADED           string = "app.upgraded"
+	APP_ROLLED_BACK        string = "app.rolled_back"
+	APP_WATCHDOG_RESTARTED string = "app.watchdog_restarted"
+	APP_WATCHDOG_GAVE_UP   string = "app.watchdog_gave_up"
+	BACKUP_STARTED         string = "backup.started"
+	BACKUP_PROGRESS        string = "backup.progress"
+	BACKUP_FINISHED        string = "backup.finished"
+	BACKUP_FAILED          string = "backup.failed"
+	TUNNEL_STATUS_CHANGED  string = "tunnel.status_changed"
+)
+
+const subscriberBufferSize int = 64
+
+var subscribers = map[chan Event]bool{}
+var subscribersMutex sync.RWMutex
+
+// Publish : Delivers an event to every subscriber. Never blocks: slow subscribers with a full buffer miss the event.
+func Publish(eventType string, data interface{}) {
+
+	event := Event{
+		Type: eventType,
+		Time: time.Now().Unix(),
+		Data: data,
+	}
+
+	subscribersMutex.RLock()
+	defer subscribersMutex.RUnlock()
+
+	for subscriber := range subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			log.Printf("Event subscriber is not keeping up, dropping %s event", eventType)
+		}
+	}
+}
+
+// Subscribe : Returns a channel receiving every published event, and a function to call when no longer interested
+func Subscribe() (<-chan Event, func()) {
+
+	subscriber := make(chan Event, subscriberBufferSize)
+
+	subscribersMutex.Lock()
+	subscribers[subscriber] = true
+	subscribersMutex.Unlock()
+
+	unsubscribe := func() {
+		subscribersMutex.Lock()
+		if subscribers[subscriber] {
+			delete(subscribers, subscriber)
+			close(subscriber)
+		}
+		subscribersMutex.Unlock()
+	}
+
+	return subscriber, unsubscribe
+}
+
+// Match : Returns true if eventType matches the pattern. Patterns are an exact type, "*" for everything, or a prefix ending in "*" like "backup.*"
+func Match(pattern string, eventType string) bool {
+	if pattern == "*" || pattern == eventType {
+		return true
+	}
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(eventType, strings.TrimSuffix(pattern, "*"))
+	}
+	return false
+}
+
+// MatchAny : Returns true if eventType matches at least one of the patterns. An empty list of patterns matches everything.
+func MatchAny(patterns []string, eventType string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if Match(strings.TrimSpace(pattern), eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactedFields : Fields of event data never sent outside edgeboxctl, as they can carry credentials (ex: task args and results)
+var redactedFields = map[string]bool{
+	"args":     true,
+	"result":   true,
+	"password": true,
+	"secret":   true,
+	"token":    true,
+}
+
+// Redact : Returns the event without the fields of its data that can carry credentials. Meant for events leaving edgeboxctl (stream, webhooks).
+func Redact(event Event) Event {
+
+	switch data := event.Data.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(data))
+		for key, value := range data {
+			if !redactedFields[strings.ToLower(key)] {
+				redacted[key] = value
+			}
+		}
+		event.Data = redacted
+	case map[string]string:
+		redacted := make(map[string]string, len(data))
+		for key, value := range data {
+			if !redactedFields[strings.ToLower(key)] {
+				redacted[key] = value
+			}
+		}
+		event.Data = redacted
+	}
+
+	return event
+}
@@ -0,0 +1,93 @@
+// +build unit
+
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPublishSubscribe(t *testing.T) {
+	subscription, unsubscribe := Subscribe()
+	defer unsubscribe()
+
+	Publish(TASK_STARTED, map[string]int{"id": 1})
+
+	select {
+	case event := <-subscription:
+		if event.Type != TASK_STARTED {
+			t.Log("Expected event type", TASK_STARTED, "but got", event.Type)
+			t.Fail()
+		}
+	case <-time.After(time.Second):
+		t.Log("Expected to receive the published event")
+		t.Fail()
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	subscription, unsubscribe := Subscribe()
+	unsubscribe()
+	unsubscribe() // Calling it twice should be harmless
+
+	Publish(TASK_STARTED, nil)
+
+	if _, open := <-subscription; open {
+		t.Log("Expected subscription channel to be closed")
+		t.Fail()
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern   string
+		eventType string
+		expected  bool
+	}{
+		{"*", APP_STATUS_CHANGED, true},
+		{"backup.*", BACKUP_FAILED, true},
+		{"backup.*", TASK_FAILED, false},
+		{TASK_FINISHED, TASK_FINISHED, true},
+		{TASK_FINISHED, TASK_FAILED, false},
+	}
+
+	for _, c := range cases {
+		if Match(c.pattern, c.eventType) != c.expected {
+			t.Log("Expected Match(", c.pattern, ",", c.eventType, ") to be", c.expected)
+			t.Fail()
+		}
+	}
+
+	if !MatchAny(nil, TASK_CREATED) {
+		t.Log("Expected an empty list of patterns to match everything")
+		t.Fail()
+	}
+}
+
+func TestRedact(t *testing.T) {
+	event := Event{Type: TASK_FINISHED, Data: map[string]interface{}{
+		"id":     1,
+		"task":   "setup_backups",
+		"args":   `{"repository_password": "hunter22"}`,
+		"result": "ok",
+	}}
+
+	redacted := Redact(event).Data.(map[string]interface{})
+	if _, found := redacted["args"]; found {
+		t.Error("Expected args to be left out, got", redacted)
+	}
+	if _, found := redacted["result"]; found {
+		t.Error("Expected result to be left out, got", redacted)
+	}
+	if redacted["task"] != "setup_backups" || redacted["id"] != 1 {
+		t.Error("Expected other fields to be kept, got", redacted)
+	}
+	if _, found := event.Data.(map[string]interface{})["args"]; !found {
+		t.Error("Expected the original event to be left untouched")
+	}
+
+	stringData := Redact(Event{Data: map[string]string{"message": "done", "Secret": "s"}}).Data.(map[string]string)
+	if _, found := stringData["Secret"]; found || stringData["message"] != "done" {
+		t.Error("Expected secrets of string data to be left out, got", stringData)
+	}
+}
@@ -0,0 +1,219 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/config"
+	"github.com/edgebox-iot/edgeboxctl/internal/options"
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+	"github.com/shirou/gopsutil/disk"
+)
+
+// Check : Struct representing the result of checking a single dependency
+type Check struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+}
+
+// Report : Struct representing the readiness of edgeboxctl to execute tasks, and the checks it was evaluated from
+type Report struct {
+	Ready  bool    `json:"ready"`
+	Time   int64   `json:"time"`
+	Checks []Check `json:"checks"`
+}
+
+// checker : A named dependency check. Returns nil when the dependency is usable.
+type checker struct {
+	name  string
+	check func() error
+}
+
+const readinessOption string = "SYSTEM_READINESS"
+const readinessSource options.Source = "readiness"
+const reportMaxAge time.Duration = time.Second * 30
+const dockerTimeout time.Duration = time.Second * 10
+const minWaitTime time.Duration = time.Second * 2
+const maxWaitTime time.Duration = time.Second * 60
+
+// DISK_FULL_PERCENT : Usage of the instance root filesystem from which the system is no longer considered ready
+const DISK_FULL_PERCENT float64 = 95
+
+// requiredTables : Tables of the api database edgeboxctl cannot work without
+var requiredTables = []string{"task", "option"}
+
+var checkers = []checker{
+	{"database", checkDatabase},
+	{"docker", checkDocker},
+	{"ws", checkWs},
+	{"disk", checkDisk},
+}
+
+var lastReport Report
+var lastReportMutex sync.Mutex
+
+// Evaluate : Runs every check, remembering and publishing (in the SYSTEM_READINESS option) the resulting report
+func Evaluate() Report {
+
+	report := Report{Ready: true, Time: time.Now().Unix()}
+	databaseOK := false
+
+	for _, c := range checkers {
+		result := Check{Name: c.name, OK: true, Message: "ok"}
+		err := c.check()
+		if err != nil {
+			result.OK = false
+			result.Message = err.Error()
+			report.Ready = false
+		}
+		if c.name == "database" {
+			databaseOK = result.OK
+		}
+		report.Checks = append(report.Checks, result)
+	}
+
+	lastReportMutex.Lock()
+	lastReport = report
+	lastReportMutex.Unlock()
+
+	// The dashboard reads readiness from the options table, which is only possible to write when the database is usable
+	if databaseOK {
+		readinessSource.SetJSON(readinessOption, report)
+	}
+
+	return report
+}
+
+// GetReport : Returns the last report, evaluating it again if it is older than 30 seconds
+func GetReport() Report {
+	lastReportMutex.Lock()
+	report := lastReport
+	lastReportMutex.Unlock()
+
+	if time.Since(time.Unix(report.Time, 0)) > reportMaxAge {
+		return Evaluate()
+	}
+
+	return report
+}
+
+// IsReady : Returns true if the system is ready to execute tasks, as of a report not older than 30 seconds
+func IsReady() bool {
+	return GetReport().Ready
+}
+
+// WaitUntilReady : Blocks until every check passes, checking again with an increasing delay (up to 60 seconds)
+func WaitUntilReady() {
+
+	waitTime := minWaitTime
+
+	for {
+		report := Evaluate()
+		if report.Ready {
+			return
+		}
+
+		for _, c := range report.Checks {
+			if !c.OK {
+				log.Printf("System not ready, %s check failed: %s", c.Name, c.Message)
+			}
+		}
+		log.Printf("Next readiness check in %s", waitTime)
+		time.Sleep(waitTime)
+
+		waitTime *= 2
+		if waitTime > maxWaitTime {
+			waitTime = maxWaitTime
+		}
+	}
+}
+
+// checkDatabase : The api database exists, can be queried and has the tables edgeboxctl uses
+func checkDatabase() error {
+
+	database, err := utils.GetSQLiteDbLocation()
+	if err != nil {
+		return fmt.Errorf("database location unknown: %s", err)
+	}
+	if database == "" {
+		return errors.New("database location not configured")
+	}
+
+	// Checked first for a clearer message than the one of a failed open
+	_, err = os.Stat(database)
+	if err != nil {
+		return fmt.Errorf("database not found: %s", err)
+	}
+
+	db, err := utils.GetDatabase()
+	if err != nil {
+		return err
+	}
+
+	var missingTables []string
+	for _, table := range requiredTables {
+		var name string
+		err = db.QueryRow("SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?;", table).Scan(&name)
+		if err == sql.ErrNoRows {
+			missingTables = append(missingTables, table)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if len(missingTables) > 0 {
+		return fmt.Errorf("database schema not ready, missing tables: %s", strings.Join(missingTables, ", "))
+	}
+
+	return nil
+}
+
+// checkDocker : The docker daemon answers in a reasonable time
+func checkDocker() error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), dockerTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "docker", "info", "--format", "{{.ServerVersion}}").CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.New("docker daemon not responding")
+	}
+	if err != nil {
+		return fmt.Errorf("docker daemon not available: %s", strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// checkWs : The ws component was built (Only after "edgebox --build" is ran at least once via SSH, or if built for distribution)
+func checkWs() error {
+	_, err := os.Stat(utils.GetPath(utils.WsPath) + ".ready")
+	if os.IsNotExist(err) {
+		return errors.New("ws not built yet")
+	}
+	return err
+}
+
+// checkDisk : The filesystem holding the instance root has free space left
+func checkDisk() error {
+
+	usage, err := disk.Usage(config.Get().Instance.Root)
+	if err != nil {
+		return err
+	}
+
+	if usage.UsedPercent >= DISK_FULL_PERCENT {
+		return fmt.Errorf("disk almost full (%.1f%% used)", usage.UsedPercent)
+	}
+
+	return nil
+}
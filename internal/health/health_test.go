@@ -0,0 +1,74 @@
+// +build unit
+
+package health
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+func TestCheckDatabase(t *testing.T) {
+	database, cleanup := utils.SetupTestConfig(t)
+	defer cleanup()
+
+	err := checkDatabase()
+	if err == nil {
+		t.Log("Expected an error when the database does not exist")
+		t.Fail()
+	}
+	if _, statErr := os.Stat(database); !os.IsNotExist(statErr) {
+		t.Log("Expected the check not to create the database")
+		t.Fail()
+	}
+
+	db, _ := sql.Open("sqlite3", database)
+	defer db.Close()
+	db.Exec(utils.TEST_TASK_TABLE)
+
+	err = checkDatabase()
+	if err == nil {
+		t.Log("Expected an error when the option table is missing")
+		t.Fail()
+	}
+
+	db.Exec(utils.TEST_OPTION_TABLE)
+
+	err = checkDatabase()
+	if err != nil {
+		t.Log("Expected the database check to pass, got", err)
+		t.Fail()
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	_, cleanup := utils.SetupTestConfig(t)
+	defer cleanup()
+
+	defaultCheckers := checkers
+	defer func() { checkers = defaultCheckers }()
+
+	checkers = []checker{
+		{"passing", func() error { return nil }},
+		{"failing", func() error { return errors.New("not yet") }},
+	}
+
+	report := Evaluate()
+	if report.Ready {
+		t.Log("Expected the report not to be ready with a failing check")
+		t.Fail()
+	}
+	if len(report.Checks) != 2 || !report.Checks[0].OK || report.Checks[1].OK || report.Checks[1].Message != "not yet" {
+		t.Log("Unexpected checks", report.Checks)
+		t.Fail()
+	}
+
+	checkers = checkers[:1]
+	if !Evaluate().Ready || !IsReady() {
+		t.Log("Expected the report to be ready when every check passes")
+		t.Fail()
+	}
+}
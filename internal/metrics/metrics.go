@@ -0,0 +1,152 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric types, as understood by Prometheus
+const (
+	COUNTER string = "counter"
+	GAUGE   string = "gauge"
+	SUMMARY string = "summary"
+)
+
+// Labels : Label names and values identifying a single sample
+type Labels map[string]string
+
+// Sample : A single value of a metric. Name can carry a suffix of the family name (ex: _sum and _count for summaries).
+type Sample struct {
+	Name   string
+	Labels Labels
+	Value  float64
+}
+
+// Family : A metric with its help text, type and every sample
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// accumulator : Samples accumulated in-process (counters and summaries), keyed by sample name and labels
+type accumulator struct {
+	family  Family
+	samples map[string]*Sample
+}
+
+var accumulators = map[string]*accumulator{}
+var accumulatorsMutex sync.Mutex
+
+// Describe : Registers a metric accumulated in-process, so it is exposed (with its help text) even before the first Add
+func Describe(name string, help string, metricType string) {
+	accumulatorsMutex.Lock()
+	defer accumulatorsMutex.Unlock()
+
+	if _, exists := accumulators[name]; !exists {
+		accumulators[name] = &accumulator{
+			family:  Family{Name: name, Help: help, Type: metricType},
+			samples: map[string]*Sample{},
+		}
+	}
+}
+
+// Add : Adds value to the sample sampleName{labels} of the family name. The family must be registered with Describe.
+func Add(name string, sampleName string, labels Labels, value float64) {
+	accumulatorsMutex.Lock()
+	defer accumulatorsMutex.Unlock()
+
+	metric, exists := accumulators[name]
+	if !exists {
+		return
+	}
+
+	key := sampleName + formatLabels(labels)
+	sample, exists := metric.samples[key]
+	if !exists {
+		sample = &Sample{Name: sampleName, Labels: labels}
+		metric.samples[key] = sample
+	}
+	sample.Value += value
+}
+
+// Collect : Returns a copy of every family accumulated in-process
+func Collect() []Family {
+	accumulatorsMutex.Lock()
+	defer accumulatorsMutex.Unlock()
+
+	var families []Family
+	for _, metric := range accumulators {
+		family := metric.family
+		family.Samples = nil
+		for _, sample := range metric.samples {
+			family.Samples = append(family.Samples, *sample)
+		}
+		sort.Slice(family.Samples, func(i, j int) bool {
+			return family.Samples[i].Name+formatLabels(family.Samples[i].Labels) < family.Samples[j].Name+formatLabels(family.Samples[j].Labels)
+		})
+		families = append(families, family)
+	}
+
+	return families
+}
+
+// Write : Writes the families in the Prometheus text exposition format, sorted by name
+func Write(w io.Writer, families []Family) error {
+
+	sort.SliceStable(families, func(i, j int) bool {
+		return families[i].Name < families[j].Name
+	})
+
+	buffered := bufio.NewWriter(w)
+	for _, family := range families {
+		fmt.Fprintf(buffered, "# HELP %s %s\n", family.Name, escapeHelp(family.Help))
+		fmt.Fprintf(buffered, "# TYPE %s %s\n", family.Name, family.Type)
+		for _, sample := range family.Samples {
+			name := sample.Name
+			if name == "" {
+				name = family.Name
+			}
+			fmt.Fprintf(buffered, "%s%s %s\n", name, formatLabels(sample.Labels), strconv.FormatFloat(sample.Value, 'g', -1, 64))
+		}
+	}
+
+	return buffered.Flush()
+}
+
+// formatLabels : Returns labels as {name="value",...}, sorted by name, or "" when there are none
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(labels))
+	for _, name := range names {
+		pairs = append(pairs, name+"=\""+escapeLabelValue(labels[name])+"\"")
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelValueEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+var helpEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
@@ -0,0 +1,82 @@
+// +build unit
+
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAddAndCollect(t *testing.T) {
+	Describe("test_tasks_total", "Tasks executed.", COUNTER)
+
+	labels := Labels{"task": "start_edgeapp", "status": "finished"}
+	Add("test_tasks_total", "test_tasks_total", labels, 1)
+	Add("test_tasks_total", "test_tasks_total", Labels{"status": "finished", "task": "start_edgeapp"}, 2)
+	Add("test_undescribed_total", "test_undescribed_total", labels, 1)
+
+	found := false
+	for _, family := range Collect() {
+		if family.Name == "test_undescribed_total" {
+			t.Log("Expected metrics not registered with Describe to be ignored")
+			t.Fail()
+		}
+		if family.Name != "test_tasks_total" {
+			continue
+		}
+		found = true
+		if len(family.Samples) != 1 || family.Samples[0].Value != 3 {
+			t.Log("Expected a single sample with value 3, got", family.Samples)
+			t.Fail()
+		}
+	}
+
+	if !found {
+		t.Log("Expected test_tasks_total to be collected")
+		t.Fail()
+	}
+}
+
+func TestWrite(t *testing.T) {
+	families := []Family{
+		{
+			Name: "test_storage_free_bytes",
+			Help: "Free space.",
+			Type: GAUGE,
+			Samples: []Sample{
+				{Labels: Labels{"mountpoint": "/", "device": "sda"}, Value: 1024},
+				{Labels: Labels{"device": "weird\"name\\"}, Value: 0.5},
+			},
+		},
+		{
+			Name: "test_duration_seconds",
+			Help: "Durations.",
+			Type: SUMMARY,
+			Samples: []Sample{
+				{Name: "test_duration_seconds_sum", Value: 12.5},
+				{Name: "test_duration_seconds_count", Value: 2},
+			},
+		},
+	}
+
+	var output bytes.Buffer
+	err := Write(&output, families)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds summary
+test_duration_seconds_sum 12.5
+test_duration_seconds_count 2
+# HELP test_storage_free_bytes Free space.
+# TYPE test_storage_free_bytes gauge
+test_storage_free_bytes{device="sda",mountpoint="/"} 1024
+test_storage_free_bytes{device="weird\"name\\"} 0.5
+`
+
+	if output.String() != expected {
+		t.Log("Expected:\n" + expected + "but got:\n" + output.String())
+		t.Fail()
+	}
+}
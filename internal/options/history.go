@@ -0,0 +1,73 @@
+package options
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+const redactedValue string = "********"
+
+func init() {
+	utils.SetOptionHistoryFilter(historyFilter)
+
+	// Options owned by the dashboard are never written by edgeboxctl, their changes are recorded when the watcher notices them
+	for _, option := range registry {
+		if option.Owner == OWNER_DASHBOARD {
+			OnChange(option.Key, recordDashboardChange)
+		}
+	}
+}
+
+// historyFilter : Keeps the changes of registered options in the option history, except for volatile ones. Values of secret and redacted options are redacted.
+func historyFilter(name string, oldValue string, newValue string) (bool, string, string) {
+
+	option, found := Lookup(name)
+	if !found || (!option.Secret && !option.Redacted) {
+		return !option.Volatile && oldValue != newValue, oldValue, newValue
+	}
+
+	// Encrypting the same secret twice gives different values, so they are compared decrypted
+	oldSecret, oldErr := decodeSecret(option, oldValue)
+	newSecret, newErr := decodeSecret(option, newValue)
+	if oldErr == nil && newErr == nil && oldSecret == newSecret {
+		return false, "", ""
+	}
+
+	return true, redact(oldValue), redact(newValue)
+}
+
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// recordDashboardChange : Records a change of a dashboard option in the option history
+func recordDashboardChange(change Change) {
+	store, err := utils.GetOptionStore()
+	if err == nil {
+		err = store.RecordChange(change.Key, change.OldValue, change.NewValue, OWNER_DASHBOARD)
+	}
+	if err != nil {
+		log.Printf("Error recording change of option %s: %s", change.Key, err)
+	}
+}
+
+// History : Returns the most recent changes of a registered option (or of every option, if key is empty), newest first
+func History(key string, limit int) ([]utils.OptionChange, error) {
+
+	if key != "" {
+		if _, found := Lookup(key); !found {
+			return []utils.OptionChange{}, fmt.Errorf("%w %s", ErrUnknownOption, key)
+		}
+	}
+
+	store, err := utils.GetOptionStore()
+	if err != nil {
+		return []utils.OptionChange{}, err
+	}
+	return store.History(key, limit)
+}
@@ -0,0 +1,339 @@
+package options
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+// Type : How the value of an option is encoded in the options table
+type Type string
+
+// Option types. Values are always stored as strings, the type tells how to read and write them.
+const (
+	STRING    Type = "string"
+	BOOL      Type = "bool"      // Encoded with the TrueValue and FalseValue of the option
+	INT       Type = "int"       // Base 10 integer
+	TIMESTAMP Type = "timestamp" // Unix timestamp, in seconds
+	ENUM      Type = "enum"      // One of Values
+	JSON      Type = "json"      // A JSON document, see Schema
+)
+
+// Owners of options: the component that writes them. Other components only read them.
+const (
+	OWNER_EDGEBOXCTL string = "edgeboxctl"
+	OWNER_DASHBOARD  string = "dashboard"
+	OWNER_CLOUD      string = "cloud" // Provisioned once from the cloud env file, see system.SetupCloudOptions
+)
+
+// Source : Who writes options, as recorded in the option history (ex: "task:setup_tunnel#12", "schedule"). Options are written with its methods,
+// so concurrent writers (tasks, schedules, background checks) are never mistaken for one another.
+type Source string
+
+// SOURCE_EDGEBOXCTL : Source of the options edgeboxctl writes on its own, outside of a task, schedule or background check
+const SOURCE_EDGEBOXCTL Source = Source(utils.DEFAULT_OPTION_SOURCE)
+
+// Option : Struct describing an option key of the options table
+type Option struct {
+	Key         string
+	Type        Type
+	Owner       string
+	Default     string
+	Secret      bool
+	Description string
+	Values      []string // Allowed values of ENUM options
+	TrueValue   string   // Stored value of true for BOOL options, defaults to "true"
+	FalseValue  string   // Stored value of false for BOOL options, defaults to "false"
+	Schema      string   // Short description of the JSON document of JSON options, for the reference
+	Volatile    bool     // Changes on every refresh, so changes are not kept in the option history
+	Redacted    bool     // Stored in plaintext, as other components read it, but its values are redacted in the option history
+}
+
+// ErrUnknownOption : Returned when reading or writing an option that is not registered
+var ErrUnknownOption = errors.New("unknown option")
+
+// Lookup : Returns the registered option with the given key
+func Lookup(key string) (Option, bool) {
+	for _, option := range registry {
+		if option.Key == key {
+			return option, true
+		}
+	}
+	return Option{}, false
+}
+
+// All : Returns every registered option, in registration order
+func All() []Option {
+	return append([]Option(nil), registry...)
+}
+
+func lookupTyped(key string, optionType Type) (Option, error) {
+	option, found := Lookup(key)
+	if !found {
+		return option, fmt.Errorf("%w %s", ErrUnknownOption, key)
+	}
+	if option.Type != optionType {
+		return option, fmt.Errorf("option %s is of type %s, not %s", key, option.Type, optionType)
+	}
+	return option, nil
+}
+
+func (o Option) trueValue() string {
+	if o.TrueValue == "" {
+		return "true"
+	}
+	return o.TrueValue
+}
+
+func (o Option) falseValue() string {
+	if o.FalseValue == "" {
+		return "false"
+	}
+	return o.FalseValue
+}
+
+// Validate : Returns an error if value is not a valid stored value for the option
+func (o Option) Validate(value string) error {
+
+	// Options can always be emptied, which means "not set" for the dashboard
+	if value == "" {
+		return nil
+	}
+
+	switch o.Type {
+	case BOOL:
+		if value != o.trueValue() && value != o.falseValue() {
+			return fmt.Errorf("option %s must be %s or %s (got %s)", o.Key, o.trueValue(), o.falseValue(), value)
+		}
+	case INT, TIMESTAMP:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("option %s must be an integer (got %s)", o.Key, value)
+		}
+	case ENUM:
+		for _, allowed := range o.Values {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("option %s must be one of %s (got %s)", o.Key, strings.Join(o.Values, ", "), value)
+	case JSON:
+		if !json.Valid([]byte(value)) {
+			return fmt.Errorf("option %s must be valid JSON", o.Key)
+		}
+	}
+
+	return nil
+}
+
+// read : Returns the stored value of a registered option, or its default when it is not set
+func read(option Option) (string, error) {
+	store, err := utils.GetOptionStore()
+	if err != nil {
+		return "", err
+	}
+
+	value, err := store.Get(option.Key)
+	if err == utils.ErrOptionNotFound {
+		return option.Default, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return decodeSecret(option, value)
+}
+
+// write : Validates and writes the stored value of a registered option on behalf of source, encrypting secrets. Errors are also logged, as most writers are fire and forget.
+func write(option Option, value string, source Source) error {
+	err := option.Validate(value)
+	if err == nil {
+		value, err = encodeSecret(option, value)
+	}
+	if err == nil {
+		var store *utils.OptionStore
+		store, err = utils.GetOptionStore()
+		if err == nil {
+			err = store.Set(option.Key, value, string(source))
+		}
+	}
+
+	if err != nil {
+		log.Printf("Error writing option %s: %s", option.Key, err)
+	}
+	return err
+}
+
+// GetString : Returns the value of a STRING option
+func GetString(key string) (string, error) {
+	option, err := lookupTyped(key, STRING)
+	if err != nil {
+		return "", err
+	}
+	return read(option)
+}
+
+// SetString : Writes the value of a STRING option
+func (source Source) SetString(key string, value string) error {
+	option, err := lookupTyped(key, STRING)
+	if err != nil {
+		return err
+	}
+	return write(option, value, source)
+}
+
+// GetEnum : Returns the value of an ENUM option
+func GetEnum(key string) (string, error) {
+	option, err := lookupTyped(key, ENUM)
+	if err != nil {
+		return "", err
+	}
+	return read(option)
+}
+
+// SetEnum : Writes the value of an ENUM option, which must be one of its allowed values
+func (source Source) SetEnum(key string, value string) error {
+	option, err := lookupTyped(key, ENUM)
+	if err != nil {
+		return err
+	}
+	return write(option, value, source)
+}
+
+// GetBool : Returns the value of a BOOL option. Unset options are false unless they default to true.
+func GetBool(key string) (bool, error) {
+	option, err := lookupTyped(key, BOOL)
+	if err != nil {
+		return false, err
+	}
+	value, err := read(option)
+	return value == option.trueValue(), err
+}
+
+// SetBool : Writes the value of a BOOL option, in the encoding the option was always stored with
+func (source Source) SetBool(key string, value bool) error {
+	option, err := lookupTyped(key, BOOL)
+	if err != nil {
+		return err
+	}
+	if value {
+		return write(option, option.trueValue(), source)
+	}
+	return write(option, option.falseValue(), source)
+}
+
+// GetInt : Returns the value of an INT option, 0 if it is not set
+func GetInt(key string) (int64, error) {
+	option, err := lookupTyped(key, INT)
+	if err != nil {
+		return 0, err
+	}
+	value, err := read(option)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// SetInt : Writes the value of an INT option
+func (source Source) SetInt(key string, value int64) error {
+	option, err := lookupTyped(key, INT)
+	if err != nil {
+		return err
+	}
+	return write(option, strconv.FormatInt(value, 10), source)
+}
+
+// GetTime : Returns the value of a TIMESTAMP option, the zero time if it is not set
+func GetTime(key string) (time.Time, error) {
+	option, err := lookupTyped(key, TIMESTAMP)
+	if err != nil {
+		return time.Time{}, err
+	}
+	value, err := read(option)
+	if err != nil || value == "" {
+		return time.Time{}, err
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+// SetTime : Writes the value of a TIMESTAMP option
+func (source Source) SetTime(key string, value time.Time) error {
+	option, err := lookupTyped(key, TIMESTAMP)
+	if err != nil {
+		return err
+	}
+	return write(option, strconv.FormatInt(value.Unix(), 10), source)
+}
+
+// GetJSON : Decodes the value of a JSON option into v. v is left untouched if the option is not set and has no default.
+func GetJSON(key string, v interface{}) error {
+	option, err := lookupTyped(key, JSON)
+	if err != nil {
+		return err
+	}
+	value, err := read(option)
+	if err != nil || value == "" {
+		return err
+	}
+	return json.Unmarshal([]byte(value), v)
+}
+
+// SetJSON : Encodes v as the value of a JSON option
+func (source Source) SetJSON(key string, v interface{}) error {
+	option, err := lookupTyped(key, JSON)
+	if err != nil {
+		return err
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return write(option, string(value), source)
+}
+
+// SetMany : Writes several already encoded values in a single transaction, after validating all of them. Secrets are encrypted.
+func (source Source) SetMany(values map[string]string) error {
+	stored := make(map[string]string, len(values))
+	for key, value := range values {
+		option, found := Lookup(key)
+		if !found {
+			return fmt.Errorf("%w %s", ErrUnknownOption, key)
+		}
+		err := option.Validate(value)
+		if err != nil {
+			return err
+		}
+		stored[key], err = encodeSecret(option, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	store, err := utils.GetOptionStore()
+	if err != nil {
+		return err
+	}
+	return store.SetMany(stored, string(source))
+}
+
+// Delete : Deletes a registered option, so it reads as its default again
+func (source Source) Delete(key string) error {
+	if _, found := Lookup(key); !found {
+		return fmt.Errorf("%w %s", ErrUnknownOption, key)
+	}
+
+	store, err := utils.GetOptionStore()
+	if err != nil {
+		return err
+	}
+	return store.Delete(key, string(source))
+}
@@ -0,0 +1,346 @@
+// +build unit
+
+package options
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/config"
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestRegistry(t *testing.T) {
+	keyPattern := regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	seen := map[string]bool{}
+
+	for _, option := range All() {
+		if seen[option.Key] {
+			t.Log("Duplicated option", option.Key)
+			t.Fail()
+		}
+		seen[option.Key] = true
+
+		if !keyPattern.MatchString(option.Key) {
+			t.Log("Invalid option key", option.Key)
+			t.Fail()
+		}
+		if option.Type == ENUM && len(option.Values) == 0 {
+			t.Log("Enum option without values", option.Key)
+			t.Fail()
+		}
+		if err := option.Validate(option.Default); err != nil {
+			t.Log("Invalid default:", err)
+			t.Fail()
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		key   string
+		value string
+		valid bool
+	}{
+		{"DOMAIN_NAME", "anything", true},
+		{"BACKUP_IS_WORKING", "1", true},
+		{"BACKUP_IS_WORKING", "true", false},
+		{"DASHBOARD_BLOCK_DEFAULT_APPS_PUBLIC_ACCESS", "yes", true},
+		{"UPDATING_SYSTEM", "true", true},
+		{"CLUSTER_SSH_PORT", "22", true},
+		{"CLUSTER_SSH_PORT", "ssh", false},
+		{"BACKUP_LAST_RUN", "1700000000", true},
+		{"BACKUP_SERVICE", "b2", true},
+		{"BACKUP_SERVICE", "ftp", false},
+		{"SYSTEM_UPDATES", "[]", true},
+		{"SYSTEM_UPDATES", "[", false},
+		{"BACKUP_SERVICE", "", true},
+	}
+
+	for _, c := range cases {
+		option, _ := Lookup(c.key)
+		err := option.Validate(c.value)
+		if (err == nil) != c.valid {
+			t.Logf("Validate(%s, %q): expected valid=%t, got %v", c.key, c.value, c.valid, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestTypedAccessors(t *testing.T) {
+	cleanup := utils.SetupTestDatabase(t)
+	defer cleanup()
+
+	var updates []map[string]string
+	if err := GetJSON("SYSTEM_UPDATES", &updates); err != nil || updates == nil || len(updates) != 0 {
+		t.Log("Expected the default of SYSTEM_UPDATES to decode to an empty list, got", updates, err)
+		t.Fail()
+	}
+
+	if err := SOURCE_EDGEBOXCTL.SetBool("BACKUP_IS_WORKING", true); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := GetString("DOMAIN_NAME"); value != "" {
+		t.Log("Expected an unset string to be empty, got", value)
+		t.Fail()
+	}
+	if working, err := GetBool("BACKUP_IS_WORKING"); err != nil || !working {
+		t.Log("Expected BACKUP_IS_WORKING to be true, got", working, err)
+		t.Fail()
+	}
+
+	now := time.Unix(time.Now().Unix(), 0)
+	SOURCE_EDGEBOXCTL.SetTime("BACKUP_LAST_RUN", now)
+	if value, err := GetTime("BACKUP_LAST_RUN"); err != nil || !value.Equal(now) {
+		t.Log("Expected BACKUP_LAST_RUN to be", now, "got", value, err)
+		t.Fail()
+	}
+
+	SOURCE_EDGEBOXCTL.SetInt("CLUSTER_SSH_PORT", 2222)
+	if value, _ := GetInt("CLUSTER_SSH_PORT"); value != 2222 {
+		t.Log("Expected CLUSTER_SSH_PORT to be 2222, got", value)
+		t.Fail()
+	}
+
+	if err := SOURCE_EDGEBOXCTL.SetEnum("BACKUP_SERVICE", "ftp"); err == nil {
+		t.Log("Expected an invalid enum value to be rejected")
+		t.Fail()
+	}
+
+	if err := SOURCE_EDGEBOXCTL.SetString("BACKUP_IS_WORKING", "yes"); err == nil {
+		t.Log("Expected a write with the wrong type to be rejected")
+		t.Fail()
+	}
+
+	if _, err := GetString("NOT_AN_OPTION"); !errors.Is(err, ErrUnknownOption) {
+		t.Log("Expected ErrUnknownOption, got", err)
+		t.Fail()
+	}
+
+	if err := SOURCE_EDGEBOXCTL.SetMany(map[string]string{"BACKUP_SERVICE": "s3", "BACKUP_IS_WORKING": "maybe"}); err == nil {
+		t.Log("Expected SetMany to reject an invalid value")
+		t.Fail()
+	}
+	if value, _ := GetEnum("BACKUP_SERVICE"); value != "" {
+		t.Log("Expected no value of a rejected SetMany to be written, got", value)
+		t.Fail()
+	}
+}
+
+func TestReferenceIsUpToDate(t *testing.T) {
+	published, err := ioutil.ReadFile("../../docs/options.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(published) != Reference() {
+		t.Log("docs/options.md is out of date, run \"make options-reference\"")
+		t.Fail()
+	}
+}
+
+func TestCheckChanges(t *testing.T) {
+	cleanup := utils.SetupTestDatabase(t)
+	defer cleanup()
+
+	var seen []Change
+	OnChange("HOSTNAME", func(change Change) {
+		seen = append(seen, change)
+	})
+
+	SOURCE_EDGEBOXCTL.SetString("HOSTNAME", "edgebox")
+	if changes := CheckChanges(); len(changes) != 0 {
+		t.Log("Expected the first check to only take a snapshot, got", changes)
+		t.Fail()
+	}
+
+	SOURCE_EDGEBOXCTL.SetString("HOSTNAME", "edgebox")
+	if changes := CheckChanges(); len(changes) != 0 {
+		t.Log("Expected rewriting the same value not to be a change, got", changes)
+		t.Fail()
+	}
+
+	SOURCE_EDGEBOXCTL.SetString("HOSTNAME", "edgebox-2")
+	SOURCE_EDGEBOXCTL.Delete("HOSTNAME")
+	SOURCE_EDGEBOXCTL.SetString("HOSTNAME", "edgebox-3")
+	CheckChanges()
+	SOURCE_EDGEBOXCTL.Delete("HOSTNAME")
+	CheckChanges()
+
+	expected := []Change{
+		{Key: "HOSTNAME", OldValue: "edgebox", NewValue: "edgebox-3"},
+		{Key: "HOSTNAME", OldValue: "edgebox-3", NewValue: ""},
+	}
+	if len(seen) != len(expected) {
+		t.Fatal("Expected", expected, "got", seen)
+	}
+	for i := range expected {
+		if seen[i] != expected[i] {
+			t.Log("Expected", expected[i], "got", seen[i])
+			t.Fail()
+		}
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	cleanup := utils.SetupTestDatabase(t)
+	defer cleanup()
+
+	store, _ := utils.GetOptionStore()
+
+	err := SOURCE_EDGEBOXCTL.SetString("BACKUP_REPOSITORY_SECRET_ACCESS_KEY", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, _ := store.Get("BACKUP_REPOSITORY_SECRET_ACCESS_KEY")
+	if !IsEncrypted(stored) || strings.Contains(stored, "hunter2") {
+		t.Log("Expected the secret to be stored encrypted, got", stored)
+		t.Fail()
+	}
+	if value, err := GetString("BACKUP_REPOSITORY_SECRET_ACCESS_KEY"); err != nil || value != "hunter2" {
+		t.Log("Expected the secret to be decrypted when read, got", value, err)
+		t.Fail()
+	}
+
+	info, err := os.Stat(config.Get().Paths.SecretKeyFile)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Log("Expected the secret key file to be readable by its owner only, got", info, err)
+		t.Fail()
+	}
+
+	// Encrypted values are bound to their option
+	store.Set("BACKUP_REPOSITORY_PASSWORD", stored, "")
+	if _, err := GetString("BACKUP_REPOSITORY_PASSWORD"); err == nil {
+		t.Log("Expected a value encrypted for another option not to decrypt")
+		t.Fail()
+	}
+
+	// Legacy plaintext is readable, and migrated
+	store.Set("BACKUP_REPOSITORY_PASSWORD", "plain", "")
+	if value, _ := GetString("BACKUP_REPOSITORY_PASSWORD"); value != "plain" {
+		t.Log("Expected a plaintext secret to be readable, got", value)
+		t.Fail()
+	}
+	count, err := EncryptSecrets()
+	if err != nil || count != 1 {
+		t.Log("Expected EncryptSecrets to encrypt 1 option, got", count, err)
+		t.Fail()
+	}
+	if stored, _ := store.Get("BACKUP_REPOSITORY_PASSWORD"); !IsEncrypted(stored) {
+		t.Log("Expected the plaintext secret to be encrypted, got", stored)
+		t.Fail()
+	}
+
+	// Options the dashboard reads are stored in plaintext, and values encrypted by older versions are decrypted
+	SOURCE_EDGEBOXCTL.SetString("BROWSERDEV_PASSWORD", "code")
+	if stored, _ := store.Get("BROWSERDEV_PASSWORD"); stored != "code" {
+		t.Log("Expected a redacted option to be stored in plaintext, got", stored)
+		t.Fail()
+	}
+	keyring, _ := loadKeyring()
+	encrypted, _ := encryptSecret(keyring, "BROWSERDEV_PASSWORD", "code")
+	store.Set("BROWSERDEV_PASSWORD", encrypted, "")
+	if value, _ := GetString("BROWSERDEV_PASSWORD"); value != "code" {
+		t.Log("Expected an encrypted value of an option that is not secret to be readable, got", value)
+		t.Fail()
+	}
+	count, err = EncryptSecrets()
+	if err != nil || count != 1 {
+		t.Log("Expected EncryptSecrets to decrypt 1 option, got", count, err)
+		t.Fail()
+	}
+	if stored, _ := store.Get("BROWSERDEV_PASSWORD"); stored != "code" {
+		t.Log("Expected the encrypted value to be migrated to plaintext, got", stored)
+		t.Fail()
+	}
+
+	previousKey, _ := ioutil.ReadFile(config.Get().Paths.SecretKeyFile)
+	count, err = RotateSecretKey()
+	if err != nil || count != 2 {
+		t.Log("Expected RotateSecretKey to encrypt 2 options, got", count, err)
+		t.Fail()
+	}
+	currentKey, _ := ioutil.ReadFile(config.Get().Paths.SecretKeyFile)
+	if string(currentKey) != firstKeyLine(t)+string(previousKey) {
+		t.Log("Expected the keyring to hold a new key and the previous one after rotation")
+		t.Fail()
+	}
+	if value, _ := GetString("BACKUP_REPOSITORY_SECRET_ACCESS_KEY"); value != "hunter2" {
+		t.Log("Expected the secret to be readable after rotation, got", value)
+		t.Fail()
+	}
+
+	// A value written with the previous key while rotating stays readable until the next rotation
+	previousKeyring, _ := loadKeyring()
+	late, _ := encryptSecret(previousKeyring[1:], "BACKUP_REPOSITORY_SECRET_ACCESS_KEY", "written-while-rotating")
+	store.Set("BACKUP_REPOSITORY_SECRET_ACCESS_KEY", late, "")
+	if value, _ := GetString("BACKUP_REPOSITORY_SECRET_ACCESS_KEY"); value != "written-while-rotating" {
+		t.Log("Expected a value encrypted with the previous key to be readable, got", value)
+		t.Fail()
+	}
+
+	RotateSecretKey()
+	currentKey, _ = ioutil.ReadFile(config.Get().Paths.SecretKeyFile)
+	if strings.Count(string(currentKey), "\n") != 2 {
+		t.Log("Expected keys no value uses anymore to be dropped, got", strings.Count(string(currentKey), "\n"), "keys")
+		t.Fail()
+	}
+	if value, _ := GetString("BACKUP_REPOSITORY_SECRET_ACCESS_KEY"); value != "written-while-rotating" {
+		t.Log("Expected the secret to be readable after another rotation, got", value)
+		t.Fail()
+	}
+}
+
+// firstKeyLine : Returns the first line of the secret key file
+func firstKeyLine(t *testing.T) string {
+	content, err := ioutil.ReadFile(config.Get().Paths.SecretKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.SplitN(string(content), "\n", 2)[0] + "\n"
+}
+
+func TestHistoryFilter(t *testing.T) {
+	cleanup := utils.SetupTestDatabase(t)
+	defer cleanup()
+
+	SOURCE_EDGEBOXCTL.SetInt("SYSTEM_UPTIME", 10)
+	SOURCE_EDGEBOXCTL.SetInt("SYSTEM_UPTIME", 20)
+	SOURCE_EDGEBOXCTL.SetString("BACKUP_REPOSITORY_PASSWORD", "first")
+	SOURCE_EDGEBOXCTL.SetString("BACKUP_REPOSITORY_PASSWORD", "first")
+	Source("task:setup_backups#3").SetString("BACKUP_REPOSITORY_PASSWORD", "second")
+
+	if changes, _ := History("SYSTEM_UPTIME", 10); len(changes) != 0 {
+		t.Log("Expected changes of volatile options not to be recorded, got", changes)
+		t.Fail()
+	}
+
+	changes, _ := History("BACKUP_REPOSITORY_PASSWORD", 10)
+	if len(changes) != 2 {
+		t.Fatal("Expected 2 changes of the secret, got", changes)
+	}
+	if changes[0].OldValue != redactedValue || changes[0].NewValue != redactedValue || changes[1].OldValue != "" {
+		t.Log("Expected secret values to be redacted, got", changes)
+		t.Fail()
+	}
+	SOURCE_EDGEBOXCTL.SetString("BROWSERDEV_PASSWORD", "code")
+	if changes, _ := History("BROWSERDEV_PASSWORD", 10); len(changes) != 1 || changes[0].NewValue != redactedValue {
+		t.Log("Expected values of redacted options to be redacted, got", changes)
+		t.Fail()
+	}
+	if changes[0].Source != "task:setup_backups#3" || changes[1].Source != string(SOURCE_EDGEBOXCTL) {
+		t.Log("Expected each change to be recorded with the source that wrote it, got", changes)
+		t.Fail()
+	}
+
+	if _, err := History("NOT_AN_OPTION", 10); !errors.Is(err, ErrUnknownOption) {
+		t.Log("Expected ErrUnknownOption, got", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,66 @@
+package options
+
+import (
+	"strings"
+)
+
+// Reference : Returns a markdown reference of every registered option, as published in docs/options.md
+func Reference() string {
+
+	var builder strings.Builder
+
+	builder.WriteString("# Options reference\n\n")
+	builder.WriteString("<!-- Generated by \"make options-reference\" from internal/options/registry.go. Do not edit by hand. -->\n\n")
+	builder.WriteString("Options are key value pairs in the `option` table of the api database. Values are always stored as strings, encoded according to their type. ")
+	builder.WriteString("Only the owner of an option writes it, other components only read it. Secret options are stored encrypted with the device key (`paths.secret_key_file`) and must never be shown in full. Redacted options are stored in plaintext, as other components read them, but are not shown in full either.\n\n")
+	builder.WriteString("| Key | Type | Owner | Default | Secret | Description |\n")
+	builder.WriteString("| --- | ---- | ----- | ------- | ------ | ----------- |\n")
+
+	for _, option := range registry {
+		secret := ""
+		if option.Secret {
+			secret = "yes"
+		} else if option.Redacted {
+			secret = "redacted"
+		}
+
+		description := option.Description
+		if option.Volatile {
+			description += " Not kept in the option history."
+		}
+
+		builder.WriteString("| `" + option.Key + "` | " + describeType(option) + " | " + option.Owner + " | " + code(option.Default) + " | " + secret + " | " + escapeCell(description) + " |\n")
+	}
+
+	return builder.String()
+}
+
+// describeType : Returns the type of the option, with its encoding details
+func describeType(option Option) string {
+	switch option.Type {
+	case BOOL:
+		return "bool (" + code(option.trueValue()) + " / " + code(option.falseValue()) + ")"
+	case ENUM:
+		values := make([]string, 0, len(option.Values))
+		for _, value := range option.Values {
+			values = append(values, code(value))
+		}
+		return "enum (" + strings.Join(values, ", ") + ")"
+	case JSON:
+		if option.Schema != "" {
+			return "json " + code(option.Schema)
+		}
+	}
+	return string(option.Type)
+}
+
+func code(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "`" + escapeCell(value) + "`"
+}
+
+func escapeCell(value string) string {
+	return strings.ReplaceAll(value, "|", "\\|")
+}
@@ -0,0 +1,64 @@
+package options
+
+// registry : Every option key of the options table. Register new options here, and regenerate the reference with "make options-reference".
+var registry = []Option{
+
+	// System
+
+	{Key: "RELEASE_VERSION", Type: STRING, Owner: OWNER_EDGEBOXCTL, Description: "Release edgeboxctl was built for (dev, prod or cloud)."},
+	{Key: "HOSTNAME", Type: STRING, Owner: OWNER_EDGEBOXCTL, Description: "Hostname of the device."},
+	{Key: "IP_ADDRESS", Type: STRING, Owner: OWNER_EDGEBOXCTL, Description: "IP address of the device in the local network."},
+	{Key: "SYSTEM_UPTIME", Type: INT, Owner: OWNER_EDGEBOXCTL, Volatile: true, Description: "Seconds since the device booted."},
+	{Key: "SYSTEM_READINESS", Type: JSON, Owner: OWNER_EDGEBOXCTL, Volatile: true, Description: "Result of the last readiness check.", Schema: `{"ready": bool, "time": int, "checks": [{"name": string, "ok": bool, "message": string}]}`},
+	{Key: "STORAGE_DEVICES_LIST", Type: JSON, Owner: OWNER_EDGEBOXCTL, Volatile: true, Description: "Storage devices, their partitions and usage.", Schema: "[storage.Device]"},
+	{Key: "SYSTEM_UPDATES", Type: JSON, Owner: OWNER_EDGEBOXCTL, Default: "[]", Description: "Components with a newer version available, as found by the last updates check.", Schema: `[{"target": string, "version": string}]`},
+	{Key: "UPDATING_SYSTEM", Type: BOOL, Owner: OWNER_EDGEBOXCTL, Description: "Whether a system update is being applied."},
+	{Key: "LAST_UPDATE", Type: TIMESTAMP, Owner: OWNER_EDGEBOXCTL, Description: "Time the last system update was applied."},
+	{Key: "WEBHOOKS", Type: JSON, Owner: OWNER_EDGEBOXCTL, Secret: true, Description: "Webhook subscriptions, managed with the add_webhook and remove_webhook tasks.", Schema: `[{"id": string, "url": string, "events": [string], "secret": string}]`},
+
+	// EdgeApps
+
+	{Key: "EDGEAPPS_LIST", Type: JSON, Owner: OWNER_EDGEBOXCTL, Volatile: true, Description: "Every EdgeApp available in the device and its status.", Schema: "[edgeapps.EdgeApp]"},
+	{Key: "DASHBOARD_BLOCK_DEFAULT_APPS_PUBLIC_ACCESS", Type: BOOL, Owner: OWNER_DASHBOARD, TrueValue: "yes", FalseValue: "no", Description: "When set, newly installed EdgeApps do not get a default network URL."},
+	{Key: "EDGEAPPS_WATCHDOG", Type: JSON, Owner: OWNER_EDGEBOXCTL, Description: "Restarts of failing EdgeApps by the watchdog, by EdgeApp ID.", Schema: `{string: {"restarts": int, "total_restarts": int, "failing_since": int, "last_restart": int, "last_reason": string, "gave_up": bool}}`},
+	{Key: "PUBLIC_DASHBOARD", Type: STRING, Owner: OWNER_EDGEBOXCTL, Description: "Internet URL of the dashboard, empty when it is not public."},
+
+	// Tunnel
+
+	{Key: "DOMAIN_NAME", Type: STRING, Owner: OWNER_EDGEBOXCTL, Description: "Domain name routed through the tunnel."},
+	{Key: "TUNNEL_STATUS", Type: JSON, Owner: OWNER_EDGEBOXCTL, Description: "Status of the tunnel setup and service.", Schema: `{"status": "waiting"|"starting"|"connected"|"stopped"|"error", "login_link": string, "domain": string, "message": string}`},
+
+	// Backups
+
+	{Key: "BACKUP_SERVICE", Type: ENUM, Owner: OWNER_EDGEBOXCTL, Values: []string{"s3", "b2", "wasabi"}, Description: "Storage service backups are sent to."},
+	{Key: "BACKUP_SERVICE_URL", Type: STRING, Owner: OWNER_EDGEBOXCTL, Description: "Endpoint of the backup storage service."},
+	{Key: "BACKUP_REPOSITORY_NAME", Type: STRING, Owner: OWNER_EDGEBOXCTL, Description: "Name of the backup repository (bucket)."},
+	{Key: "BACKUP_REPOSITORY_PASSWORD", Type: STRING, Owner: OWNER_EDGEBOXCTL, Secret: true, Description: "Password encrypting the backup repository."},
+	{Key: "BACKUP_REPOSITORY_ACCESS_KEY_ID", Type: STRING, Owner: OWNER_EDGEBOXCTL, Description: "Access key id of the backup storage service."},
+	{Key: "BACKUP_REPOSITORY_SECRET_ACCESS_KEY", Type: STRING, Owner: OWNER_EDGEBOXCTL, Secret: true, Description: "Secret access key of the backup storage service."},
+	{Key: "BACKUP_REPOSITORY_LOCATION", Type: STRING, Owner: OWNER_EDGEBOXCTL, Description: "Local folder that is backed up."},
+	{Key: "BACKUP_STATUS", Type: ENUM, Owner: OWNER_EDGEBOXCTL, Values: []string{"initiated", "working", "error"}, Description: "Status of backups, empty when they are not configured."},
+	{Key: "BACKUP_IS_WORKING", Type: BOOL, Owner: OWNER_EDGEBOXCTL, TrueValue: "1", FalseValue: "0", Description: "Whether a backup operation is running."},
+	{Key: "BACKUP_LAST_RUN", Type: TIMESTAMP, Owner: OWNER_EDGEBOXCTL, Description: "Time of the last backup attempt, successful or not."},
+	{Key: "BACKUP_LAST_SUCCESS", Type: TIMESTAMP, Owner: OWNER_EDGEBOXCTL, Description: "Time of the last successful backup."},
+	{Key: "BACKUP_ERROR_MESSAGE", Type: STRING, Owner: OWNER_EDGEBOXCTL, Description: "Output of the last failed backup operation."},
+	{Key: "BACKUP_STATS", Type: STRING, Owner: OWNER_EDGEBOXCTL, Volatile: true, Description: "Output of restic stats for the backup repository."},
+
+	// Shell and BrowserDev
+
+	{Key: "SHELL_STATUS", Type: ENUM, Owner: OWNER_EDGEBOXCTL, Values: []string{"running", "not_running"}, Description: "Status of the remote shell."},
+	{Key: "SHELL_URL", Type: STRING, Owner: OWNER_EDGEBOXCTL, Description: "URL of the running remote shell."},
+	{Key: "BROWSERDEV_STATUS", Type: ENUM, Owner: OWNER_EDGEBOXCTL, Values: []string{"running", "not_running"}, Description: "Status of the browser development environment."},
+	{Key: "BROWSERDEV_URL", Type: STRING, Owner: OWNER_EDGEBOXCTL, Description: "URL of the browser development environment."},
+	{Key: "BROWSERDEV_PASSWORD", Type: STRING, Owner: OWNER_EDGEBOXCTL, Redacted: true, Description: "Password of the browser development environment."},
+
+	// Cloud
+
+	{Key: "NAME", Type: STRING, Owner: OWNER_CLOUD, Description: "Name of the device owner."},
+	{Key: "EMAIL", Type: STRING, Owner: OWNER_CLOUD, Description: "Email of the device owner."},
+	{Key: "USERNAME", Type: STRING, Owner: OWNER_CLOUD, Description: "Username of the device owner in the cluster."},
+	{Key: "CLUSTER", Type: STRING, Owner: OWNER_CLOUD, Description: "Domain of the cluster the device runs in."},
+	{Key: "CLUSTER_IP", Type: STRING, Owner: OWNER_CLOUD, Description: "IP address of the cluster."},
+	{Key: "CLUSTER_SSH_PORT", Type: INT, Owner: OWNER_CLOUD, Description: "SSH port of the device in the cluster."},
+	{Key: "EDGEBOXIO_API_TOKEN", Type: STRING, Owner: OWNER_CLOUD, Redacted: true, Description: "Token for the edgebox.io API."},
+}
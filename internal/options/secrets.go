@@ -0,0 +1,372 @@
+package options
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+// secretPrefix : Marks stored values encrypted with a device key, followed by "<key id>:<base64 nonce and ciphertext>".
+// Values without it are legacy plaintext, still readable until EncryptSecrets migrates them.
+const secretPrefix string = "enc:v1:"
+
+const secretKeySize int = 32 // AES-256
+
+// ErrSecretKeyNotFound : Returned when a secret was encrypted with a key that is not in the keyring (anymore)
+var ErrSecretKeyNotFound = errors.New("secret key not found")
+
+// secretKey : A device key, identified by the start of its hash so stored values tell which key encrypted them
+type secretKey struct {
+	id  string
+	key []byte
+}
+
+// IsEncrypted : Returns true if a stored value was encrypted by the secret store
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, secretPrefix)
+}
+
+func newSecretKey(key []byte) secretKey {
+	sum := sha256.Sum256(key)
+	return secretKey{id: hex.EncodeToString(sum[:4]), key: key}
+}
+
+func generateSecretKey() (secretKey, error) {
+	key := make([]byte, secretKeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return secretKey{}, err
+	}
+	return newSecretKey(key), nil
+}
+
+// loadKeyring : Reads the device keys from the secret key file, one base64 key per line. The first key encrypts, any of them decrypts.
+// The file is read every time (instead of cached) so a key rotation ran from the command line is seen by the service right away.
+func loadKeyring() ([]secretKey, error) {
+
+	keyFile := utils.GetPath(utils.SecretKeyFileLocation)
+
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		log.Printf("Secret key file %s is readable by other users, restricting it to root", keyFile)
+		os.Chmod(keyFile, 0600)
+	}
+
+	content, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyring []secretKey
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(key) != secretKeySize {
+			return nil, fmt.Errorf("invalid key in secret key file %s", keyFile)
+		}
+		keyring = append(keyring, newSecretKey(key))
+	}
+
+	if len(keyring) == 0 {
+		return nil, fmt.Errorf("secret key file %s is empty", keyFile)
+	}
+
+	return keyring, nil
+}
+
+// loadOrCreateKeyring : Same as loadKeyring, generating the device key when there is none yet
+func loadOrCreateKeyring() ([]secretKey, error) {
+	keyring, err := loadKeyring()
+	if !os.IsNotExist(err) {
+		return keyring, err
+	}
+
+	key, err := generateSecretKey()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Generating device key in %s", utils.GetPath(utils.SecretKeyFileLocation))
+	keyring = []secretKey{key}
+	return keyring, saveKeyring(keyring)
+}
+
+// saveKeyring : Atomically replaces the secret key file, readable by root only
+func saveKeyring(keyring []secretKey) error {
+
+	keyFile := utils.GetPath(utils.SecretKeyFileLocation)
+	err := os.MkdirAll(filepath.Dir(keyFile), 0700)
+	if err != nil {
+		return err
+	}
+
+	var content strings.Builder
+	for _, key := range keyring {
+		content.WriteString(base64.StdEncoding.EncodeToString(key.key) + "\n")
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(keyFile), filepath.Base(keyFile)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	// TempFile already creates the file as 0600
+	_, err = file.WriteString(content.String())
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), keyFile)
+}
+
+// encryptSecret : Encrypts the value of a secret option with the first key of the keyring. The option key is authenticated too, so values can not be swapped between options.
+func encryptSecret(keyring []secretKey, optionKey string, value string) (string, error) {
+
+	if value == "" {
+		return "", nil // Empty still means "not set"
+	}
+
+	gcm, err := newGCM(keyring[0].key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(value), []byte(optionKey))
+	return secretPrefix + keyring[0].id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptSecret : Decrypts a stored secret value with the keyring key that encrypted it. Legacy plaintext values are returned as they are.
+func decryptSecret(keyring []secretKey, optionKey string, stored string) (string, error) {
+
+	if !IsEncrypted(stored) {
+		return stored, nil
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(stored, secretPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("option %s has a malformed encrypted value", optionKey)
+	}
+
+	var key []byte
+	for _, candidate := range keyring {
+		if candidate.id == parts[0] {
+			key = candidate.key
+		}
+	}
+	if key == nil {
+		return "", fmt.Errorf("%w %s (option %s)", ErrSecretKeyNotFound, parts[0], optionKey)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("option %s has a malformed encrypted value", optionKey)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("option %s has a malformed encrypted value", optionKey)
+	}
+
+	value, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(optionKey))
+	if err != nil {
+		return "", fmt.Errorf("option %s could not be decrypted: %s", optionKey, err)
+	}
+
+	return string(value), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encodeSecret : Returns the value to store for an option, encrypted when the option is secret
+func encodeSecret(option Option, value string) (string, error) {
+	if !option.Secret || value == "" {
+		return value, nil
+	}
+
+	keyring, err := loadOrCreateKeyring()
+	if err != nil {
+		return "", fmt.Errorf("option %s could not be encrypted: %s", option.Key, err)
+	}
+	return encryptSecret(keyring, option.Key, value)
+}
+
+// decodeSecret : Returns the value of an option from its stored value, decrypting it when needed. Options that are not secret anymore
+// may still hold an encrypted value until EncryptSecrets migrates them, so any encrypted value is decrypted.
+func decodeSecret(option Option, stored string) (string, error) {
+	if !IsEncrypted(stored) {
+		return stored, nil
+	}
+
+	keyring, err := loadKeyring()
+	if err != nil {
+		return "", fmt.Errorf("option %s could not be decrypted: %s", option.Key, err)
+	}
+	return decryptSecret(keyring, option.Key, stored)
+}
+
+// reencryptSecrets : Encrypts every stored secret option with the first key of the keyring, and decrypts options that are not secret anymore,
+// in a single transaction. Returns the number of options rewritten.
+func reencryptSecrets(keyring []secretKey, onlyPlaintext bool) (int, error) {
+
+	store, err := utils.GetOptionStore()
+	if err != nil {
+		return 0, err
+	}
+
+	values := map[string]string{}
+	for _, option := range registry {
+		stored, err := store.Get(option.Key)
+		if err == utils.ErrOptionNotFound || stored == "" {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if !option.Secret {
+			// Other components read these options directly, without the device key
+			if IsEncrypted(stored) {
+				values[option.Key], err = decryptSecret(keyring, option.Key, stored)
+				if err != nil {
+					return 0, err
+				}
+			}
+			continue
+		}
+		if onlyPlaintext && IsEncrypted(stored) {
+			continue
+		}
+
+		value, err := decryptSecret(keyring, option.Key, stored)
+		if err != nil {
+			return 0, err
+		}
+		values[option.Key], err = encryptSecret(keyring, option.Key, value)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(values) == 0 {
+		return 0, nil
+	}
+	return len(values), store.SetMany(values, string(SOURCE_EDGEBOXCTL))
+}
+
+// EncryptSecrets : Migrates secret options still stored in plaintext (written by older versions, or directly in the database) to encrypted values,
+// and options that are not secret anymore back to plaintext
+func EncryptSecrets() (int, error) {
+	keyring, err := loadOrCreateKeyring()
+	if err != nil {
+		return 0, err
+	}
+	return reencryptSecrets(keyring, true)
+}
+
+// RotateSecretKey : Generates a new device key and encrypts every secret option with it. The new key is added to the keyring before any value
+// is rewritten, so values stay readable if the rotation is interrupted. The previous key is kept until the next rotation, as the running service
+// may still encrypt a value with it while rotating, and older keys are only dropped when no stored value uses them.
+func RotateSecretKey() (int, error) {
+
+	keyring, err := loadOrCreateKeyring()
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := generateSecretKey()
+	if err != nil {
+		return 0, err
+	}
+
+	keyring = append([]secretKey{key}, keyring...)
+	err = saveKeyring(keyring)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := reencryptSecrets(keyring, false)
+	if err != nil {
+		return count, err
+	}
+
+	used, err := usedSecretKeyIDs()
+	if err != nil {
+		return count, err
+	}
+
+	kept := keyring[:2]
+	for _, key := range keyring[2:] {
+		if used[key.id] {
+			kept = append(kept, key)
+		}
+	}
+
+	return count, saveKeyring(kept)
+}
+
+// usedSecretKeyIDs : Returns the ids of the keys the stored secret options are encrypted with
+func usedSecretKeyIDs() (map[string]bool, error) {
+
+	store, err := utils.GetOptionStore()
+	if err != nil {
+		return nil, err
+	}
+
+	used := map[string]bool{}
+	for _, option := range registry {
+		if !option.Secret {
+			continue
+		}
+
+		stored, err := store.Get(option.Key)
+		if err == utils.ErrOptionNotFound || !IsEncrypted(stored) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		used[strings.SplitN(strings.TrimPrefix(stored, secretPrefix), ":", 2)[0]] = true
+	}
+
+	return used, nil
+}
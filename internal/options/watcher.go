@@ -0,0 +1,133 @@
+package options
+
+import (
+	"database/sql"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+// Change : Struct describing a change of a watched option, as detected between two checks
+type Change struct {
+	Key      string
+	OldValue string
+	NewValue string
+}
+
+// Reaction : Function called when a watched option changes
+type Reaction func(change Change)
+
+var reactions = map[string][]Reaction{}
+var snapshot map[string]string // Values of watched options as of the last check
+var watcherMutex sync.Mutex
+
+// OnChange : Registers a reaction to changes of an option, no matter who writes it (edgeboxctl, the dashboard or the cloud)
+func OnChange(key string, reaction Reaction) {
+	if _, found := Lookup(key); !found {
+		log.Printf("Not watching unknown option %s", key)
+		return
+	}
+
+	watcherMutex.Lock()
+	defer watcherMutex.Unlock()
+	reactions[key] = append(reactions[key], reaction)
+}
+
+// CheckChanges : Compares watched options with the last check and calls the reactions of the ones that changed.
+// The first successful check only takes a snapshot, so restarting edgeboxctl does not trigger reactions.
+func CheckChanges() []Change {
+
+	watcherMutex.Lock()
+	keys := make([]string, 0, len(reactions))
+	for key := range reactions {
+		keys = append(keys, key)
+	}
+	watcherMutex.Unlock()
+
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+
+	current, err := readWatched(keys)
+	if err != nil {
+		log.Printf("Error checking option changes: %s", err)
+		return nil
+	}
+
+	watcherMutex.Lock()
+	previous := snapshot
+	snapshot = current
+	watcherMutex.Unlock()
+
+	if previous == nil {
+		return nil
+	}
+
+	var changes []Change
+	for _, key := range keys {
+		// Values are compared rather than the updated column, as not every writer maintains it and deleted rows have none.
+		// Rewriting the same value is not a change.
+		if previous[key] == current[key] {
+			continue
+		}
+		changes = append(changes, Change{Key: key, OldValue: previous[key], NewValue: current[key]})
+	}
+
+	for _, change := range changes {
+		log.Printf("Option %s changed, running its reactions", change.Key)
+		watcherMutex.Lock()
+		keyReactions := append([]Reaction(nil), reactions[change.Key]...)
+		watcherMutex.Unlock()
+		for _, reaction := range keyReactions {
+			reaction(change)
+		}
+	}
+
+	return changes
+}
+
+// readWatched : Reads the given options with a single query over the options table. Missing rows read as the option default.
+func readWatched(keys []string) (map[string]string, error) {
+
+	db, err := utils.GetDatabase()
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+
+	rows, err := db.Query("SELECT name, value FROM option WHERE name IN (?"+strings.Repeat(", ?", len(keys)-1)+");", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[string]string{}
+	for _, key := range keys {
+		option, _ := Lookup(key)
+		result[key] = option.Default
+	}
+
+	for rows.Next() {
+		var name string
+		var value sql.NullString
+		err = rows.Scan(&name, &value)
+		if err != nil {
+			return nil, err
+		}
+		option, _ := Lookup(name)
+		result[name], err = decodeSecret(option, value.String)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, rows.Err()
+}
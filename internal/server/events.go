@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/events"
+)
+
+const eventsHeartbeatInterval time.Duration = time.Second * 15
+
+// handleEvents : Streams published events as Server-Sent Events. Use ?types=task.*,app.status_changed to only receive some of them.
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	var patterns []string
+	if types := r.URL.Query().Get("types"); types != "" {
+		patterns = strings.Split(types, ",")
+	}
+
+	subscription, unsubscribe := events.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+
+		case event, open := <-subscription:
+			if !open {
+				return
+			}
+			if !events.MatchAny(patterns, event.Type) {
+				continue
+			}
+			data, err := json.Marshal(events.Redact(event))
+			if err != nil {
+				log.Printf("Error encoding %s event: %s", event.Type, err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
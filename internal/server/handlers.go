@@ -0,0 +1,300 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/backups"
+	"github.com/edgebox-iot/edgeboxctl/internal/diagnostics"
+	"github.com/edgebox-iot/edgeboxctl/internal/edgeapps"
+	"github.com/edgebox-iot/edgeboxctl/internal/health"
+	"github.com/edgebox-iot/edgeboxctl/internal/options"
+	"github.com/edgebox-iot/edgeboxctl/internal/storage"
+	"github.com/edgebox-iot/edgeboxctl/internal/system"
+	"github.com/edgebox-iot/edgeboxctl/internal/tasks"
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+	"github.com/edgebox-iot/edgeboxctl/internal/webhooks"
+)
+
+const maxRequestBodySize int64 = 1 << 20
+const webhookDeliveriesLimit int = 100
+const defaultOptionHistoryLimit int = 100
+
+// taskRequest : Body of a task submission. Args is passed along as-is, in the same format the dashboard uses.
+type taskRequest struct {
+	Task string          `json:"task"`
+	Args json.RawMessage `json:"args"`
+}
+
+// taskResponse : Representation of a task returned by the control API
+type taskResponse struct {
+	ID      int    `json:"id"`
+	Task    string `json:"task"`
+	Args    string `json:"args"`
+	Status  string `json:"status"`
+	Result  string `json:"result"`
+	Created string `json:"created"`
+	Updated string `json:"updated"`
+}
+
+func newRouter() *http.ServeMux {
+	router := http.NewServeMux()
+	router.HandleFunc("/v1/system", handleSystem)
+	router.HandleFunc("/v1/apps", handleApps)
+	router.HandleFunc("/v1/apps/", handleApp)
+	router.HandleFunc("/v1/storage", handleStorage)
+	router.HandleFunc("/v1/backups", handleBackups)
+	router.HandleFunc("/v1/tasks", handleTasks)
+	router.HandleFunc("/v1/tasks/", handleTask)
+	router.HandleFunc("/v1/events", handleEvents)
+	router.HandleFunc("/v1/webhooks", handleWebhooks)
+	router.HandleFunc("/v1/options/history", handleOptionHistory)
+	router.HandleFunc("/v1/logs/", handleLogs)
+	router.HandleFunc("/metrics", handleMetrics)
+	router.HandleFunc("/healthz", handleHealthz)
+	router.HandleFunc("/readyz", handleReadyz)
+	return router
+}
+
+func newTaskResponse(task tasks.Task) taskResponse {
+	return taskResponse{
+		ID:      task.ID,
+		Task:    task.Task,
+		Args:    task.Args.String,
+		Status:  task.Status,
+		Result:  task.Result.String,
+		Created: task.Created,
+		Updated: task.Updated,
+	}
+}
+
+// allowMethod : Writes a 405 response and returns false if the request method is not the expected one
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+	return true
+}
+
+// pathID : Returns the last segment of the request path after prefix, or "" if it is not a single valid segment
+func pathID(r *http.Request, prefix string) string {
+	ID := strings.TrimPrefix(r.URL.Path, prefix)
+	if ID == "" || strings.Contains(ID, "/") || strings.HasPrefix(ID, ".") {
+		return ""
+	}
+	return ID
+}
+
+// handleHealthz : Liveness, answering as long as edgeboxctl is running and serving requests
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz : Readiness to execute tasks, with the result of every dependency check
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	report := health.GetReport()
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+func handleSystem(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, system.GetInfo())
+}
+
+func handleApps(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	edgeApps := edgeapps.GetEdgeApps()
+	if edgeApps == nil {
+		edgeApps = []edgeapps.EdgeApp{}
+	}
+	writeJSON(w, http.StatusOK, edgeApps)
+}
+
+func handleApp(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	ID := pathID(r, "/v1/apps/")
+	if ID == "" {
+		writeError(w, http.StatusNotFound, "app not found")
+		return
+	}
+
+	maybeEdgeApp := edgeapps.GetEdgeApp(ID)
+	if !maybeEdgeApp.Valid {
+		writeError(w, http.StatusNotFound, "app not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, maybeEdgeApp.EdgeApp)
+}
+
+func handleStorage(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, storage.GetDevices(diagnostics.GetReleaseVersion()))
+}
+
+func handleBackups(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, backups.GetStatus())
+}
+
+func handleTasks(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var request taskRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	err := decoder.Decode(&request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	if !tasks.IsKnownTask(request.Task) {
+		writeError(w, http.StatusBadRequest, "unknown task "+request.Task)
+		return
+	}
+
+	args := ""
+	if len(request.Args) > 0 && string(request.Args) != "null" {
+		args = string(request.Args)
+	}
+
+	task, err := tasks.CreateTask(request.Task, args)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newTaskResponse(task))
+}
+
+func handleTask(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	ID, err := strconv.Atoi(pathID(r, "/v1/tasks/"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "task not found")
+		return
+	}
+
+	task, found, err := tasks.GetTask(ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "task not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newTaskResponse(task))
+}
+
+func handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	subscriptions, err := webhooks.GetWebhooks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for i := range subscriptions {
+		subscriptions[i].Secret = "********"
+	}
+
+	deliveries, err := webhooks.GetDeliveries(webhookDeliveriesLimit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"webhooks":   subscriptions,
+		"deliveries": deliveries,
+	})
+}
+
+// handleOptionHistory : Recorded option changes, newest first. Filtered with ?key=<option key>, and limited with ?limit=<count> (100 by default).
+func handleOptionHistory(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	limit := defaultOptionHistoryLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+	}
+
+	changes, err := options.History(r.URL.Query().Get("key"), limit)
+	if errors.Is(err, options.ErrUnknownOption) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, changes)
+}
+
+// handleLogs : Downloads a logs file written by the get_edgeapp_logs task
+func handleLogs(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	name := pathID(r, "/v1/logs/")
+	if name == "" || !strings.HasSuffix(name, ".log") {
+		writeError(w, http.StatusNotFound, "logs not found")
+		return
+	}
+
+	path := utils.GetPath(utils.EdgeAppLogsPath) + name
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		writeError(w, http.StatusNotFound, "logs not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+name+"\"")
+	http.ServeFile(w, r, path)
+}
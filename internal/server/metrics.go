@@ -0,0 +1,159 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/backups"
+	"github.com/edgebox-iot/edgeboxctl/internal/diagnostics"
+	"github.com/edgebox-iot/edgeboxctl/internal/edgeapps"
+	"github.com/edgebox-iot/edgeboxctl/internal/metrics"
+	"github.com/edgebox-iot/edgeboxctl/internal/storage"
+	"github.com/edgebox-iot/edgeboxctl/internal/system"
+	"github.com/edgebox-iot/edgeboxctl/internal/tasks"
+)
+
+// handleMetrics : Serves every metric in the Prometheus text format. Gauges are read at scrape time, counters are accumulated in-process.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	families := metrics.Collect()
+	families = append(families, collectSystemMetrics()...)
+	families = append(families, collectTaskQueueMetrics()...)
+	families = append(families, collectEdgeAppMetrics()...)
+	families = append(families, collectBackupMetrics()...)
+	families = append(families, collectStorageMetrics()...)
+	families = append(families, collectUpdateMetrics()...)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	err := metrics.Write(w, families)
+	if err != nil {
+		log.Println("Error writing metrics: " + err.Error())
+	}
+}
+
+func gauge(name string, help string, samples ...metrics.Sample) metrics.Family {
+	return metrics.Family{Name: name, Help: help, Type: metrics.GAUGE, Samples: samples}
+}
+
+func collectSystemMetrics() []metrics.Family {
+	families := []metrics.Family{
+		gauge("edgeboxctl_build_info", "Version information of this edgeboxctl instance.", metrics.Sample{
+			Labels: metrics.Labels{
+				"release": diagnostics.Version,
+				"commit":  diagnostics.Commit,
+			},
+			Value: 1,
+		}),
+	}
+
+	uptime, err := strconv.ParseFloat(system.GetUptimeInSeconds(), 64)
+	if err == nil {
+		families = append(families, gauge("edgeboxctl_system_uptime_seconds", "Time since the system booted.", metrics.Sample{Value: uptime}))
+	}
+
+	return families
+}
+
+func collectTaskQueueMetrics() []metrics.Family {
+	depth, err := tasks.GetQueueDepth()
+	if err != nil {
+		log.Println("Error reading task queue depth: " + err.Error())
+		return nil
+	}
+
+	return []metrics.Family{
+		gauge("edgeboxctl_task_queue_depth", "Number of tasks waiting to be executed.", metrics.Sample{Value: float64(depth)}),
+	}
+}
+
+// collectEdgeAppMetrics : Reports EdgeApps as of their last status check, so scraping does not query docker
+func collectEdgeAppMetrics() []metrics.Family {
+	status := gauge("edgeboxctl_edgeapp_status", "Last known status of each EdgeApp: -1 not installed, 0 off, 1 on, 2 error, 3 degraded, 4 unhealthy.")
+	for ID, appStatus := range edgeapps.GetLastKnownStatuses() {
+		status.Samples = append(status.Samples, metrics.Sample{
+			Labels: metrics.Labels{"app": ID},
+			Value:  float64(appStatus.ID),
+		})
+	}
+
+	running := gauge("edgeboxctl_edgeapp_service_running", "Whether each service of an EdgeApp was running at the last status check.")
+	for ID, services := range edgeapps.GetLastKnownServices() {
+		for _, service := range services {
+			value := 0.0
+			if service.IsRunning {
+				value = 1
+			}
+			running.Samples = append(running.Samples, metrics.Sample{
+				Labels: metrics.Labels{"app": ID, "service": service.ID},
+				Value:  value,
+			})
+		}
+	}
+
+	return []metrics.Family{status, running}
+}
+
+func collectBackupMetrics() []metrics.Family {
+	backupStatus := backups.GetStatus()
+
+	families := []metrics.Family{
+		gauge("edgeboxctl_backup_last_run_timestamp_seconds", "Time of the last backup attempt, successful or not.", metrics.Sample{Value: float64(backupStatus.LastRun)}),
+		gauge("edgeboxctl_backup_last_success_timestamp_seconds", "Time of the last successful backup.", metrics.Sample{Value: float64(backupStatus.LastSuccess)}),
+	}
+
+	size, found := backups.ParseStatsSize(backupStatus.Stats)
+	if found {
+		families = append(families, gauge("edgeboxctl_backup_size_bytes", "Size of the backup repository, as of the last stats check.", metrics.Sample{Value: float64(size)}))
+	}
+
+	return families
+}
+
+func collectStorageMetrics() []metrics.Family {
+	total := gauge("edgeboxctl_storage_total_bytes", "Total size of each storage device and mounted partition.")
+	used := gauge("edgeboxctl_storage_used_bytes", "Used space of each storage device and mounted partition.")
+	free := gauge("edgeboxctl_storage_free_bytes", "Free space of each storage device and mounted partition.")
+
+	addUsage := func(labels metrics.Labels, usage storage.UsageStat) {
+		total.Samples = append(total.Samples, metrics.Sample{Labels: labels, Value: float64(usage.Total)})
+		used.Samples = append(used.Samples, metrics.Sample{Labels: labels, Value: float64(usage.Used)})
+		free.Samples = append(free.Samples, metrics.Sample{Labels: labels, Value: float64(usage.Free)})
+	}
+
+	for _, device := range storage.GetDevices(diagnostics.GetReleaseVersion()) {
+		addUsage(metrics.Labels{"device": string(device.ID), "partition": "", "mountpoint": ""}, device.UsageStat)
+		for _, partition := range device.Partitions {
+			if partition.Mountpoint == "" {
+				continue
+			}
+			addUsage(metrics.Labels{"device": string(device.ID), "partition": partition.ID, "mountpoint": partition.Mountpoint}, partition.UsageStat)
+		}
+	}
+
+	return []metrics.Family{total, used, free}
+}
+
+func collectUpdateMetrics() []metrics.Family {
+	updates, err := system.GetAvailableUpdates()
+	if err != nil {
+		log.Println("Error reading available updates: " + err.Error())
+		return nil
+	}
+
+	available := gauge("edgeboxctl_update_available", "Components with a newer version available, as found by the last updates check.")
+	for _, update := range updates {
+		available.Samples = append(available.Samples, metrics.Sample{
+			Labels: metrics.Labels{"target": update.Target, "version": update.Version},
+			Value:  1,
+		})
+	}
+
+	return []metrics.Family{
+		available,
+		gauge("edgeboxctl_updates_available", "Number of components with a newer version available.", metrics.Sample{Value: float64(len(updates))}),
+	}
+}
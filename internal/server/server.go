@@ -0,0 +1,103 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/config"
+)
+
+const socketPermissions os.FileMode = 0660
+
+// Start : Starts serving the control API on the configured unix socket, and also on TCP if control_api.listen is set. Returns once listening.
+func Start() error {
+
+	controlApi := config.Get().ControlApi
+	router := newRouter()
+
+	socketListener, err := listenSocket(controlApi.Socket)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Control API listening on unix socket %s", controlApi.Socket)
+	go serve(socketListener, router)
+
+	if controlApi.Listen != "" {
+		tcpListener, err := net.Listen("tcp", controlApi.Listen)
+		if err != nil {
+			socketListener.Close()
+			return err
+		}
+
+		log.Printf("Control API listening on %s", controlApi.Listen)
+		go serve(tcpListener, requireToken(controlApi.Token, router))
+	}
+
+	return nil
+}
+
+// listenSocket : Opens the unix socket, removing any stale socket left behind by a previous run
+func listenSocket(socketPath string) (net.Listener, error) {
+
+	err := os.MkdirAll(filepath.Dir(socketPath), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Remove(socketPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Chmod(socketPath, socketPermissions)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+func serve(listener net.Listener, handler http.Handler) {
+	err := http.Serve(listener, handler)
+	if err != nil {
+		log.Printf("Control API stopped serving on %s: %s", listener.Addr(), err)
+	}
+}
+
+// requireToken : Wraps a handler, only letting through requests carrying "Authorization: Bearer <token>"
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid or missing token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		log.Printf("Error writing control API response: %s", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,88 @@
+// +build unit
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+func TestSubmitAndGetTask(t *testing.T) {
+	defer utils.SetupTestDatabase(t)()
+	router := newRouter()
+
+	request := httptest.NewRequest(http.MethodPost, "/v1/tasks", strings.NewReader(`{"task": "start_edgeapp", "args": {"id": "nextcloud"}}`))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusCreated {
+		t.Log("Expected status 201 but got", recorder.Code, recorder.Body.String())
+		t.FailNow()
+	}
+
+	var created taskResponse
+	json.Unmarshal(recorder.Body.Bytes(), &created)
+	if created.Task != "start_edgeapp" || created.Args != `{"id": "nextcloud"}` || created.Status != "0" {
+		t.Log("Unexpected created task", created)
+		t.Fail()
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/v1/tasks/1", nil)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		t.Log("Expected status 200 but got", recorder.Code, recorder.Body.String())
+		t.Fail()
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/v1/tasks/42", nil)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusNotFound {
+		t.Log("Expected status 404 but got", recorder.Code)
+		t.Fail()
+	}
+}
+
+func TestSubmitUnknownTask(t *testing.T) {
+	router := newRouter()
+
+	request := httptest.NewRequest(http.MethodPost, "/v1/tasks", strings.NewReader(`{"task": "format_disk"}`))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Log("Expected status 400 but got", recorder.Code)
+		t.Fail()
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	handler := requireToken("secret", newRouter())
+
+	request := httptest.NewRequest(http.MethodGet, "/v1/tasks/1", nil)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusUnauthorized {
+		t.Log("Expected status 401 without token but got", recorder.Code)
+		t.Fail()
+	}
+
+	request = httptest.NewRequest(http.MethodPost, "/v1/tasks", strings.NewReader(`{"task": "format_disk"}`))
+	request.Header.Set("Authorization", "Bearer secret")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Log("Expected the request to reach the router with a valid token, got", recorder.Code)
+		t.Fail()
+	}
+}
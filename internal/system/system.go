@@ -14,6 +14,9 @@ import (
 	"io/ioutil"
 	"encoding/json"
 
+	"github.com/edgebox-iot/edgeboxctl/internal/config"
+	"github.com/edgebox-iot/edgeboxctl/internal/diagnostics"
+	"github.com/edgebox-iot/edgeboxctl/internal/options"
 	"github.com/edgebox-iot/edgeboxctl/internal/utils"
 
 	"github.com/joho/godotenv"
@@ -21,12 +24,45 @@ import (
 	"github.com/go-yaml/yaml"
 )
 
+// systemdUnitsPath : Where the systemd units installed by edgeboxctl go
+const systemdUnitsPath string = "/etc/systemd/system/"
+
 type cloudflaredTunnelJson struct {
 	AccountTag string `json:"AccountTag"`
 	TunnelSecret string `json:"TunnelSecret"`
 	TunnelID string `json:"TunnelID"`
 }
 
+// Info : Struct representing general information about the system and this edgeboxctl instance
+type Info struct {
+	Instance  string `json:"instance"`
+	Hostname  string `json:"hostname"`
+	IP        string `json:"ip"`
+	Uptime    string `json:"uptime"`
+	Release   string `json:"release"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+}
+
+// Update : Struct representing a component with a newer version available, as found by the last updates check
+type Update struct {
+	Target  string `json:"target"`
+	Version string `json:"version"`
+}
+
+// GetInfo: Returns general information about the system
+func GetInfo() Info {
+	return Info{
+		Instance:  config.Get().Instance.Name,
+		Hostname:  GetHostname(),
+		IP:        GetIP(),
+		Uptime:    GetUptimeInSeconds(),
+		Release:   diagnostics.Version,
+		Commit:    diagnostics.Commit,
+		BuildDate: diagnostics.BuildDate,
+	}
+}
+
 // GetUptimeInSeconds: Returns a value (as string) of the total system uptime
 func GetUptimeInSeconds() string {
 	uptime, _ := host.Uptime()
@@ -76,7 +112,7 @@ func GetHostname() string {
 }
 
 // SetupCloudOptions: Reads the designated env file looking for options to write into the options table. Meant to be used on initial setup. Deletes source env file after operation.
-func SetupCloudOptions() {
+func SetupCloudOptions(source options.Source) {
 
 	var cloudEnv map[string]string
 	cloudEnvFileLocationPath := utils.GetPath(utils.CloudEnvFileLocation)
@@ -87,31 +123,36 @@ func SetupCloudOptions() {
 	}
 
 	if cloudEnv["NAME"] != "" {
-		utils.WriteOption("NAME", cloudEnv["NAME"])
+		source.SetString("NAME", cloudEnv["NAME"])
 	}
 
 	if cloudEnv["EMAIL"] != "" {
-		utils.WriteOption("EMAIL", cloudEnv["EMAIL"])
+		source.SetString("EMAIL", cloudEnv["EMAIL"])
 	}
 
 	if cloudEnv["USERNAME"] != "" {
-		utils.WriteOption("USERNAME", cloudEnv["USERNAME"])
+		source.SetString("USERNAME", cloudEnv["USERNAME"])
 	}
 
 	if cloudEnv["CLUSTER"] != "" {
-		utils.WriteOption("CLUSTER", cloudEnv["CLUSTER"])
+		source.SetString("CLUSTER", cloudEnv["CLUSTER"])
 	}
 
 	if cloudEnv["CLUSTER_IP"] != "" {
-		utils.WriteOption("CLUSTER_IP", cloudEnv["CLUSTER_IP"])
+		source.SetString("CLUSTER_IP", cloudEnv["CLUSTER_IP"])
 	}
 
 	if cloudEnv["CLUSTER_SSH_PORT"] != "" {
-		utils.WriteOption("CLUSTER_SSH_PORT", cloudEnv["CLUSTER_SSH_PORT"])
+		port, err := strconv.ParseInt(cloudEnv["CLUSTER_SSH_PORT"], 10, 64)
+		if err == nil {
+			source.SetInt("CLUSTER_SSH_PORT", port)
+		} else {
+			log.Printf("Invalid CLUSTER_SSH_PORT %s in cloud env: %s", cloudEnv["CLUSTER_SSH_PORT"], err)
+		}
 	}
 
 	if cloudEnv["EDGEBOXIO_API_TOKEN"] != "" {
-		utils.WriteOption("EDGEBOXIO_API_TOKEN", cloudEnv["EDGEBOXIO_API_TOKEN"])
+		source.SetString("EDGEBOXIO_API_TOKEN", cloudEnv["EDGEBOXIO_API_TOKEN"])
 	}
 
 	// In the end of this operation takes place, remove the env file as to not overwrite any options once they are set.
@@ -161,7 +202,7 @@ func StartService(serviceID string) {
 func StopService(serviceID string) {
 	wsPath := utils.GetPath(utils.WsPath)
 	fmt.Println("Stopping" + serviceID + "service")
-	cmdargs := []string{"stop", "cloudflared"}
+	cmdargs := []string{"stop", serviceID}
 	utils.Exec(wsPath, "systemctl", cmdargs)
 }
 
@@ -189,8 +230,12 @@ func CreateBackupsPasswordFile(password string) {
 		os.MkdirAll(backupPasswordFileDir, 0755)
 	}
 
-	// Write the password to the file, overriting an existing file
-	err := ioutil.WriteFile(backupPasswordFile, []byte(password), 0644)
+	// Write the password to the file, overriting an existing file. Only root (running restic) needs to read it.
+	err := ioutil.WriteFile(backupPasswordFile, []byte(password), 0600)
+	if err == nil {
+		// WriteFile keeps the permissions of an existing file, written 0644 by older versions
+		err = os.Chmod(backupPasswordFile, 0600)
+	}
 	if err != nil {
 		panic(err)
 	}
@@ -199,7 +244,8 @@ func CreateBackupsPasswordFile(password string) {
 // CreateTunnel: Creates a tunnel via cloudflared, needs to be authenticated first
 func CreateTunnel(configDestination string) {
 	fmt.Println("Creating Tunnel for Edgebox.")
-	cmd := exec.Command("sh", "/home/system/components/edgeboxctl/scripts/cloudflared_tunnel_create.sh")
+	cmd := exec.Command("sh", utils.GetPath(utils.ScriptsPath) + "cloudflared_tunnel_create.sh")
+	cmd.Env = tunnelScriptEnv()
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		panic(err)
@@ -223,8 +269,8 @@ func CreateTunnel(configDestination string) {
 	// This also needs to be executed in root and non root variants
 	fmt.Println("Reading cloudflared folder to get the JSON file.")
 	isRoot := false
-	dir := "/home/system/.cloudflared/"
-	dir2 := "/root/.cloudflared/"
+	dir := utils.GetPath(utils.CloudflaredPath)
+	dir2 := utils.GetPath(utils.CloudflaredRootPath)
 	files, err := os.ReadDir(dir)
 	if err != nil {
 		panic(err)
@@ -260,9 +306,9 @@ func CreateTunnel(configDestination string) {
 	}
 
 	fmt.Println("Reading JSON file.")
-	targetDir := "/home/system/.cloudflared/"
+	targetDir := dir
 	if isRoot {
-		targetDir = "/root/.cloudflared/"
+		targetDir = dir2
 	}
 
 	jsonFilePath := filepath.Join(targetDir, jsonFile.Name())
@@ -305,7 +351,8 @@ func DeleteTunnel() {
 	fmt.Println("Deleting possible previous tunnel.")
 	
 	// Configure the service and start it
-	cmd := exec.Command("sh", "/home/system/components/edgeboxctl/scripts/cloudflared_tunnel_delete.sh")
+	cmd := exec.Command("sh", utils.GetPath(utils.ScriptsPath) + "cloudflared_tunnel_delete.sh")
+	cmd.Env = tunnelScriptEnv()
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		panic(err)
@@ -327,28 +374,127 @@ func DeleteTunnel() {
 	}
 }
 
-// InstallTunnelService: Installs the tunnel service
-func InstallTunnelService(config string) {
+// tunnelScriptEnv: Environment for the cloudflared helper scripts, pointing them to this instance tunnel and certificate.
+// Other instances than the primary one also get their own home, so cloudflared tunnel login does not replace the certificate of the primary one.
+func tunnelScriptEnv() []string {
+	env := append(
+		os.Environ(),
+		"EDGEBOX_TUNNEL_NAME="+config.Get().Services.TunnelName,
+		"TUNNEL_ORIGIN_CERT="+utils.GetPath(utils.CloudflaredPath)+"cert.pem",
+	)
+	if !config.Get().IsPrimaryInstance() {
+		env = append(env, "HOME="+config.Get().Instance.Root)
+	}
+	return env
+}
+
+// TunnelLoginCommand: Returns the command starting the cloudflared login of this instance
+func TunnelLoginCommand() *exec.Cmd {
+	cmd := exec.Command("sh", utils.GetPath(utils.ScriptsPath)+"cloudflared_login.sh")
+	cmd.Env = tunnelScriptEnv()
+	return cmd
+}
+
+// GetTunnelService: Returns the name of the system service running the tunnel
+func GetTunnelService() string {
+	return config.Get().Services.Tunnel
+}
+
+// GetTunnelName: Returns the name of the cloudflared tunnel owned by this instance
+func GetTunnelName() string {
+	return config.Get().Services.TunnelName
+}
+
+// GetBrowserDevService: Returns the name of the system service running the browser dev environment
+func GetBrowserDevService() string {
+	return config.Get().Services.BrowserDev
+}
+
+// InstallTunnelService: Installs the tunnel service. cloudflared service install only knows about a single, host-wide, cloudflared service,
+// so other instances than the primary one get their own systemd unit (services.tunnel) instead.
+func InstallTunnelService(tunnelConfig string) {
 	fmt.Println("Installing cloudflared service.")
-	cmd := exec.Command("cloudflared", "--config", config, "service", "install")
-	cmd.Start()
-	cmd.Wait()
+	if config.Get().IsPrimaryInstance() {
+		cmd := exec.Command("cloudflared", "--config", tunnelConfig, "service", "install")
+		cmd.Start()
+		cmd.Wait()
+		return
+	}
+
+	err := installTunnelUnit(tunnelConfig)
+	if err != nil {
+		log.Printf("Error installing %s service: %s", GetTunnelService(), err)
+	}
+}
+
+func getTunnelUnitPath() string {
+	return systemdUnitsPath + GetTunnelService() + ".service"
 }
 
-// RemoveTunnelService: Removes the tunnel service
+// installTunnelUnit: Copies the tunnel config to paths.cloudflared_service_config, and installs and enables a systemd unit running the tunnel with it
+func installTunnelUnit(tunnelConfig string) error {
+
+	serviceConfig := utils.GetPath(utils.CloudflaredServiceConfigLocation)
+	content, err := ioutil.ReadFile(tunnelConfig)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(serviceConfig), 0755)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(serviceConfig, content, 0644)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(getTunnelUnitPath(), []byte(tunnelUnit(serviceConfig)), 0644)
+	if err != nil {
+		return err
+	}
+
+	wsPath := utils.GetPath(utils.WsPath)
+	utils.Exec(wsPath, "systemctl", []string{"daemon-reload"})
+	utils.Exec(wsPath, "systemctl", []string{"enable", GetTunnelService()})
+	return nil
+}
+
+// tunnelUnit: Returns the systemd unit running the tunnel of this instance
+func tunnelUnit(serviceConfig string) string {
+	return "[Unit]\n" +
+		"Description=cloudflared tunnel of edgebox instance " + config.Get().Instance.Name + "\n" +
+		"After=network-online.target\n" +
+		"Wants=network-online.target\n\n" +
+		"[Service]\n" +
+		"TimeoutStartSec=0\n" +
+		"Type=notify\n" +
+		"ExecStart=/usr/bin/env cloudflared --no-autoupdate --config " + serviceConfig + " tunnel run\n" +
+		"Restart=on-failure\n" +
+		"RestartSec=5s\n\n" +
+		"[Install]\n" +
+		"WantedBy=multi-user.target\n"
+}
+
+// RemoveTunnelService: Removes the tunnel service and the tunnel files of this instance
 func RemoveTunnelService() {
 	wsPath := utils.GetPath(utils.WsPath)	
 	fmt.Println("Removing possibly previous service install.")
-	cmd := exec.Command("cloudflared", "service", "uninstall")
-	cmd.Start()
-	cmd.Wait()
+	if config.Get().IsPrimaryInstance() {
+		cmd := exec.Command("cloudflared", "service", "uninstall")
+		cmd.Start()
+		cmd.Wait()
+	} else {
+		utils.Exec(wsPath, "systemctl", []string{"disable", "--now", GetTunnelService()})
+		os.Remove(getTunnelUnitPath())
+		utils.Exec(wsPath, "systemctl", []string{"daemon-reload"})
+	}
 
 	fmt.Println("Removing cloudflared files")
-	cmdargs := []string{"-rf", "/home/system/.cloudflared"}
+	cmdargs := []string{"-rf", utils.GetPath(utils.CloudflaredPath)}
 	utils.Exec(wsPath, "rm", cmdargs)
-	cmdargs = []string{"-rf", "/etc/cloudflared/config.yml"}
+	cmdargs = []string{"-rf", utils.GetPath(utils.CloudflaredServiceConfigLocation)}
 	utils.Exec(wsPath, "rm", cmdargs)
-	cmdargs = []string{"-rf", "/root/.cloudflared/cert.pem"}
+	cmdargs = []string{"-rf", utils.GetPath(utils.CloudflaredRootPath) + "cert.pem"}
 	utils.Exec(wsPath, "rm", cmdargs)
 }
 
@@ -427,11 +573,11 @@ func CopyFile(src string, dest string) error {
     return nil
 }
 
-func CheckUpdates() {
+func CheckUpdates(source options.Source) {
 	fmt.Println("Checking for Edgebox System Updates.")
 	
 	// Configure the service and start it
-	cmd := exec.Command("sh", "/home/system/components/updater/run.sh", "--check")
+	cmd := exec.Command("sh", utils.GetPath(utils.UpdaterPath) + "run.sh", "--check")
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		panic(err)
@@ -450,50 +596,57 @@ func CheckUpdates() {
 		cmd.Process.Kill()
 		cmd.Wait()
 		fmt.Println("Error running updates check.")
-		utils.WriteOption("SYSTEM_UPDATES", "[]")
+		source.SetJSON("SYSTEM_UPDATES", []Update{})
 		return
 	}
 
-	// Read targets.env file into JSON list structure
-	targets := []string{}
-	targetsFile, err := os.Open("/home/system/components/updater/targets.env")
+	// Read targets.env file into a list of updates
+	targets := []Update{}
+	targetsFile, err := os.Open(utils.GetPath(utils.UpdaterPath) + "targets.env")
 	if err != nil {
 		fmt.Println("No targets.env file found. Skipping.")
-		utils.WriteOption("SYSTEM_UPDATES", "[]")
+		source.SetJSON("SYSTEM_UPDATES", targets)
 		return
 	}
 	defer targetsFile.Close()
 	scanner = bufio.NewScanner(targetsFile)
 	for scanner.Scan() {
-		text := scanner.Text()
-		// text line should look like: {"target": "<target>", "version": "<version>"}
-		target := strings.Split(text, "=")
-		newText := "{\"target\": \"" + strings.Replace(target[0], "_VERSION", "", -1) + "\", \"version\": \"" + target[1] + "\"}"
-		targets = append(targets, newText)
+		// text line should look like: <TARGET>_VERSION=<version>
+		target := strings.SplitN(scanner.Text(), "=", 2)
+		if len(target) != 2 {
+			continue
+		}
+		targets = append(targets, Update{
+			Target:  strings.Replace(target[0], "_VERSION", "", -1),
+			Version: target[1],
+		})
 	}
 	if scanner.Err() != nil {
 		fmt.Println("Error reading update targets file.")
-		utils.WriteOption("SYSTEM_UPDATES", "[]")
+		source.SetJSON("SYSTEM_UPDATES", []Update{})
 		return
 	}
 
-	// convert targets to string
-	targetsString := strings.Join(targets, ",")
-	targetsString = "[" + targetsString + "]"
-
-	fmt.Println(targetsString)
+	fmt.Println(targets)
 
 	// Write option with targets
-	utils.WriteOption("SYSTEM_UPDATES", targetsString)
+	source.SetJSON("SYSTEM_UPDATES", targets)
+}
+
+// GetAvailableUpdates : Returns the updates found by the last call to CheckUpdates
+func GetAvailableUpdates() ([]Update, error) {
+	updates := []Update{}
+	err := options.GetJSON("SYSTEM_UPDATES", &updates)
+	return updates, err
 }
 
-func ApplyUpdates() {
+func ApplyUpdates(source options.Source) {
 	fmt.Println("Applying Edgebox System Updates.")
 
-	utils.WriteOption("UPDATING_SYSTEM", "true")
+	source.SetBool("UPDATING_SYSTEM", true)
 	
 	// Configure the service and start it
-	cmd := exec.Command("sh", "/home/system/components/updater/run.sh", "--update")
+	cmd := exec.Command("sh", utils.GetPath(utils.UpdaterPath) + "run.sh", "--update")
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		panic(err)
@@ -515,7 +668,7 @@ func ApplyUpdates() {
 	}
 
 	// If the system did not yet restart, set updating system to false
-	utils.WriteOption("UPDATING_SYSTEM", "false")
+	source.SetBool("UPDATING_SYSTEM", false)
 }
 
 func FetchBrowserDevPasswordFromFile() (string, error) {
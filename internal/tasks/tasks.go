@@ -12,11 +12,16 @@ import (
 	"os"
 	"bufio"
 
+	"github.com/edgebox-iot/edgeboxctl/internal/catalog"
 	"github.com/edgebox-iot/edgeboxctl/internal/diagnostics"
 	"github.com/edgebox-iot/edgeboxctl/internal/edgeapps"
+	"github.com/edgebox-iot/edgeboxctl/internal/events"
+	"github.com/edgebox-iot/edgeboxctl/internal/metrics"
+	"github.com/edgebox-iot/edgeboxctl/internal/options"
 	"github.com/edgebox-iot/edgeboxctl/internal/storage"
 	"github.com/edgebox-iot/edgeboxctl/internal/system"
 	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+	"github.com/edgebox-iot/edgeboxctl/internal/webhooks"
 
 	"github.com/joho/godotenv"
 
@@ -50,6 +55,14 @@ type taskSetupTunnelArgs struct {
 	DomainName string `json:"domain_name"`
 }
 
+// tunnelStatus : Value of the TUNNEL_STATUS option
+type tunnelStatus struct {
+	Status    string `json:"status"`
+	LoginLink string `json:"login_link,omitempty"`
+	Domain    string `json:"domain,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
 type taskStartEdgeAppArgs struct {
 	ID string `json:"id"`
 }
@@ -58,12 +71,41 @@ type taskInstallEdgeAppArgs struct {
 	ID string `json:"id"`
 }
 
+type taskInstallEdgeAppFromCatalogArgs struct {
+	ID      string `json:"id"`
+	Version string `json:"version"` // Empty for the newest one
+}
+
+type taskUpgradeEdgeAppArgs struct {
+	ID      string `json:"id"`
+	Version string `json:"version"` // Empty for the newest one in the catalog
+}
+
+type taskGetEdgeAppLogsArgs struct {
+	ID       string   `json:"id"`
+	Services []string `json:"services"` // Every service of the EdgeApp when empty
+	Tail     int      `json:"tail"`     // Lines of each service, defaults to 200
+	Since    string   `json:"since"`    // RFC 3339 timestamp, unix timestamp or duration (ex: 10m)
+	File     bool     `json:"file"`     // Writes the logs to a file, instead of the task result
+}
+
+type taskExportEdgeAppArgs struct {
+	ID   string `json:"id"`
+	Path string `json:"path"` // Empty for a new file in the exports folder
+}
+
+type taskImportEdgeAppArgs struct {
+	Path    string `json:"path"`
+	Replace bool   `json:"replace"` // Confirms replacing an EdgeApp with the same ID
+}
+
 type taskInstallBulkEdgeAppsArgs struct {
 	IDS []string `json:"ids"`
 }
 
 type taskRemoveEdgeAppArgs struct {
-	ID string `json:"id"`
+	ID      string `json:"id"`
+	Cascade bool   `json:"cascade"` // Confirms removing the EdgeApps requiring it too
 }
 
 type taskStopEdgeAppArgs struct {
@@ -81,6 +123,11 @@ type taskSetEdgeAppBasicAuthArgs struct {
 	Login TaskBasicAuth `json:"login"`
 }
 
+type taskSetEdgeAppLimitsArgs struct {
+	ID     string                  `json:"id"`
+	Limits edgeapps.ManifestLimits `json:"limits"` // Empty values fall back to the manifest
+}
+
 type taskRemoveEdgeAppBasicAuthArgs struct {
 	ID string `json:"id"`
 }
@@ -114,17 +161,120 @@ type taskSetBrowserDevPasswordArgs struct {
 	Password string `json:"password"`
 }
 
+type taskAddWebhookArgs struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+type taskRemoveWebhookArgs struct {
+	ID string `json:"id"`
+}
+
 
 const STATUS_CREATED int = 0
 const STATUS_EXECUTING int = 1
 const STATUS_FINISHED int = 2
 const STATUS_ERROR int = 3
 
+// scheduleSource : Source of the options written by the schedules, and by the reactions to options edited outside of tasks they check for
+const scheduleSource options.Source = "schedule"
+
+// knownTasks : Every task name handled in ExecuteTask. Register new tasks here too, so they can be queued via the control API.
+var knownTasks = []string{
+	"setup_backups",
+	"start_backup",
+	"restore_backup",
+	"setup_tunnel",
+	"start_tunnel",
+	"stop_tunnel",
+	"disable_tunnel",
+	"start_shell",
+	"stop_shell",
+	"activate_browser_dev",
+	"install_edgeapp",
+	"install_bulk_edgeapps",
+	"install_edgeapp_from_catalog",
+	"upgrade_edgeapp",
+	"export_edgeapp",
+	"get_edgeapp_logs",
+	"import_edgeapp",
+	"remove_edgeapp",
+	"start_edgeapp",
+	"stop_edgeapp",
+	"set_edgeapp_options",
+	"set_edgeapp_basic_auth",
+	"set_edgeapp_limits",
+	"remove_edgeapp_basic_auth",
+	"enable_online",
+	"disable_online",
+	"enable_public_dashboard",
+	"disable_public_dashboard",
+	"check_updates",
+	"apply_updates",
+	"set_browserdev_password",
+	"activate_browserdev",
+	"deactivate_browserdev",
+	"add_webhook",
+	"remove_webhook",
+}
+
+// Metrics recorded for every executed task, labeled by task type and final status
+const (
+	METRIC_TASKS_TOTAL           string = "edgeboxctl_tasks_total"
+	METRIC_TASK_DURATION_SECONDS string = "edgeboxctl_task_duration_seconds"
+)
+
+func init() {
+	metrics.Describe(METRIC_TASKS_TOTAL, "Number of tasks executed, by task type and final status.", metrics.COUNTER)
+	metrics.Describe(METRIC_TASK_DURATION_SECONDS, "Time spent executing tasks, by task type and final status.", metrics.SUMMARY)
+
+	// Reactions to options edited outside of tasks (ex: by the dashboard), checked by the schedules
+	options.OnChange("DOMAIN_NAME", reactDomainNameChange)
+}
+
+// reactDomainNameChange : Moves EdgeApps on their default URL to the new domain name (or back to the local one when it is removed)
+func reactDomainNameChange(change options.Change) {
+	source := scheduleSource
+	log.Printf("Domain name changed from \"%s\" to \"%s\", updating EdgeApps default URLs", change.OldValue, change.NewValue)
+	updated := edgeapps.UpdateDefaultInternetURLs(change.OldValue, change.NewValue)
+	if len(updated) > 0 {
+		log.Printf("Updated default URLs of %s", strings.Join(updated, ", "))
+		taskGetEdgeApps(source)
+	}
+}
+
+// recordTaskMetrics : Accounts an executed task and how long it took
+func recordTaskMetrics(task Task, status string, duration time.Duration) {
+	taskType := task.Task
+	if !IsKnownTask(taskType) {
+		taskType = "unknown"
+	}
+
+	labels := metrics.Labels{"task": taskType, "status": status}
+	metrics.Add(METRIC_TASKS_TOTAL, METRIC_TASKS_TOTAL, labels, 1)
+	metrics.Add(METRIC_TASK_DURATION_SECONDS, METRIC_TASK_DURATION_SECONDS+"_sum", labels, duration.Seconds())
+	metrics.Add(METRIC_TASK_DURATION_SECONDS, METRIC_TASK_DURATION_SECONDS+"_count", labels, 1)
+}
+
+// GetQueueDepth : Returns the number of tasks waiting to be executed
+func GetQueueDepth() (int, error) {
+
+	db, err := utils.GetDatabase()
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM task WHERE status = ?;", STATUS_CREATED).Scan(&count)
+	return count, err
+}
+
 // GetNextTask : Performs a MySQL query over the device's Edgebox API
 func GetNextTask() Task {
 
 	// Will try to connect to API database, which should be running locally under WS.
-	db, err := sql.Open("sqlite3", utils.GetSQLiteDbConnectionDetails())
+	db, err := utils.GetDatabase()
 
 	// if there is an error opening the connection, handle it
 	if err != nil {
@@ -150,16 +300,104 @@ func GetNextTask() Task {
 	}
 
 	results.Close()
-	db.Close()
 
 	return task
 
 }
 
+// GetTask : Returns the task with the given ID from the device's Edgebox API database. Valid is false when it does not exist.
+func GetTask(ID int) (Task, bool, error) {
+
+	var task Task
+
+	db, err := utils.GetDatabase()
+	if err != nil {
+		return task, false, err
+	}
+
+	err = db.QueryRow("SELECT id, task, args, status, result, created, updated FROM task WHERE id = ?;", ID).Scan(&task.ID, &task.Task, &task.Args, &task.Status, &task.Result, &task.Created, &task.Updated)
+	if err == sql.ErrNoRows {
+		return task, false, nil
+	}
+	if err != nil {
+		return task, false, err
+	}
+
+	return task, true, nil
+}
+
+// CreateTask : Queues a new task in the device's Edgebox API database, exactly as the dashboard does. It will be picked up by the system iterator.
+func CreateTask(name string, args string) (Task, error) {
+
+	var task Task
+
+	if !IsKnownTask(name) {
+		return task, fmt.Errorf("unknown task %s", name)
+	}
+
+	db, err := utils.GetDatabase()
+	if err != nil {
+		return task, err
+	}
+
+	formatedDatetime := utils.GetSQLiteFormattedDateTime(time.Now())
+	taskArgs := sql.NullString{String: args, Valid: args != ""}
+
+	result, err := db.Exec("INSERT INTO task (task, args, status, created, updated) VALUES (?, ?, ?, ?, ?);", name, taskArgs, STATUS_CREATED, formatedDatetime, formatedDatetime)
+	if err != nil {
+		return task, err
+	}
+
+	ID, err := result.LastInsertId()
+	if err != nil {
+		return task, err
+	}
+
+	task, _, err = GetTask(int(ID))
+	if err == nil {
+		events.Publish(events.TASK_CREATED, newTaskEventData(task, STATUS_CREATED))
+	}
+
+	return task, err
+}
+
+// newTaskEventData : Returns the payload published in task lifecycle events. Args and results are left out, as they can carry credentials
+// (ex: setup_backups, set_edgeapp_basic_auth) and events reach every subscriber. They are available from the task itself.
+func newTaskEventData(task Task, status int) map[string]interface{} {
+	return map[string]interface{}{
+		"id":      task.ID,
+		"task":    task.Task,
+		"status":  status,
+		"created": task.Created,
+		"updated": utils.GetSQLiteFormattedDateTime(time.Now()),
+	}
+}
+
+// taskSource : Returns the source of the options written by a task, ex: task:setup_tunnel#12
+func taskSource(task Task) options.Source {
+	return options.Source("task:" + task.Task + "#" + strconv.Itoa(task.ID))
+}
+
+// setTunnelStatus : Writes the TUNNEL_STATUS option and publishes the change
+func setTunnelStatus(source options.Source, status tunnelStatus) {
+	source.SetJSON("TUNNEL_STATUS", status)
+	events.Publish(events.TUNNEL_STATUS_CHANGED, status)
+}
+
+// IsKnownTask : Returns true if the given task name is handled by ExecuteTask
+func IsKnownTask(name string) bool {
+	for _, knownTask := range knownTasks {
+		if knownTask == name {
+			return true
+		}
+	}
+	return false
+}
+
 // GetExecutingTasks : Performs a MySQL query over the device's Edgebox API to obtain all tasks that are currently executing
 func GetExecutingTasks() []Task {
 	// Will try to connect to API database, which should be running locally under WS.
-	db, err := sql.Open("sqlite3", utils.GetSQLiteDbConnectionDetails())
+	db, err := utils.GetDatabase()
 	if err != nil {
 		panic(err.Error())
 	}
@@ -179,14 +417,13 @@ func GetExecutingTasks() []Task {
 		tasks = append(tasks, task)
 	}
 	results.Close()
-	db.Close()
 	return tasks
 }
 
 // ExecuteTask : Performs execution of the given task, updating the task status as it goes, and publishing the task result
 func ExecuteTask(task Task) Task {
 
-	db, err := sql.Open("sqlite3", utils.GetSQLiteDbConnectionDetails())
+	db, err := utils.GetDatabase()
 
 	if err != nil {
 		panic(err.Error())
@@ -205,6 +442,14 @@ func ExecuteTask(task Task) Task {
 		log.Fatal(err.Error())
 	}
 
+	statement.Close()
+
+	events.Publish(events.TASK_STARTED, newTaskEventData(task, STATUS_EXECUTING))
+	startedAt := time.Now()
+
+	// Options written by the task, including the ones written in the background after it finished (ex: setup_tunnel), are attributed to it in the option history
+	source := taskSource(task)
+
 	if diagnostics.GetReleaseVersion() == diagnostics.DEV_VERSION {
 		log.Printf("Dev environemnt. Not executing tasks.")
 	} else {
@@ -219,7 +464,7 @@ func ExecuteTask(task Task) Task {
 			if err != nil {
 				log.Println("Error reading arguments of setup_backups task.")
 			} else {
-				taskResult := taskSetupBackups(args)
+				taskResult := taskSetupBackups(source, args)
 				taskResultBool := true
 				// Check if returned taskResult string contains "error"
 				if strings.Contains(taskResult, "error") {
@@ -231,7 +476,7 @@ func ExecuteTask(task Task) Task {
 		case "start_backup":
 
 			log.Println("Backing up Edgebox...")
-			taskResult := taskBackup()
+			taskResult := taskBackup(source)
 			taskResultBool := true
 			// Check if returned taskResult string contains "error"
 			if strings.Contains(taskResult, "error") {
@@ -241,7 +486,7 @@ func ExecuteTask(task Task) Task {
 
 		case "restore_backup":
 			log.Println("Attempting to Restore Last Backup to Edgebox")
-			taskResult := taskRestoreBackup()
+			taskResult := taskRestoreBackup(source)
 			taskResultBool := true
 			// Check if returned taskResult string contains "error"
 			if strings.Contains(taskResult, "error") {
@@ -256,29 +501,28 @@ func ExecuteTask(task Task) Task {
 			err := json.Unmarshal([]byte(task.Args.String), &args)
 			if err != nil {
 				log.Printf("Error reading arguments of setup_tunnel task: %s", err)
-				status := "{\"status\": \"error\", \"message\": \"The Domain Name you are going to Authorize must be provided beforehand! Please insert a domain name and try again.\"}"
-				utils.WriteOption("TUNNEL_STATUS", status)
+				setTunnelStatus(source, tunnelStatus{Status: "error", Message: "The Domain Name you are going to Authorize must be provided beforehand! Please insert a domain name and try again."})
 			} else {
-				taskResult := taskSetupTunnel(args)
+				taskResult := taskSetupTunnel(source, args)
 				task.Result = sql.NullString{String: taskResult, Valid: true}
 			}
 
 		case "start_tunnel":
 
 			log.Println("Starting Cloudflare Tunnel...")
-			taskResult := taskStartTunnel()
+			taskResult := taskStartTunnel(source)
 			task.Result = sql.NullString{String: taskResult, Valid: true}
 
 		case "stop_tunnel":
 
 			log.Println("Stopping Cloudflare Tunnel...")
-			taskResult := taskStopTunnel()
+			taskResult := taskStopTunnel(source)
 			task.Result = sql.NullString{String: taskResult, Valid: true}
 		
 		case "disable_tunnel":
 
 			log.Println("Disabling Cloudflare Tunnel...")
-			taskResult := taskDisableTunnel()
+			taskResult := taskDisableTunnel(source)
 			task.Result = sql.NullString{String: taskResult, Valid: true}
 
 		case "start_shell":
@@ -288,18 +532,18 @@ func ExecuteTask(task Task) Task {
 			if err != nil {
 				log.Printf("Error reading arguments or start_shell task: %s", err)
 			} else {
-				taskResult := taskStartShell(args)
+				taskResult := taskStartShell(source, args)
 				task.Result = sql.NullString{String: taskResult, Valid: true}												
 			}
 
 		case "stop_shell":
 			log.Println("Stopping SSHX.io Shell...")
-			taskResult := taskStopShell()
+			taskResult := taskStopShell(source)
 			task.Result = sql.NullString{String: taskResult, Valid: true}
 
 		case "activate_browser_dev":
 			log.Println("Activating Browser Dev Environment")
-			taskResult := taskActivateBrowserDev()
+			taskResult := taskActivateBrowserDev(source)
 			task.Result = sql.NullString{String: taskResult, Valid: true}
 
 		case "install_edgeapp":
@@ -310,7 +554,7 @@ func ExecuteTask(task Task) Task {
 			if err != nil {
 				log.Printf("Error reading arguments of install_edgeapp task: %s", err)
 			} else {
-				taskResult := taskInstallEdgeApp(args)
+				taskResult := taskInstallEdgeApp(source, args)
 				task.Result = sql.NullString{String: taskResult, Valid: true}
 			}
 
@@ -322,10 +566,71 @@ func ExecuteTask(task Task) Task {
 			if err != nil {
 				log.Printf("Error reading arguments of install_bulk_edgeapps task: %s", err)
 			} else {
-				taskResult := taskInstallBulkEdgeApps(args)
+				taskResult := taskInstallBulkEdgeApps(source, args)
 				task.Result = sql.NullString{String: taskResult, Valid: true}
 			}
 
+		case "install_edgeapp_from_catalog":
+
+			log.Println("Installing EdgeApp from the catalog...")
+			var args taskInstallEdgeAppFromCatalogArgs
+			err := json.Unmarshal([]byte(task.Args.String), &args)
+			if err != nil {
+				log.Printf("Error reading arguments of install_edgeapp_from_catalog task: %s", err)
+			} else {
+				taskResult := taskInstallEdgeAppFromCatalog(source, args)
+				task.Result = sql.NullString{String: taskResult, Valid: !strings.Contains(taskResult, "error")}
+			}
+
+		case "upgrade_edgeapp":
+
+			log.Println("Upgrading EdgeApp...")
+			var args taskUpgradeEdgeAppArgs
+			err := json.Unmarshal([]byte(task.Args.String), &args)
+			if err != nil {
+				log.Printf("Error reading arguments of upgrade_edgeapp task: %s", err)
+			} else {
+				taskResult := taskUpgradeEdgeApp(source, args)
+				task.Result = sql.NullString{String: taskResult, Valid: !strings.Contains(taskResult, "error")}
+			}
+
+		case "export_edgeapp":
+
+			log.Println("Exporting EdgeApp...")
+			var args taskExportEdgeAppArgs
+			err := json.Unmarshal([]byte(task.Args.String), &args)
+			if err != nil {
+				log.Printf("Error reading arguments of export_edgeapp task: %s", err)
+			} else {
+				taskResult := taskExportEdgeApp(source, args)
+				task.Result = sql.NullString{String: taskResult, Valid: !strings.Contains(taskResult, "error")}
+			}
+
+		case "get_edgeapp_logs":
+
+			log.Println("Getting EdgeApp Logs...")
+			var args taskGetEdgeAppLogsArgs
+			err := json.Unmarshal([]byte(task.Args.String), &args)
+			if err != nil {
+				log.Printf("Error reading arguments of get_edgeapp_logs task: %s", err)
+			} else {
+				taskResult := taskGetEdgeAppLogs(args)
+				// The logs themselves may mention errors, so only the status tells a failure
+				task.Result = sql.NullString{String: taskResult, Valid: !strings.HasPrefix(taskResult, "{\"status\": \"error\"")}
+			}
+
+		case "import_edgeapp":
+
+			log.Println("Importing EdgeApp...")
+			var args taskImportEdgeAppArgs
+			err := json.Unmarshal([]byte(task.Args.String), &args)
+			if err != nil {
+				log.Printf("Error reading arguments of import_edgeapp task: %s", err)
+			} else {
+				taskResult := taskImportEdgeApp(source, args)
+				task.Result = sql.NullString{String: taskResult, Valid: !strings.Contains(taskResult, "error")}
+			}
+
 		case "remove_edgeapp":
 
 			log.Println("Removing EdgeApp...")
@@ -334,7 +639,7 @@ func ExecuteTask(task Task) Task {
 			if err != nil {
 				log.Printf("Error reading arguments of remove_edgeapp task: %s", err)
 			} else {
-				taskResult := taskRemoveEdgeApp(args)
+				taskResult := taskRemoveEdgeApp(source, args)
 				task.Result = sql.NullString{String: taskResult, Valid: true}
 			}
 
@@ -346,7 +651,7 @@ func ExecuteTask(task Task) Task {
 			if err != nil {
 				log.Printf("Error reading arguments of start_edgeapp task: %s", err)
 			} else {
-				taskResult := taskStartEdgeApp(args)
+				taskResult := taskStartEdgeApp(source, args)
 				task.Result = sql.NullString{String: taskResult, Valid: true}
 			}
 
@@ -358,7 +663,7 @@ func ExecuteTask(task Task) Task {
 			if err != nil {
 				log.Printf("Error reading arguments of stop_edgeapp task: %s", err)
 			} else {
-				taskResult := taskStopEdgeApp(args)
+				taskResult := taskStopEdgeApp(source, args)
 				task.Result = sql.NullString{String: taskResult, Valid: true}
 			}
 
@@ -371,8 +676,9 @@ func ExecuteTask(task Task) Task {
 			if err != nil {
 				log.Printf("Error reading arguments of set_edgeapp_options task: %s", err)
 			} else {
-				taskResult := taskSetEdgeAppOptions(args)
-				task.Result = sql.NullString{String: taskResult, Valid: true}
+				taskResult := taskSetEdgeAppOptions(source, args)
+				// The EdgeApp returned on success holds option values, so only the status tells a failure
+				task.Result = sql.NullString{String: taskResult, Valid: !strings.HasPrefix(taskResult, "{\"status\": \"error\"")}
 			}
 
 		case "set_edgeapp_basic_auth":
@@ -383,10 +689,23 @@ func ExecuteTask(task Task) Task {
 			if err != nil {
 				log.Printf("Error reading arguments of set_edgeapp_basic_auth task: %s", err)
 			} else {
-				taskResult := taskSetEdgeAppBasicAuth(args)
+				taskResult := taskSetEdgeAppBasicAuth(source, args)
 				task.Result = sql.NullString{String: taskResult, Valid: true}
 			}
 
+		case "set_edgeapp_limits":
+
+			log.Println("Setting EdgeApp Limits...")
+			var args taskSetEdgeAppLimitsArgs
+			err := json.Unmarshal([]byte(task.Args.String), &args)
+			if err != nil {
+				log.Printf("Error reading arguments of set_edgeapp_limits task: %s", err)
+			} else {
+				taskResult := taskSetEdgeAppLimits(source, args)
+				// The EdgeApp returned on success may mention errors, so only the status tells a failure
+				task.Result = sql.NullString{String: taskResult, Valid: !strings.HasPrefix(taskResult, "{\"status\": \"error\"")}
+			}
+
 		case "remove_edgeapp_basic_auth":
 
 			log.Println("Removing EdgeApp Basic Authentication...")
@@ -395,7 +714,7 @@ func ExecuteTask(task Task) Task {
 			if err != nil {
 				log.Printf("Error reading arguments of remove_edgeapp_basic_auth task: %s", err)
 			} else {
-				taskResult := taskRemoveEdgeAppBasicAuth(args)
+				taskResult := taskRemoveEdgeAppBasicAuth(source, args)
 				task.Result = sql.NullString{String: taskResult, Valid: true}
 			}
 
@@ -407,7 +726,7 @@ func ExecuteTask(task Task) Task {
 			if err != nil {
 				log.Printf("Error reading arguments of enable_online task: %s", err)
 			} else {
-				taskResult := taskEnableOnline(args)
+				taskResult := taskEnableOnline(source, args)
 				task.Result = sql.NullString{String: taskResult, Valid: true}
 			}
 
@@ -419,7 +738,7 @@ func ExecuteTask(task Task) Task {
 			if err != nil {
 				log.Printf("Error reading arguments of enable_online task: %s", err)
 			} else {
-				taskResult := taskDisableOnline(args)
+				taskResult := taskDisableOnline(source, args)
 				task.Result = sql.NullString{String: taskResult, Valid: true}
 			}
 
@@ -431,32 +750,32 @@ func ExecuteTask(task Task) Task {
 			if err != nil {
 				log.Printf("Error reading arguments of enable_public_dashboard task: %s", err)
 			} else {
-				taskResult := taskEnablePublicDashboard(args)
+				taskResult := taskEnablePublicDashboard(source, args)
 				task.Result = sql.NullString{String: taskResult, Valid: true}
 			}
 
 		case "disable_public_dashboard":
 
 			log.Println("Disabling online access to Dashboard...")
-			taskResult := taskDisablePublicDashboard()
+			taskResult := taskDisablePublicDashboard(source)
 			task.Result = sql.NullString{String: taskResult, Valid: true}
 
 		case "check_updates":
 			log.Println("Checking for updates...")
-			taskResult := taskCheckSystemUpdates()
+			taskResult := taskCheckSystemUpdates(source)
 			task.Result = sql.NullString{String: taskResult, Valid: true}
 
 		case "apply_updates":
 
 			log.Println("Updating Edgebox System...")
-			is_updating := utils.ReadOption("UPDATING_SYSTEM")
-			if is_updating == "true"  {
+			is_updating, _ := options.GetBool("UPDATING_SYSTEM")
+			if is_updating {
 				log.Println("Edgebox update was running... Probably system restarted. Finishing update...")
-				utils.WriteOption("UPDATING_SYSTEM", "false")
+				source.SetBool("UPDATING_SYSTEM", false)
 				task.Result = sql.NullString{String: "{result: true}", Valid: true}
 			} else {
 				log.Println("Updating Edgebox System...")
-				taskResult := taskUpdateSystem()
+				taskResult := taskUpdateSystem(source)
 				task.Result = sql.NullString{String: taskResult, Valid: true}
 			}
 
@@ -468,22 +787,46 @@ func ExecuteTask(task Task) Task {
 			if err != nil {
 				log.Printf("Error reading arguments of set_browserdev_password task: %s", err)
 			} else {
-				taskResult := taskSetBrowserDevPassword(args)
+				taskResult := taskSetBrowserDevPassword(source, args)
 				task.Result = sql.NullString{String: taskResult, Valid: true}
 			}
 
 		case "activate_browserdev":
 
 			log.Println("Activating BrowserDev Environment...")
-			taskResult := taskActivateBrowserDev()
+			taskResult := taskActivateBrowserDev(source)
 			task.Result = sql.NullString{String: taskResult, Valid: true}
 
 		case "deactivate_browserdev":
 
 			log.Println("Deactivating BrowserDev Environment...")
-			taskResult := taskDeactivateBrowserDev()
+			taskResult := taskDeactivateBrowserDev(source)
 			task.Result = sql.NullString{String: taskResult, Valid: true}
 
+		case "add_webhook":
+
+			log.Println("Adding Webhook...")
+			var args taskAddWebhookArgs
+			err := json.Unmarshal([]byte(task.Args.String), &args)
+			if err != nil {
+				log.Printf("Error reading arguments of add_webhook task: %s", err)
+			} else {
+				taskResult := taskAddWebhook(source, args)
+				task.Result = sql.NullString{String: taskResult, Valid: !strings.Contains(taskResult, "error")}
+			}
+
+		case "remove_webhook":
+
+			log.Println("Removing Webhook...")
+			var args taskRemoveWebhookArgs
+			err := json.Unmarshal([]byte(task.Args.String), &args)
+			if err != nil {
+				log.Printf("Error reading arguments of remove_webhook task: %s", err)
+			} else {
+				taskResult := taskRemoveWebhook(source, args)
+				task.Result = sql.NullString{String: taskResult, Valid: !strings.Contains(taskResult, "error")}
+			}
+
 		}
 
 	}
@@ -505,19 +848,25 @@ func ExecuteTask(task Task) Task {
 			log.Fatal(err.Error())
 		}
 
+		recordTaskMetrics(task, "finished", time.Since(startedAt))
+		events.Publish(events.TASK_FINISHED, newTaskEventData(task, STATUS_FINISHED))
+
 	} else {
 		fmt.Println("Error executing task with result: " + task.Result.String)
 		_, err = statement.Exec(STATUS_ERROR, "Error", formatedDatetime, strconv.Itoa(task.ID)) // Execute SQL Statement with Error info
 		if err != nil {
 			log.Fatal(err.Error())
 		}
+
+		recordTaskMetrics(task, "error", time.Since(startedAt))
+		events.Publish(events.TASK_FAILED, newTaskEventData(task, STATUS_ERROR))
 	}
 
 	if err != nil {
 		panic(err.Error())
 	}
 
-	db.Close()
+	statement.Close()
 
 	returnTask := task
 
@@ -528,74 +877,85 @@ func ExecuteTask(task Task) Task {
 // ExecuteSchedules - Run Specific tasks without input each multiple x of ticks.
 func ExecuteSchedules(tick int) {
 
+	source := scheduleSource
+
 	if tick == 1 {
 
+		// Before anything reads or writes secrets, so none is left in plaintext
+		taskEncryptSecrets()
+
 		log.Println("Fetching Browser Dev Environment Information")
-		taskGetBrowserDevPassword()
-		taskGetBrowserDevStatus()
+		taskGetBrowserDevPassword(source)
+		taskGetBrowserDevStatus(source)
 
-		taskCheckSystemUpdates()
+		taskCheckSystemUpdates(source)
 		
-		ip := taskGetSystemIP()
+		ip := taskGetSystemIP(source)
 		log.Println("System IP is: " + ip)
 
-		release := taskSetReleaseVersion()
+		release := taskSetReleaseVersion(source)
 		log.Println("Setting api option flag for Edgeboxctl (" + release + " version)")
 
-		hostname := taskGetHostname()
+		hostname := taskGetHostname(source)
 		log.Println("Hostname is " + hostname)
 
 		// if diagnostics.Version == "cloud" && !edgeapps.IsPublicDashboard() {
-		// 	taskEnablePublicDashboard(taskEnablePublicDashboardArgs{
+		// 	taskEnablePublicDashboard(source, taskEnablePublicDashboardArgs{
 		// 		InternetURL: hostname + ".myedge.app",
 		// 	})
 		// }
 
 		if diagnostics.GetReleaseVersion() == diagnostics.CLOUD_VERSION {
 			log.Println("Setting up cloud version options (name, email, api token)")
-			taskSetupCloudOptions()
+			taskSetupCloudOptions(source)
 		}
 
 		// Executing on startup (first tick). Schedules run before tasks in the SystemIterator
-		uptime := taskGetSystemUptime()
+		uptime := taskGetSystemUptime(source)
 		log.Println("Uptime is " + uptime + " seconds (" + system.GetUptimeFormatted() + ")")
 
-		log.Println(taskGetStorageDevices())
+		log.Println(taskGetStorageDevices(source))
 		taskStartWs()
-		log.Println(taskGetEdgeApps())
+		log.Println(taskGetEdgeApps(source))
 		taskUpdateSystemLoggerServices()
 		taskRecoverFromUpdate()		
+
+		// Takes the snapshot later checks are compared with
+		options.CheckChanges()
+
+		// Each check runs as often as its interval says, off the tasks loop
+		edgeapps.StartHealthChecks()
 	}
 
 	if tick%5 == 0 {
 		// Executing every 5 ticks
-		taskGetSystemUptime()
-		log.Println(taskGetStorageDevices())
+		options.CheckChanges()
+		taskGetSystemUptime(source)
+		log.Println(taskGetStorageDevices(source))
 	}
 
 	if tick%15 == 0 {
-		taskGetBrowserDevStatus()
+		taskGetBrowserDevStatus(source)
 	}
 
 	if tick%30 == 0 {
 		// Executing every 30 ticks
-		log.Println(taskGetEdgeApps())
+		log.Println(taskGetEdgeApps(source))
 		taskUpdateSystemLoggerServices()
 		// RESET SOME VARIABLES HERE IF NEEDED, SINCE SYSTEM IS UNBLOCKED
-		utils.WriteOption("BACKUP_IS_WORKING", "0")
+		source.SetBool("BACKUP_IS_WORKING", false)
 
 		// Check is Last Backup time (in unix time) is older than 1 h
-		lastBackup := utils.ReadOption("BACKUP_LAST_RUN")
-		if lastBackup != "" {
-			lastBackupTime, err := strconv.ParseInt(lastBackup, 10, 64)
+		lastBackupTime, err := options.GetTime("BACKUP_LAST_RUN")
+		if !lastBackupTime.IsZero() || err != nil {
 			if err != nil {
 				log.Println("Error parsing last backup time: " + err.Error())
 			} else {
-				secondsSinceLastBackup := time.Now().Unix() - lastBackupTime
+				secondsSinceLastBackup := time.Now().Unix() - lastBackupTime.Unix()
 				if secondsSinceLastBackup > 3600 {
 					// If last backup is older than 1 hour, set BACKUP_IS_WORKING to 0
 					log.Println("Last backup was older than 1 hour, performing auto backup...")
-					log.Println(taskAutoBackup())
+					log.Println(taskAutoBackup(source))
 				} else {
 
 					log.Println("Last backup is " + fmt.Sprint(secondsSinceLastBackup) + " seconds old (less than 1 hour ago), skipping auto backup...")
@@ -609,14 +969,14 @@ func ExecuteSchedules(tick int) {
 	}
 
 	if tick%60 == 0 {
-		ip := taskGetSystemIP()
+		ip := taskGetSystemIP(source)
 		log.Println("System IP is: " + ip)
 	}
 
 	if tick%3600 == 0 {
 		// Executing every 3600 ticks (1 hour)
-		taskGetBrowserDevStatus()
-		taskCheckSystemUpdates()
+		taskGetBrowserDevStatus(source)
+		taskCheckSystemUpdates(source)
 
 	}
 
@@ -630,13 +990,13 @@ func ExecuteSchedules(tick int) {
 
 }
 
-func taskSetupBackups(args taskSetupBackupsArgs) string {
+func taskSetupBackups(source options.Source, args taskSetupBackupsArgs) string {
 	fmt.Println("Executing taskSetupBackups" + args.Service)
 	// ...
 	service_url := ""
 	key_id_name := "AWS_ACCESS_KEY_ID"
 	key_secret_name := "AWS_SECRET_ACCESS_KEY"
-	repo_location := "/home/system/components/apps/"
+	repo_location := utils.GetPath(utils.EdgeAppsPath)
 	service_found := false
 
 	switch args.Service {
@@ -667,68 +1027,98 @@ func taskSetupBackups(args taskSetupBackupsArgs) string {
 	system.CreateBackupsPasswordFile(args.RepositoryPassword)
 
 	fmt.Println("Initializing restic repository")
-	utils.WriteOption("BACKUP_IS_WORKING", "1")
+	source.SetBool("BACKUP_IS_WORKING", true)
+	events.Publish(events.BACKUP_STARTED, newBackupEventData("init", ""))
 
 	cmdArgs := []string{"-r", args.Service + ":" + service_url + args.RepositoryName + ":" + repo_location, "init", "--password-file", utils.GetPath(utils.BackupPasswordFileLocation), "--verbose=3"}
 	
-	result := utils.ExecAndStream(repo_location, "restic", cmdArgs)
-
-	utils.WriteOption("BACKUP_IS_WORKING", "0")
-
-	// Write backup settings to table
-	utils.WriteOption("BACKUP_SERVICE", args.Service)
-	utils.WriteOption("BACKUP_SERVICE_URL", service_url)
-	utils.WriteOption("BACKUP_REPOSITORY_NAME", args.RepositoryName)
-	utils.WriteOption("BACKUP_REPOSITORY_PASSWORD", args.RepositoryPassword)
-	utils.WriteOption("BACKUP_REPOSITORY_ACCESS_KEY_ID", args.AccessKeyID)
-	utils.WriteOption("BACKUP_REPOSITORY_SECRET_ACCESS_KEY", args.SecretAccessKey)
-	utils.WriteOption("BACKUP_REPOSITORY_LOCATION", repo_location)
+	result := utils.ExecAndStreamLines(repo_location, "restic", cmdArgs, newBackupProgressPublisher("init"))
+
+	source.SetBool("BACKUP_IS_WORKING", false)
+
+	// Write backup settings to table, all of them or none
+	err := source.SetMany(map[string]string{
+		"BACKUP_SERVICE":                      args.Service,
+		"BACKUP_SERVICE_URL":                  service_url,
+		"BACKUP_REPOSITORY_NAME":              args.RepositoryName,
+		"BACKUP_REPOSITORY_PASSWORD":          args.RepositoryPassword,
+		"BACKUP_REPOSITORY_ACCESS_KEY_ID":     args.AccessKeyID,
+		"BACKUP_REPOSITORY_SECRET_ACCESS_KEY": args.SecretAccessKey,
+		"BACKUP_REPOSITORY_LOCATION":          repo_location,
+	})
+	if err != nil {
+		fmt.Println("Error saving backup settings: " + err.Error())
+		events.Publish(events.BACKUP_FAILED, newBackupEventData("init", err.Error()))
+		return "{\"status\": \"error\", \"message\": \"Could not save backup settings\"}"
+	}
 
 	// See if result contains the substring "Fatal:"
 	if strings.Contains(result, "Fatal:") {
 		fmt.Println("Error initializing restic repository")
 
-		utils.WriteOption("BACKUP_STATUS", "error")
-		utils.WriteOption("BACKUP_ERROR_MESSAGE", result)
+		source.SetEnum("BACKUP_STATUS", "error")
+		source.SetString("BACKUP_ERROR_MESSAGE", result)
+		events.Publish(events.BACKUP_FAILED, newBackupEventData("init", result))
 
 		return "{\"status\": \"error\", \"message\": \"" + result + "\"}"
 	}
 
 	// Save options to database
-	utils.WriteOption("BACKUP_STATUS", "initiated")
+	source.SetEnum("BACKUP_STATUS", "initiated")
+	events.Publish(events.BACKUP_FINISHED, newBackupEventData("init", ""))
 
 	// Populate Stats right away
-	taskGetBackupStatus()
+	taskGetBackupStatus(source)
 	
 	return "{\"status\": \"ok\"}"
 	
 }
 
-func taskRemoveBackups() string {
+// newBackupEventData : Returns the payload published in backup events. Operation is one of init, backup or restore.
+func newBackupEventData(operation string, message string) map[string]string {
+	return map[string]string{
+		"operation": operation,
+		"message":   message,
+	}
+}
+
+// newBackupProgressPublisher : Returns a function publishing restic output lines as backup progress, at most once per second
+func newBackupProgressPublisher(operation string) func(line string) {
+	lastPublished := time.Time{}
+	return func(line string) {
+		if line == "" || time.Since(lastPublished) < time.Second {
+			return
+		}
+		lastPublished = time.Now()
+		events.Publish(events.BACKUP_PROGRESS, newBackupEventData(operation, line))
+	}
+}
+
+func taskRemoveBackups(source options.Source) string {
 
 	fmt.Println("Executing taskRemoveBackups")
 
 	// ...	This deletes the restic repository
 	// cmdArgs := []string{"-r", "s3:https://s3.amazonaws.com/edgebox-backups:/home/system/components/apps/", "forget", "latest", "--password-file", utils.GetPath(utils.BackupPasswordFileLocation), "--verbose=3"}
 	
-	utils.WriteOption("BACKUP_STATUS", "")
-	utils.WriteOption("BACKUP_IS_WORKING", "0")
+	source.SetEnum("BACKUP_STATUS", "")
+	source.SetBool("BACKUP_IS_WORKING", false)
 
 	return "{\"status\": \"ok\"}"
 	
 }
 
-func taskBackup() string {
+func taskBackup(source options.Source) string {
 	fmt.Println("Executing taskBackup")
 
 	// Load Backup Options
-	backup_service := utils.ReadOption("BACKUP_SERVICE")
-	backup_service_url := utils.ReadOption("BACKUP_SERVICE_URL")
-	backup_repository_name := utils.ReadOption("BACKUP_REPOSITORY_NAME")
-	// backup_repository_password := utils.ReadOption("BACKUP_REPOSITORY_PASSWORD")
-	backup_repository_access_key_id := utils.ReadOption("BACKUP_REPOSITORY_ACCESS_KEY_ID")
-	backup_repository_secret_access_key := utils.ReadOption("BACKUP_REPOSITORY_SECRET_ACCESS_KEY")
-	backup_repository_location := utils.ReadOption("BACKUP_REPOSITORY_LOCATION")
+	backup_service, _ := options.GetEnum("BACKUP_SERVICE")
+	backup_service_url, _ := options.GetString("BACKUP_SERVICE_URL")
+	backup_repository_name, _ := options.GetString("BACKUP_REPOSITORY_NAME")
+	// backup_repository_password, _ := options.GetString("BACKUP_REPOSITORY_PASSWORD")
+	backup_repository_access_key_id, _ := options.GetString("BACKUP_REPOSITORY_ACCESS_KEY_ID")
+	backup_repository_secret_access_key, _ := options.GetString("BACKUP_REPOSITORY_SECRET_ACCESS_KEY")
+	backup_repository_location, _ := options.GetString("BACKUP_REPOSITORY_LOCATION")
 
 	key_id_name := "AWS_ACCESS_KEY_ID"
 	key_secret_name := "AWS_SECRET_ACCESS_KEY"
@@ -757,41 +1147,45 @@ func taskBackup() string {
 	os.Setenv(key_secret_name, backup_repository_secret_access_key)
 
 
-	utils.WriteOption("BACKUP_IS_WORKING", "1")
+	source.SetBool("BACKUP_IS_WORKING", true)
+	events.Publish(events.BACKUP_STARTED, newBackupEventData("backup", ""))
 
 	// ...	This backs up the restic repository
 	cmdArgs := []string{"-r", backup_service + ":" + backup_service_url + backup_repository_name + ":" + backup_repository_location, "backup", backup_repository_location, "--password-file", utils.GetPath(utils.BackupPasswordFileLocation), "--verbose=3"}
-	result := utils.ExecAndStream(backup_repository_location, "restic", cmdArgs)
+	result := utils.ExecAndStreamLines(backup_repository_location, "restic", cmdArgs, newBackupProgressPublisher("backup"))
 
-	utils.WriteOption("BACKUP_IS_WORKING", "0")
+	source.SetBool("BACKUP_IS_WORKING", false)
 	// Write as Unix timestamp
-	utils.WriteOption("BACKUP_LAST_RUN", strconv.FormatInt(time.Now().Unix(), 10))
+	source.SetTime("BACKUP_LAST_RUN", time.Now())
 
 	// See if result contains the substring "Fatal:"
 	if strings.Contains(result, "Fatal:") {
 		fmt.Println("Error backing up")
-		utils.WriteOption("BACKUP_STATUS", "error")
-		utils.WriteOption("BACKUP_ERROR_MESSAGE", result)
+		source.SetEnum("BACKUP_STATUS", "error")
+		source.SetString("BACKUP_ERROR_MESSAGE", result)
+		events.Publish(events.BACKUP_FAILED, newBackupEventData("backup", result))
 		return "{\"status\": \"error\", \"message\": \"" + result + "\"}"
 	}
 
-	utils.WriteOption("BACKUP_STATUS", "working")
-	taskGetBackupStatus()
+	source.SetEnum("BACKUP_STATUS", "working")
+	source.SetTime("BACKUP_LAST_SUCCESS", time.Now())
+	events.Publish(events.BACKUP_FINISHED, newBackupEventData("backup", ""))
+	taskGetBackupStatus(source)
 	return "{\"status\": \"ok\"}"
 	
 }
 
-func taskRestoreBackup() string {
+func taskRestoreBackup(source options.Source) string {
 	fmt.Println("Executing taskRestoreBackup")
 
 	// Load Backup Options
-	backup_service := utils.ReadOption("BACKUP_SERVICE")
-	backup_service_url := utils.ReadOption("BACKUP_SERVICE_URL")
-	backup_repository_name := utils.ReadOption("BACKUP_REPOSITORY_NAME")
-	// backup_repository_password := utils.ReadOption("BACKUP_REPOSITORY_PASSWORD")
-	backup_repository_access_key_id := utils.ReadOption("BACKUP_REPOSITORY_ACCESS_KEY_ID")
-	backup_repository_secret_access_key := utils.ReadOption("BACKUP_REPOSITORY_SECRET_ACCESS_KEY")
-	backup_repository_location := utils.ReadOption("BACKUP_REPOSITORY_LOCATION")
+	backup_service, _ := options.GetEnum("BACKUP_SERVICE")
+	backup_service_url, _ := options.GetString("BACKUP_SERVICE_URL")
+	backup_repository_name, _ := options.GetString("BACKUP_REPOSITORY_NAME")
+	// backup_repository_password, _ := options.GetString("BACKUP_REPOSITORY_PASSWORD")
+	backup_repository_access_key_id, _ := options.GetString("BACKUP_REPOSITORY_ACCESS_KEY_ID")
+	backup_repository_secret_access_key, _ := options.GetString("BACKUP_REPOSITORY_SECRET_ACCESS_KEY")
+	backup_repository_location, _ := options.GetString("BACKUP_REPOSITORY_LOCATION")
 
 	key_id_name := "AWS_ACCESS_KEY_ID"
 	key_secret_name := "AWS_SECRET_ACCESS_KEY"
@@ -820,7 +1214,8 @@ func taskRestoreBackup() string {
 	os.Setenv(key_secret_name, backup_repository_secret_access_key)
 
 
-	utils.WriteOption("BACKUP_IS_WORKING", "1")
+	source.SetBool("BACKUP_IS_WORKING", true)
+	events.Publish(events.BACKUP_STARTED, newBackupEventData("restore", ""))
 
 	fmt.Println("Stopping All EdgeApps")
 	// Stop All EdgeApps
@@ -828,8 +1223,8 @@ func taskRestoreBackup() string {
 
 	// Copy all files in /home/system/components/apps/ to a backup folder
 	fmt.Println("Copying all files in /home/system/components/apps/ to a backup folder")
-	os.MkdirAll(utils.GetPath(utils.EdgeAppsBackupPath + "temp/"), 0777)
-	system.CopyDir(utils.GetPath(utils.EdgeAppsPath), utils.GetPath(utils.EdgeAppsBackupPath + "temp/"))
+	os.MkdirAll(utils.GetPath(utils.EdgeAppsBackupPath) + "temp/", 0777)
+	system.CopyDir(utils.GetPath(utils.EdgeAppsPath), utils.GetPath(utils.EdgeAppsBackupPath) + "temp/")
 
 	fmt.Println("Removing all files in /home/system/components/apps/")
 	os.RemoveAll(utils.GetPath(utils.EdgeAppsPath))
@@ -840,57 +1235,59 @@ func taskRestoreBackup() string {
 
 	// ...	This restores up the restic repository
 	cmdArgs := []string{"-r", backup_service + ":" + backup_service_url + backup_repository_name + ":" + backup_repository_location, "restore", "latest", "--target", "/", "--path", backup_repository_location, "--password-file", utils.GetPath(utils.BackupPasswordFileLocation), "--verbose=3"}
-	result := utils.ExecAndStream(backup_repository_location, "restic", cmdArgs)
+	result := utils.ExecAndStreamLines(backup_repository_location, "restic", cmdArgs, newBackupProgressPublisher("restore"))
 
-	taskGetBackupStatus()
+	taskGetBackupStatus(source)
 
 	edgeapps.RestartEdgeAppsService()
 
-	utils.WriteOption("BACKUP_IS_WORKING", "0")
+	source.SetBool("BACKUP_IS_WORKING", false)
 
 	// See if result contains the substring "Fatal:"
 	if strings.Contains(result, "Fatal:") {
 		// Copy all files from backup folder to /home/system/components/apps/
 		os.MkdirAll(utils.GetPath(utils.EdgeAppsPath), 0777)
-		system.CopyDir(utils.GetPath(utils.EdgeAppsBackupPath + "temp/"), utils.GetPath(utils.EdgeAppsPath))
+		system.CopyDir(utils.GetPath(utils.EdgeAppsBackupPath) + "temp/", utils.GetPath(utils.EdgeAppsPath))
 
 		fmt.Println("Error restoring backup: ")
-		utils.WriteOption("BACKUP_STATUS", "error")
-		utils.WriteOption("BACKUP_ERROR_MESSAGE", result)
+		source.SetEnum("BACKUP_STATUS", "error")
+		source.SetString("BACKUP_ERROR_MESSAGE", result)
+		events.Publish(events.BACKUP_FAILED, newBackupEventData("restore", result))
 		return "{\"status\": \"error\", \"message\": \"" + result + "\"}"
 	}
 
-	utils.WriteOption("BACKUP_STATUS", "working")
-	taskGetBackupStatus()
+	source.SetEnum("BACKUP_STATUS", "working")
+	events.Publish(events.BACKUP_FINISHED, newBackupEventData("restore", ""))
+	taskGetBackupStatus(source)
 	return "{\"status\": \"ok\"}"
 	
 }
 
-func taskAutoBackup() string {
+func taskAutoBackup(source options.Source) string {
 	fmt.Println("Executing taskAutoBackup")
 
 	// Get Backup Status
-	backup_status := utils.ReadOption("BACKUP_STATUS")
+	backup_status, _ := options.GetEnum("BACKUP_STATUS")
 	// We only backup is the status is "working"
 	if backup_status == "working" {
-		return taskBackup()
+		return taskBackup(source)
 	} else {
 		fmt.Println("Backup status is not working... skipping")
 		return "{\"status\": \"skipped\"}"		
 	}
 }
 
-func taskGetBackupStatus() string {
+func taskGetBackupStatus(source options.Source) string {
 	fmt.Println("Executing taskGetBackupStatus")
 
 	// Load Backup Options
-	backup_service := utils.ReadOption("BACKUP_SERVICE")
-	backup_service_url := utils.ReadOption("BACKUP_SERVICE_URL")
-	backup_repository_name := utils.ReadOption("BACKUP_REPOSITORY_NAME")
-	// backup_repository_password := utils.ReadOption("BACKUP_REPOSITORY_PASSWORD")
-	backup_repository_access_key_id := utils.ReadOption("BACKUP_REPOSITORY_ACCESS_KEY_ID")
-	backup_repository_secret_access_key := utils.ReadOption("BACKUP_REPOSITORY_SECRET_ACCESS_KEY")
-	backup_repository_location := utils.ReadOption("BACKUP_REPOSITORY_LOCATION")
+	backup_service, _ := options.GetEnum("BACKUP_SERVICE")
+	backup_service_url, _ := options.GetString("BACKUP_SERVICE_URL")
+	backup_repository_name, _ := options.GetString("BACKUP_REPOSITORY_NAME")
+	// backup_repository_password, _ := options.GetString("BACKUP_REPOSITORY_PASSWORD")
+	backup_repository_access_key_id, _ := options.GetString("BACKUP_REPOSITORY_ACCESS_KEY_ID")
+	backup_repository_secret_access_key, _ := options.GetString("BACKUP_REPOSITORY_SECRET_ACCESS_KEY")
+	backup_repository_location, _ := options.GetString("BACKUP_REPOSITORY_LOCATION")
 
 	key_id_name := "AWS_ACCESS_KEY_ID"
 	key_secret_name := "AWS_SECRET_ACCESS_KEY"
@@ -918,27 +1315,27 @@ func taskGetBackupStatus() string {
 
 	// ...	This gets the restic repository status
 	cmdArgs := []string{"-r", backup_service + ":" + backup_service_url + backup_repository_name + ":" + backup_repository_location, "stats", "--password-file", utils.GetPath(utils.BackupPasswordFileLocation), "--verbose=3"}
-	utils.WriteOption("BACKUP_STATS", utils.ExecAndStream(backup_repository_location, "restic", cmdArgs))
+	source.SetString("BACKUP_STATS", utils.ExecAndStream(backup_repository_location, "restic", cmdArgs))
 
 	return "{\"status\": \"ok\"}"
 	
 }
 
-func taskSetupTunnel(args taskSetupTunnelArgs) string {
+func taskSetupTunnel(source options.Source, args taskSetupTunnelArgs) string {
 	fmt.Println("Executing taskSetupTunnel")
 	wsPath := utils.GetPath(utils.WsPath)	
 
 	// Stop a the service if it is running
-	system.StopService("cloudflared")
+	system.StopService(system.GetTunnelService())
 
 	// Uninstall the service if it is installed
 	system.RemoveTunnelService()
 
 	fmt.Println("Creating cloudflared folder")
-	cmdargs := []string{"/home/system/.cloudflared"}
+	cmdargs := []string{utils.GetPath(utils.CloudflaredPath)}
 	utils.Exec(wsPath, "mkdir", cmdargs)
 
-	cmd := exec.Command("sh", "/home/system/components/edgeboxctl/scripts/cloudflared_login.sh")
+	cmd := system.TunnelLoginCommand()
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		panic(err)
@@ -955,8 +1352,7 @@ func taskSetupTunnel(args taskSetupTunnelArgs) string {
 		if strings.Contains(text, "https://") {
 			url = text
 			fmt.Println("Tunnel setup is requesting auth with URL: " + url)
-			status := "{\"status\": \"waiting\", \"login_link\": \"" + url + "\"}"
-			utils.WriteOption("TUNNEL_STATUS", status)
+			setTunnelStatus(source, tunnelStatus{Status: "waiting", LoginLink: url})
 			break
 		}
 	}
@@ -974,8 +1370,8 @@ func taskSetupTunnel(args taskSetupTunnelArgs) string {
 		// When running as a service, the cert is saved to a different folder,
 		// so we check both :)
 		for {
-			_, err := os.Stat("/home/system/.cloudflared/cert.pem")
-			_, err2 := os.Stat("/root/.cloudflared/cert.pem")
+			_, err := os.Stat(utils.GetPath(utils.CloudflaredPath) + "cert.pem")
+			_, err2 := os.Stat(utils.GetPath(utils.CloudflaredRootPath) + "cert.pem")
 			if err == nil || err2 == nil {
 				fmt.Println("cert.pem file detected")
 				break
@@ -985,24 +1381,23 @@ func taskSetupTunnel(args taskSetupTunnelArgs) string {
 		}
 
 		fmt.Println("Tunnel auth setup finished without errors.")
-		status := "{\"status\": \"starting\", \"login_link\": \"" + url + "\"}"
-		utils.WriteOption("TUNNEL_STATUS", status)
+		setTunnelStatus(source, tunnelStatus{Status: "starting", LoginLink: url})
 
 		// Remove old tunnel if it exists, and create from scratch
 		system.DeleteTunnel()
 
 		// Create new tunnel (destination config file is param)
-		system.CreateTunnel("/home/system/.cloudflared/config.yml")
+		system.CreateTunnel(utils.GetPath(utils.CloudflaredPath) + "config.yml")
 
 		fmt.Println("Creating DNS Routes for @ and *.")
-		cmd = exec.Command("cloudflared", "tunnel", "route", "dns", "-f" ,"edgebox", "*." + args.DomainName)
+		cmd = exec.Command("cloudflared", "tunnel", "route", "dns", "-f", system.GetTunnelName(), "*." + args.DomainName)
 		cmd.Start()
 		err = cmd.Wait()
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		cmd = exec.Command("cloudflared", "tunnel", "route", "dns", "-f" ,"edgebox", args.DomainName)
+		cmd = exec.Command("cloudflared", "tunnel", "route", "dns", "-f", system.GetTunnelName(), args.DomainName)
 		cmd.Start()
 		err = cmd.Wait()
 		if err != nil {
@@ -1010,23 +1405,21 @@ func taskSetupTunnel(args taskSetupTunnelArgs) string {
 		}
 
 		domainNameInfo := args.DomainName
-		utils.WriteOption("DOMAIN_NAME", domainNameInfo)
+		source.SetString("DOMAIN_NAME", domainNameInfo)
 
 		// Install service with given config file
-		system.InstallTunnelService("/home/system/.cloudflared/config.yml")
+		system.InstallTunnelService(utils.GetPath(utils.CloudflaredPath) + "config.yml")
 
 		// Start the service
-		system.StartService("cloudflared")
+		system.StartService(system.GetTunnelService())
 
 		if err != nil {
 			fmt.Println("Tunnel auth setup finished with errors.")
-			status := "{\"status\": \"error\", \"login_link\": \"" + url + "\"}"
-			utils.WriteOption("TUNNEL_STATUS", status)
+			setTunnelStatus(source, tunnelStatus{Status: "error", LoginLink: url})
 			log.Fatal(err)
 		} else {
 			fmt.Println("Tunnel auth setup finished without errors.")
-			status := "{\"status\": \"connected\", \"login_link\": \"" + url + "\", \"domain\": \"" + args.DomainName + "\"}"
-			utils.WriteOption("TUNNEL_STATUS", status)
+			setTunnelStatus(source, tunnelStatus{Status: "connected", LoginLink: url, Domain: args.DomainName})
 		}
 
 		fmt.Println("Finished running async")
@@ -1035,42 +1428,42 @@ func taskSetupTunnel(args taskSetupTunnelArgs) string {
     return "{\"url\": \"" + url + "\"}"
 }
 
-func taskStartTunnel() string {
+func taskStartTunnel(source options.Source) string {
     fmt.Println("Executing taskStartTunnel")
     
     // Read tunnel status to check if cloudflare is configured
-    tunnelStatus := utils.ReadOption("TUNNEL_STATUS")
-	if tunnelStatus != "" {
+    var currentTunnelStatus tunnelStatus
+    options.GetJSON("TUNNEL_STATUS", &currentTunnelStatus)
+	if currentTunnelStatus.Status != "" {
 		// Only start cloudflared if we have a tunnel configured
-        system.StartService("cloudflared")
-        domainName := utils.ReadOption("DOMAIN_NAME")
-        status := "{\"status\": \"connected\", \"domain\": \"" + domainName + "\"}"
-        utils.WriteOption("TUNNEL_STATUS", status)
+        system.StartService(system.GetTunnelService())
+        domainName, _ := options.GetString("DOMAIN_NAME")
+        setTunnelStatus(source, tunnelStatus{Status: "connected", Domain: domainName})
 	}
     
     return "{\"status\": \"ok\"}"
 }
 
-func taskStopTunnel() string {
+func taskStopTunnel(source options.Source) string {
 	fmt.Println("Executing taskStopTunnel")
-	system.StopService("cloudflared")
-	domainName := utils.ReadOption("DOMAIN_NAME")
-	status := "{\"status\": \"stopped\", \"domain\": \"" + domainName + "\"}"
-	utils.WriteOption("TUNNEL_STATUS", status)
+	system.StopService(system.GetTunnelService())
+	domainName, _ := options.GetString("DOMAIN_NAME")
+	setTunnelStatus(source, tunnelStatus{Status: "stopped", Domain: domainName})
 	return "{\"status\": \"ok\"}"
 }
 
-func taskDisableTunnel() string {
+func taskDisableTunnel(source options.Source) string {
 	fmt.Println("Executing taskDisableTunnel")
-	system.StopService("cloudflared")
+	system.StopService(system.GetTunnelService())
 	system.DeleteTunnel()
 	system.RemoveTunnelService()
-	utils.DeleteOption("DOMAIN_NAME")
-	utils.DeleteOption("TUNNEL_STATUS")
+	source.Delete("DOMAIN_NAME")
+	source.Delete("TUNNEL_STATUS")
+	events.Publish(events.TUNNEL_STATUS_CHANGED, tunnelStatus{Status: "disabled"})
 	return "{\"status\": \"ok\"}"
 }
 
-func taskStartShell(args taskStartShellArgs) string {
+func taskStartShell(source options.Source, args taskStartShellArgs) string {
 	fmt.Println("Executing taskStartShell")
 	wsPath := utils.GetPath(utils.WsPath)
 
@@ -1097,8 +1490,8 @@ func taskStartShell(args taskStartShellArgs) string {
 		if strings.Contains(text, "https://") {
 			url = text
 			fmt.Println("Shell start is responding with URL: " + url)
-			utils.WriteOption("SHELL_URL", url)
-			utils.WriteOption("SHELL_STATUS", "running")
+			source.SetString("SHELL_URL", url)
+			source.SetEnum("SHELL_STATUS", "running")
 			break
 		}
 	}
@@ -1119,7 +1512,7 @@ func taskStartShell(args taskStartShellArgs) string {
 			if timeout <= 0 {
 				fmt.Println("Timeout reached, killing process...")
 				utils.Exec(wsPath, "killall sshx", []string{})
-				utils.WriteOption("SHELL_STATUS", "not_running")
+				source.SetEnum("SHELL_STATUS", "not_running")
 				break
 			}
 			if timeout%10 == 0 {
@@ -1132,42 +1525,42 @@ func taskStartShell(args taskStartShellArgs) string {
 	return "{\"status\": \"ok\"}"
 }
 
-func taskStopShell() string {
+func taskStopShell(source options.Source) string {
 	fmt.Println("Executing taskStopShell")
 	wsPath := utils.GetPath(utils.WsPath)
 
 	// kill the process if its running
 	utils.Exec(wsPath, "killall", []string{"sshx"})
-	utils.WriteOption("SHELL_STATUS", "not_running")
+	source.SetEnum("SHELL_STATUS", "not_running")
 
 	return "{\"status\": \"ok\"}"
 
 }
 
-func taskGetBrowserDevStatus() string {
+func taskGetBrowserDevStatus(source options.Source) string {
 	fmt.Println("Executing taskGetBrowserDevStatus")
 
-	// Read status from systemctl status code-server@root
+	// Read status from systemctl status of the browser dev service (code-server@root by default)
 	browserDevStatus := utils.Exec(
 		utils.GetPath(utils.WsPath),
 		"sh",
-		[]string{"-c", "systemctl --quiet is-active code-server@root && echo 'active' || echo 'inactive'"},
+		[]string{"-c", "systemctl --quiet is-active " + system.GetBrowserDevService() + " && echo 'active' || echo 'inactive'"},
 	)	
 	if browserDevStatus == "active" {
 		fmt.Println("Browser Dev Environment is running")
-		utils.WriteOption("BROWSERDEV_STATUS", "running")
-		taskGetBrowserDevUrl()
+		source.SetEnum("BROWSERDEV_STATUS", "running")
+		taskGetBrowserDevUrl(source)
 
 		return "{\"status\": \"running\"}"
 
 	} else {
 		fmt.Println("Browser Dev Environment is not running")
-		utils.WriteOption("BROWSERDEV_STATUS", "not_running")
+		source.SetEnum("BROWSERDEV_STATUS", "not_running")
 		return "{\"status\": \"not_running\"}"
 	}
 }
 
-func taskGetBrowserDevUrl() string {
+func taskGetBrowserDevUrl(source options.Source) string {
 	url := ""
 	myEdgeAppServiceEnv, err := godotenv.Read(utils.GetPath(utils.BrowserDevPath) + "myedgeapp.env")
 	if err != nil {
@@ -1181,30 +1574,30 @@ func taskGetBrowserDevUrl() string {
 
 	fmt.Println("Browser Dev Url: " + url)
 
-	utils.WriteOption("BROWSERDEV_URL", url)
+	source.SetString("BROWSERDEV_URL", url)
 	return url
 }
 
-func taskActivateBrowserDev() string {
+func taskActivateBrowserDev(source options.Source) string {
 	fmt.Println("Executing taskActivateBrowserDev")
 	wsPath := utils.GetPath(utils.WsPath)
 
 	// Start the service
-	utils.Exec(wsPath, "systemctl", []string{"start", "code-server@root"})
-	// Write run file to /home/system/components/dev/.run
+	utils.Exec(wsPath, "systemctl", []string{"start", system.GetBrowserDevService()})
+	// Write run file to <browserdev proxy path>/.run
 	utils.Exec(wsPath, "touch", []string{utils.GetPath(utils.BrowserDevProxyPath) + ".run"})
 	// Rebuild WS (necessary to start the proxy)
 	system.StartWs()
 	// Write control option for API
-	utils.WriteOption("BROWSERDEV_STATUS", "running")
+	source.SetEnum("BROWSERDEV_STATUS", "running")
 
 	// Write and refresh the dev environment password option
-	taskGetBrowserDevPassword()
+	taskGetBrowserDevPassword(source)
 
 	return "{\"status\": \"ok\"}"
 }
 
-func taskDeactivateBrowserDev() string {
+func taskDeactivateBrowserDev(source options.Source) string {
 	fmt.Println("Executing taskDeactivateBrowserDev")
 	wsPath := utils.GetPath(utils.WsPath)
 
@@ -1212,18 +1605,54 @@ func taskDeactivateBrowserDev() string {
 	os.Remove(utils.GetPath(utils.BrowserDevProxyPath) + ".run")
 	system.StartWs()
 	
-	utils.Exec(wsPath, "systemctl", []string{"stop", "code-server@root"})
-	utils.WriteOption("BROWSERDEV_STATUS", "not_running")
+	utils.Exec(wsPath, "systemctl", []string{"stop", system.GetBrowserDevService()})
+	source.SetEnum("BROWSERDEV_STATUS", "not_running")
 
 	return "{\"status\": \"ok\"}"
 }
 
-func taskGetBrowserDevPassword() string {
+func taskAddWebhook(source options.Source, args taskAddWebhookArgs) string {
+	fmt.Println("Executing taskAddWebhook for " + args.URL)
+
+	webhook, err := webhooks.AddWebhook(source, args.URL, args.Events, args.Secret)
+	if err != nil {
+		log.Println("Error adding webhook: " + err.Error())
+		message, _ := json.Marshal(err.Error())
+		return "{\"status\": \"error\", \"message\": " + string(message) + "}"
+	}
+
+	return "{\"status\": \"ok\", \"id\": \"" + webhook.ID + "\"}"
+}
+
+func taskRemoveWebhook(source options.Source, args taskRemoveWebhookArgs) string {
+	fmt.Println("Executing taskRemoveWebhook for " + args.ID)
+
+	err := webhooks.RemoveWebhook(source, args.ID)
+	if err != nil {
+		log.Println("Error removing webhook: " + err.Error())
+		message, _ := json.Marshal(err.Error())
+		return "{\"status\": \"error\", \"message\": " + string(message) + "}"
+	}
+
+	return "{\"status\": \"ok\"}"
+}
+
+// taskEncryptSecrets : Encrypts secret options still stored in plaintext, generating the device key on first run
+func taskEncryptSecrets() {
+	count, err := options.EncryptSecrets()
+	if err != nil {
+		log.Println("Error migrating secret options: " + err.Error())
+	} else if count > 0 {
+		log.Printf("Migrated %d options to their stored format (encrypted secrets, plaintext otherwise)", count)
+	}
+}
+
+func taskGetBrowserDevPassword(source options.Source) string {
 	fmt.Println("Executing taskGetBrowserDevPassword")
 
 	password, err := system.FetchBrowserDevPasswordFromFile()
 	if err == nil {
-		utils.WriteOption("BROWSERDEV_PASSWORD", password)
+		source.SetString("BROWSERDEV_PASSWORD", password)
 	} else {
 		fmt.Println("Error fetching browser dev password from file: " + err.Error())
 	}
@@ -1231,173 +1660,279 @@ func taskGetBrowserDevPassword() string {
 	return password
 }
 
-func taskSetBrowserDevPassword(args taskSetBrowserDevPasswordArgs) string {
+func taskSetBrowserDevPassword(source options.Source, args taskSetBrowserDevPasswordArgs) string {
 	fmt.Println("Executing taskSetBrowserDevPassword")
 	wsPath := utils.GetPath(utils.WsPath)
 
 	system.SetBrowserDevPasswordFile(args.Password)
-	utils.WriteOption("BROWSERDEV_PASSWORD", args.Password)
+	source.SetString("BROWSERDEV_PASSWORD", args.Password)
 
 	// Check if BROWSERDEV_STATUS is "running", if so, restart the service
-	if utils.ReadOption("BROWSERDEV_STATUS") == "running" {
-		utils.Exec(wsPath, "systemctl", []string{"restart", "code-server@root"})
+	if browserDevStatus, _ := options.GetEnum("BROWSERDEV_STATUS"); browserDevStatus == "running" {
+		utils.Exec(wsPath, "systemctl", []string{"restart", system.GetBrowserDevService()})
 	}
 
 	return "{\"status\": \"ok\"}"
 }
 
-func taskInstallEdgeApp(args taskInstallEdgeAppArgs) string {
+func taskInstallEdgeApp(source options.Source, args taskInstallEdgeAppArgs) string {
 	fmt.Println("Executing taskInstallEdgeApp for " + args.ID)
 
-	result := edgeapps.SetEdgeAppInstalled(args.ID)
+	IDs, err := edgeapps.ResolveInstall([]string{args.ID})
+	if err != nil {
+		message, _ := json.Marshal(err.Error())
+		return "{\"status\": \"error\", \"message\": " + string(message) + "}"
+	}
+
+	result := true
+	if len(IDs) > 1 {
+		fmt.Println("Installing required EdgeApps first: " + strings.Join(IDs[:len(IDs)-1], ", "))
+		result = edgeapps.SetEdgeAppBulkInstalled(IDs)
+	} else {
+		result = edgeapps.SetEdgeAppInstalled(args.ID)
+	}
 	resultJSON, _ := json.Marshal(result)
 
-	taskGetEdgeApps()
+	taskGetEdgeApps(source)
+	return string(resultJSON)
+}
+
+func taskInstallEdgeAppFromCatalog(source options.Source, args taskInstallEdgeAppFromCatalogArgs) string {
+	fmt.Println("Executing taskInstallEdgeAppFromCatalog for " + args.ID)
+
+	placed, err := catalog.Install(args.ID, args.Version)
+	if err != nil {
+		if len(placed) > 0 {
+			taskGetEdgeApps(source)
+		}
+		message, _ := json.Marshal(err.Error())
+		return "{\"status\": \"error\", \"message\": " + string(message) + "}"
+	}
+
+	return taskInstallEdgeApp(source, taskInstallEdgeAppArgs{ID: args.ID})
+}
+
+func taskUpgradeEdgeApp(source options.Source, args taskUpgradeEdgeAppArgs) string {
+	fmt.Println("Executing taskUpgradeEdgeApp for " + args.ID)
+
+	staged, cleanup, err := catalog.Stage(args.ID, args.Version)
+	defer cleanup()
+
+	version := ""
+	if err == nil {
+		version, err = edgeapps.UpgradeEdgeApp(args.ID, staged)
+	}
+
+	taskGetEdgeApps(source)
+
+	if err != nil {
+		message, _ := json.Marshal(err.Error())
+		return "{\"status\": \"error\", \"message\": " + string(message) + "}"
+	}
+
+	versionJSON, _ := json.Marshal(version)
+	return "{\"status\": \"ok\", \"version\": " + string(versionJSON) + "}"
+}
+
+func taskExportEdgeApp(source options.Source, args taskExportEdgeAppArgs) string {
+	fmt.Println("Executing taskExportEdgeApp for " + args.ID)
+
+	path, err := edgeapps.ExportEdgeApp(args.ID, args.Path)
+	taskGetEdgeApps(source)
+
+	if err != nil {
+		message, _ := json.Marshal(err.Error())
+		return "{\"status\": \"error\", \"message\": " + string(message) + "}"
+	}
+
+	pathJSON, _ := json.Marshal(path)
+	return "{\"status\": \"ok\", \"path\": " + string(pathJSON) + "}"
+}
+
+func taskGetEdgeAppLogs(args taskGetEdgeAppLogsArgs) string {
+	fmt.Println("Executing taskGetEdgeAppLogs for " + args.ID)
+
+	logs, err := edgeapps.GetEdgeAppLogs(args.ID, edgeapps.LogsRequest{
+		Services: args.Services,
+		Tail:     args.Tail,
+		Since:    args.Since,
+	}, args.File)
+	if err != nil {
+		message, _ := json.Marshal(err.Error())
+		return "{\"status\": \"error\", \"message\": " + string(message) + "}"
+	}
+
+	result := struct {
+		Status string `json:"status"`
+		edgeapps.Logs
+	}{"ok", logs}
+	resultJSON, _ := json.Marshal(result)
 	return string(resultJSON)
 }
 
-func taskInstallBulkEdgeApps(args taskInstallBulkEdgeAppsArgs) string {
+func taskImportEdgeApp(source options.Source, args taskImportEdgeAppArgs) string {
+	fmt.Println("Executing taskImportEdgeApp for " + args.Path)
+
+	ID, err := edgeapps.ImportEdgeApp(args.Path, args.Replace)
+	taskGetEdgeApps(source)
+
+	if err != nil {
+		message, _ := json.Marshal(err.Error())
+		return "{\"status\": \"error\", \"message\": " + string(message) + "}"
+	}
+
+	IDJSON, _ := json.Marshal(ID)
+	return "{\"status\": \"ok\", \"id\": " + string(IDJSON) + "}"
+}
+
+func taskInstallBulkEdgeApps(source options.Source, args taskInstallBulkEdgeAppsArgs) string {
 	fmt.Println("Executing taskInstallBulkEdgeApps for " + strings.Join(args.IDS, ", "))
 
-	// args.Apps is a list of edgeapp ids
-	edgeapps.SetEdgeAppBulkInstalled(args.IDS)
+	// args.Apps is a list of edgeapp ids, installed with their required dependencies
+	IDs, err := edgeapps.ResolveInstall(args.IDS)
+	if err != nil {
+		message, _ := json.Marshal(err.Error())
+		return "{\"status\": \"error\", \"message\": " + string(message) + "}"
+	}
+	edgeapps.SetEdgeAppBulkInstalled(IDs)
 
-	taskGetEdgeApps()
+	taskGetEdgeApps(source)
 	return "{\"status\": \"ok\"}"
 }
 
-func taskRemoveEdgeApp(args taskRemoveEdgeAppArgs) string {
+func taskRemoveEdgeApp(source options.Source, args taskRemoveEdgeAppArgs) string {
 	fmt.Println("Executing taskRemoveEdgeApp for " + args.ID)
 
+	dependents := edgeapps.GetDependents(args.ID)
+	if len(dependents) > 0 && !args.Cascade {
+		dependentsJSON, _ := json.Marshal(dependents)
+		return "{\"status\": \"error\", \"message\": \"EdgeApp is required by other EdgeApps, remove them first or confirm with cascade\", \"dependents\": " + string(dependentsJSON) + "}"
+	}
+
+	// Dependents come first, so nothing is left running without the EdgeApps it requires
+	for _, dependent := range dependents {
+		fmt.Println("Removing " + dependent + ", which requires " + args.ID)
+		edgeapps.StopEdgeApp(dependent)
+		edgeapps.SetEdgeAppNotInstalled(dependent)
+	}
+
 	// Making sure the application is stopped before setting it as removed.
 	edgeapps.StopEdgeApp(args.ID)
 	result := edgeapps.SetEdgeAppNotInstalled(args.ID)
 	resultJSON, _ := json.Marshal(result)
 
-	taskGetEdgeApps()
+	taskGetEdgeApps(source)
 	return string(resultJSON)
 }
 
-func taskStartEdgeApp(args taskStartEdgeAppArgs) string {
+func taskStartEdgeApp(source options.Source, args taskStartEdgeAppArgs) string {
 	fmt.Println("Executing taskStartEdgeApp for " + args.ID)
 
-	result := edgeapps.RunEdgeApp(args.ID)
+	result := edgeapps.RunEdgeAppAndDependencies(args.ID)
 	resultJSON, _ := json.Marshal(result)
 
-	taskGetEdgeApps() // This task will imediatelly update the entry in the api database.
+	taskGetEdgeApps(source) // This task will imediatelly update the entry in the api database.
 	return string(resultJSON)
 }
 
-func taskStopEdgeApp(args taskStopEdgeAppArgs) string {
+func taskStopEdgeApp(source options.Source, args taskStopEdgeAppArgs) string {
 	fmt.Println("Executing taskStopEdgeApp for " + args.ID)
 
-	result := edgeapps.StopEdgeApp(args.ID)
+	result := edgeapps.StopEdgeAppAndDependents(args.ID)
 	resultJSON, _ := json.Marshal(result)
 
-	taskGetEdgeApps() // This task will imediatelly update the entry in the api database.
+	taskGetEdgeApps(source) // This task will imediatelly update the entry in the api database.
 	return string(resultJSON)
 }
 
-func taskSetEdgeAppOptions(args taskSetEdgeAppOptionsArgs) string {
+func taskSetEdgeAppOptions(source options.Source, args taskSetEdgeAppOptionsArgs) string {
 	// Id is the edgeapp id
 	appID := args.ID
 
-
-	// Open the file to write the options,
-	// it is an env file in /home/system/components/apps/<app_id>/edgeapp.env
-
-	// Get the path to the edgeapp.env file
-	edgeappEnvPath := "/home/system/components/apps/" + appID + "/edgeapp.env"
-
-	// If the file does not exist, create it
-	if _, err := os.Stat(edgeappEnvPath); os.IsNotExist(err) {
-		// Create the file
-		_, err := os.Create(edgeappEnvPath)
-		if err != nil {
-			log.Printf("Error creating edgeapp.env file: %s", err)
-		}
+	if !edgeapps.Exists(appID) {
+		return "{\"status\": \"error\", \"message\": \"EdgeApp not found\"}"
 	}
 
-	// It is an env file, so we can use go-dotenv to write the options
-	// Open the file
-	edgeappEnvFile, err := os.OpenFile(edgeappEnvPath, os.O_WRONLY, 0600)
-	if err != nil {
-		log.Printf("Error opening edgeapp.env file: %s", err)
+	values := map[string]string{}
+	for _, option := range args.Options {
+		values[option.Key] = option.Value
 	}
 
-	// Write the options to the file
-	for _, value := range args.Options {
-		// Write the option to the file
-		_, err := edgeappEnvFile.WriteString(value.Key + "=" + value.Value + "\n")
-		if err != nil {
-			log.Printf("Error writing option to edgeapp.env file: %s", err)
-		}
+	// Nothing is written unless every option is valid
+	validationErrors := edgeapps.ValidateOptionValues(appID, values)
+	if len(validationErrors) > 0 {
+		errorsJSON, _ := json.Marshal(validationErrors)
+		return "{\"status\": \"error\", \"message\": \"Invalid options\", \"errors\": " + string(errorsJSON) + "}"
 	}
-	
-	// Close the file
-	err = edgeappEnvFile.Close()
+
+	// Options not sent keep their value, and the file is replaced atomically
+	edgeappEnvPath := utils.GetPath(utils.EdgeAppsPath) + appID + "/edgeapp.env"
+	err := utils.MergeEnvFile(edgeappEnvPath, values, 0600)
 	if err != nil {
-		log.Printf("Error closing edgeapp.env file: %s", err)
+		log.Printf("Error writing edgeapp.env file: %s", err)
+		message, _ := json.Marshal("Error writing options: " + err.Error())
+		return "{\"status\": \"error\", \"message\": " + string(message) + "}"
 	}
 
 	result := edgeapps.GetEdgeAppStatus(appID)
 	resultJSON, _ := json.Marshal(result)
 
 	system.StartWs()
-	taskGetEdgeApps() // This task will imediatelly update the entry in the api database.
+	taskGetEdgeApps(source) // This task will imediatelly update the entry in the api database.
 
 	return string(resultJSON)
 }
 
-func taskSetEdgeAppBasicAuth(args taskSetEdgeAppBasicAuthArgs) string {
+func taskSetEdgeAppBasicAuth(source options.Source, args taskSetEdgeAppBasicAuthArgs) string {
 	// Id is the edgeapp id
 	appID := args.ID
 
 
-	// Open the file to write the options,
-	// it is an env file in /home/system/components/apps/<app_id>/auth.env
+	edgeappAuthEnvPath := utils.GetPath(utils.EdgeAppsPath) + appID + "/auth.env"
+	err := utils.MergeEnvFile(edgeappAuthEnvPath, map[string]string{
+		"USERNAME": args.Login.Username,
+		"PASSWORD": args.Login.Password,
+	}, 0600)
+	if err != nil {
+		log.Printf("Error writing credentials to auth.env file: %s", err)
+		message, _ := json.Marshal("Error writing credentials: " + err.Error())
+		return "{\"status\": \"error\", \"message\": " + string(message) + "}"
+	}
 
-	// Get the path to the auth.env file
-	edgeappAuthEnvPath := "/home/system/components/apps/" + appID + "/auth.env"
+	result := edgeapps.GetEdgeAppStatus(appID)
+	resultJSON, _ := json.Marshal(result)
 
-	// If the file does not exist, create it
-	if _, err := os.Stat(edgeappAuthEnvPath); os.IsNotExist(err) {
-		// Create the file
-		_, err := os.Create(edgeappAuthEnvPath)
-		if err != nil {
-			log.Printf("Error creating auth.env file: %s", err)
-		}
-	}
+	system.StartWs()
+	taskGetEdgeApps(source) // This task will imediatelly update the entry in the api database.
 
-	// It is an env file, so we can use go-dotenv to write the options
-	// Open the file
-	edgeappAuthEnvFile, err := os.OpenFile(edgeappAuthEnvPath, os.O_WRONLY, 0600)
-	if err != nil {
-		log.Printf("Error opening auth.env file: %s", err)
-	}
+	return string(resultJSON)
+}
 
-	// Write the login values to the file
-	_, err = edgeappAuthEnvFile.WriteString("USERNAME=" + args.Login.Username + "\n" + "PASSWORD=" + args.Login.Password + "\n")
-	if err != nil {
-		log.Printf("Error writing credentials to auth.env file: %s", err)
+func taskSetEdgeAppLimits(source options.Source, args taskSetEdgeAppLimitsArgs) string {
+	fmt.Println("Executing taskSetEdgeAppLimits for " + args.ID)
+
+	if !edgeapps.Exists(args.ID) {
+		return "{\"status\": \"error\", \"message\": \"EdgeApp not found\"}"
 	}
-	
-	// Close the file
-	err = edgeappAuthEnvFile.Close()
+
+	err := edgeapps.SetEdgeAppLimits(args.ID, args.Limits)
 	if err != nil {
-		log.Printf("Error closing auth.env file: %s", err)
+		log.Printf("Error setting limits of %s: %s", args.ID, err)
+		message, _ := json.Marshal(err.Error())
+		return "{\"status\": \"error\", \"message\": " + string(message) + "}"
 	}
 
-	result := edgeapps.GetEdgeAppStatus(appID)
-	resultJSON, _ := json.Marshal(result)
-
+	// Containers are recreated with the new limits
 	system.StartWs()
-	taskGetEdgeApps() // This task will imediatelly update the entry in the api database.
+	taskGetEdgeApps(source) // This task will imediatelly update the entry in the api database.
+
+	result := edgeapps.GetEdgeAppLimits(args.ID)
+	resultJSON, _ := json.Marshal(result)
 
 	return string(resultJSON)
 }
 
-func taskRemoveEdgeAppBasicAuth(args taskRemoveEdgeAppBasicAuthArgs) string {
+func taskRemoveEdgeAppBasicAuth(source options.Source, args taskRemoveEdgeAppBasicAuthArgs) string {
 	// Id is the edgeapp id
 	appID := args.ID
 
@@ -1415,37 +1950,37 @@ func taskRemoveEdgeAppBasicAuth(args taskRemoveEdgeAppBasicAuthArgs) string {
 	resultJSON, _ := json.Marshal(result)
 
 	system.StartWs()
-	taskGetEdgeApps() // This task will imediatelly update the entry in the api database.
+	taskGetEdgeApps(source) // This task will imediatelly update the entry in the api database.
 
 	return string(resultJSON)
 }
 
-func taskEnableOnline(args taskEnableOnlineArgs) string {
+func taskEnableOnline(source options.Source, args taskEnableOnlineArgs) string {
 	fmt.Println("Executing taskEnableOnline for " + args.ID)
 
 	result := edgeapps.EnableOnline(args.ID, args.InternetURL)
 	resultJSON, _ := json.Marshal(result)
 
-	taskGetEdgeApps()
+	taskGetEdgeApps(source)
 	return string(resultJSON)
 }
 
-func taskDisableOnline(args taskDisableOnlineArgs) string {
+func taskDisableOnline(source options.Source, args taskDisableOnlineArgs) string {
 	fmt.Println("Executing taskDisableOnline for " + args.ID)
 
 	result := edgeapps.DisableOnline(args.ID)
 	resultJSON, _ := json.Marshal(result)
 
-	taskGetEdgeApps()
+	taskGetEdgeApps(source)
 	return string(resultJSON)
 }
 
-func taskEnablePublicDashboard(args taskEnablePublicDashboardArgs) string {
+func taskEnablePublicDashboard(source options.Source, args taskEnablePublicDashboardArgs) string {
 	fmt.Println("Enabling taskEnablePublicDashboard")
 	result := edgeapps.EnablePublicDashboard(args.InternetURL)
 	if result {
 
-		utils.WriteOption("PUBLIC_DASHBOARD", args.InternetURL)
+		source.SetString("PUBLIC_DASHBOARD", args.InternetURL)
 		return "{result: true}"
 
 	}
@@ -1453,26 +1988,26 @@ func taskEnablePublicDashboard(args taskEnablePublicDashboardArgs) string {
 	return "{result: false}"
 }
 
-func taskDisablePublicDashboard() string {
+func taskDisablePublicDashboard(source options.Source) string {
 	fmt.Println("Executing taskDisablePublicDashboard")
 	result := edgeapps.DisablePublicDashboard()
-	utils.WriteOption("PUBLIC_DASHBOARD", "")
+	source.SetString("PUBLIC_DASHBOARD", "")
 	if result {
 		return "{result: true}"
 	}
 	return "{result: false}"
 }
 
-func taskCheckSystemUpdates() string {
+func taskCheckSystemUpdates(source options.Source) string {
 	fmt.Println("Executing taskCheckSystemUpdates")
-	system.CheckUpdates()
+	system.CheckUpdates(source)
 	return "{result: true}"
 }
 
-func taskUpdateSystem() string {
+func taskUpdateSystem(source options.Source) string {
 	fmt.Println("Executing taskUpdateSystem")
-	system.ApplyUpdates()
-	utils.WriteOption("LAST_UPDATE", strconv.FormatInt(time.Now().Unix(), 10))
+	system.ApplyUpdates(source)
+	source.SetTime("LAST_UPDATE", time.Now())
 	return "{result: true}"
 }
 
@@ -1496,11 +2031,11 @@ func taskRecoverFromUpdate() string {
 	return "{result: true}"
 }
 
-func taskSetReleaseVersion() string {
+func taskSetReleaseVersion(source options.Source) string {
 
 	fmt.Println("Executing taskSetReleaseVersion")
 
-	utils.WriteOption("RELEASE_VERSION", diagnostics.Version)
+	source.SetString("RELEASE_VERSION", diagnostics.Version)
 
 	return diagnostics.Version
 }
@@ -1512,11 +2047,11 @@ func taskUpdateSystemLoggerServices() string {
 	var input []string
 
 	// Get the services
-	edgeAppsList := utils.ReadOption("EDGEAPPS_LIST")
 	var edgeApps []edgeapps.EdgeApp
-	err := json.Unmarshal([]byte(edgeAppsList), &edgeApps)
+	err := options.GetJSON("EDGEAPPS_LIST", &edgeApps)
 	if err != nil {
-		log.Fatalf("failed to unmarshal EDGEAPPS_LIST: %v", err)
+		log.Printf("failed to read EDGEAPPS_LIST: %v", err)
+		return "{\"status\": \"error\"}"
 	}
 
 	for _, edgeApp := range edgeApps {
@@ -1534,51 +2069,52 @@ func taskUpdateSystemLoggerServices() string {
 	return "{\"status\": \"ok\"}"
 }
 
-func taskGetEdgeApps() string {
+func taskGetEdgeApps(source options.Source) string {
 	fmt.Println("Executing taskGetEdgeApps")
 
 	edgeApps := edgeapps.GetEdgeApps()
 	edgeAppsJSON, _ := json.Marshal(edgeApps)
 
-	utils.WriteOption("EDGEAPPS_LIST", string(edgeAppsJSON))
+	source.SetJSON("EDGEAPPS_LIST", edgeApps)
 	return string(edgeAppsJSON)
 }
 
-func taskGetSystemUptime() string {
+func taskGetSystemUptime(source options.Source) string {
 	fmt.Println("Executing taskGetSystemUptime")
 	uptime := system.GetUptimeInSeconds()
-	utils.WriteOption("SYSTEM_UPTIME", uptime)
+	seconds, _ := strconv.ParseInt(uptime, 10, 64)
+	source.SetInt("SYSTEM_UPTIME", seconds)
 	return uptime
 }
 
-func taskGetStorageDevices() string {
+func taskGetStorageDevices(source options.Source) string {
 	fmt.Println("Executing taskGetStorageDevices")
 
 	devices := storage.GetDevices(diagnostics.GetReleaseVersion())
 	devicesJSON, _ := json.Marshal(devices)
 
-	utils.WriteOption("STORAGE_DEVICES_LIST", string(devicesJSON))
+	source.SetJSON("STORAGE_DEVICES_LIST", devices)
 
 	return string(devicesJSON)
 }
 
-func taskGetSystemIP() string {
+func taskGetSystemIP(source options.Source) string {
 	fmt.Println("Executing taskGetStorageDevices")
 	ip := system.GetIP()
-	utils.WriteOption("IP_ADDRESS", ip)
+	source.SetString("IP_ADDRESS", ip)
 	return ip
 }
 
-func taskGetHostname() string {
+func taskGetHostname(source options.Source) string {
 	fmt.Println("Executing taskGetHostname")
 	hostname := system.GetHostname()
-	utils.WriteOption("HOSTNAME", hostname)
+	source.SetString("HOSTNAME", hostname)
 	return hostname
 }
 
-func taskSetupCloudOptions() {
+func taskSetupCloudOptions(source options.Source) {
 	fmt.Println("Executing taskSetupCloudOptions")
-	system.SetupCloudOptions()
+	system.SetupCloudOptions(source)
 }
 
 func taskStartWs() {
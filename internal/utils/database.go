@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/config"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// busyTimeout : How long SQLite waits for a lock held by another connection (ex: the api) before failing with SQLITE_BUSY
+const busyTimeout time.Duration = time.Second * 5
+const busyRetries int = 3
+const busyRetryDelay time.Duration = time.Millisecond * 200
+
+var database *sql.DB
+var databaseLocation string
+var databaseMutex sync.Mutex
+var migratedSchemaVersion int // Schema version of the open database, as of its last migration by this process
+
+// GetDatabase : Returns the connection pool to the api shared database, opening it on first use. Never creates the database file.
+func GetDatabase() (*sql.DB, error) {
+
+	location, err := GetSQLiteDbLocation()
+	if err != nil {
+		return nil, err
+	}
+	if location == "" {
+		return nil, errors.New("database location not configured")
+	}
+
+	databaseMutex.Lock()
+	defer databaseMutex.Unlock()
+
+	if database != nil && databaseLocation == location {
+		return database, nil
+	}
+
+	db, err := sql.Open("sqlite3", getSQLiteDSN(location, config.Get().DatabaseJournalMode))
+	if err != nil {
+		return nil, err
+	}
+
+	if database != nil {
+		database.Close()
+	}
+	database = db
+	databaseLocation = location
+	migratedSchemaVersion = 0
+
+	return database, nil
+}
+
+// getSQLiteDSN : Returns the connection string for the database at location. mode=rw makes opening fail instead of creating a missing database.
+func getSQLiteDSN(location string, journalMode string) string {
+	params := url.Values{}
+	params.Set("mode", "rw")
+	params.Set("_busy_timeout", strconv.FormatInt(busyTimeout.Milliseconds(), 10))
+	if journalMode != "" {
+		params.Set("_journal_mode", strings.ToUpper(journalMode))
+	}
+	return "file:" + location + "?" + params.Encode()
+}
+
+// isBusy : Returns true if err is SQLite reporting the database (or a table) as locked by another connection
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
+
+// retryOnBusy : Runs operation, running it again (up to busyRetries times) while it fails because the database is locked
+func retryOnBusy(operation func() error) error {
+	err := operation()
+	for attempt := 1; attempt <= busyRetries && isBusy(err); attempt++ {
+		time.Sleep(busyRetryDelay * time.Duration(attempt))
+		err = operation()
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// envValueEscaper : Escapes what is special inside double quotes, for both godotenv and docker compose env files
+var envValueEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	`$`, `\$`,
+	"\n", `\n`,
+	"\r", `\r`,
+)
+
+// MarshalEnv : Formats env as the contents of an env file, one KEY="value" line per key, sorted by key
+func MarshalEnv(env map[string]string) string {
+
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var content strings.Builder
+	for _, key := range keys {
+		content.WriteString(key + "=\"" + envValueEscaper.Replace(env[key]) + "\"\n")
+	}
+
+	return content.String()
+}
+
+// WriteEnvFile : Atomically replaces the env file at path with env. The new contents are written to a temporary file in the same folder, synced and renamed over path, so it is never left half written.
+func WriteEnvFile(path string, env map[string]string, perm os.FileMode) error {
+
+	dir := filepath.Dir(path)
+	file, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString(MarshalEnv(env))
+	if err == nil {
+		err = file.Chmod(perm)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(file.Name(), path)
+	if err != nil {
+		return err
+	}
+
+	// The rename itself is only durable once the folder is synced
+	folder, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer folder.Close()
+	return folder.Sync()
+}
+
+// MergeEnvFile : Sets values in the env file at path, keeping the other values it has. The file is created when missing, and replaced atomically.
+func MergeEnvFile(path string, values map[string]string, perm os.FileMode) error {
+
+	env, err := godotenv.Read(path)
+	if os.IsNotExist(err) {
+		env = map[string]string{}
+	} else if err != nil {
+		return err
+	}
+
+	for key, value := range values {
+		env[key] = value
+	}
+
+	return WriteEnvFile(path, env, perm)
+}
@@ -0,0 +1,88 @@
+// +build unit
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joho/godotenv"
+)
+
+func TestMergeEnvFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "edgeboxctl-env")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "edgeapp.env")
+	err := ioutil.WriteFile(path, []byte("KEPT=old\nCHANGED=a much longer previous value\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string]string{
+		"CHANGED":  "short",
+		"SPACES":   "hello world # not a comment",
+		"QUOTES":   `say "hi" it's me`,
+		"SPECIAL":  `$HOME \n ${KEPT} \ ! ` + "`",
+		"NEWLINES": "line one\nline two",
+		"EMPTY":    "",
+	}
+	err = MergeEnvFile(path, values, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := godotenv.Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values["KEPT"] = "old"
+	if len(env) != len(values) {
+		t.Log("Expected", values, "got", env)
+		t.Fail()
+	}
+	for key, value := range values {
+		if env[key] != value {
+			t.Log("Expected", key, "to read back as", value, "got", env[key])
+			t.Fail()
+		}
+	}
+
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0600 {
+		t.Log("Expected the file mode to be 0600, got", info.Mode().Perm())
+		t.Fail()
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Log("Expected temporary files to be removed, got", len(files), "files")
+		t.Fail()
+	}
+}
+
+func TestMergeEnvFileMissing(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "edgeboxctl-env")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "myedgeapp.env")
+	err := MergeEnvFile(path, map[string]string{"INTERNET_URL": "app.example.com"}, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := ioutil.ReadFile(path)
+	if string(content) != "INTERNET_URL=\"app.example.com\"\n" {
+		t.Log("Unexpected contents", string(content))
+		t.Fail()
+	}
+
+	err = MergeEnvFile(filepath.Join(dir, "missing", "auth.env"), map[string]string{"USERNAME": "admin"}, 0600)
+	if err == nil {
+		t.Log("Expected an error writing to a missing folder")
+		t.Fail()
+	}
+}
@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Migration : A versioned change to the tables owned by edgeboxctl in the api database. The task and option tables belong to the api and are not migrated here.
+type Migration struct {
+	Version     int
+	Description string
+	Statements  []string
+}
+
+// schemaMigrations : Every migration, in version order. Never edit or remove a released migration, add a new one with the next version instead.
+// Statements should tolerate objects created by versions of edgeboxctl from before migrations existed (ex: CREATE TABLE IF NOT EXISTS).
+var schemaMigrations = []Migration{
+	{
+		Version:     1,
+		Description: "Create webhook_delivery",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS webhook_delivery (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				delivery_id TEXT NOT NULL,
+				webhook_id TEXT NOT NULL,
+				event TEXT NOT NULL,
+				url TEXT NOT NULL,
+				attempt INTEGER NOT NULL,
+				status_code INTEGER NOT NULL,
+				error TEXT NOT NULL,
+				created TEXT NOT NULL
+			);`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "Create option_history",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS option_history (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL,
+				old_value TEXT NOT NULL,
+				new_value TEXT NOT NULL,
+				source TEXT NOT NULL,
+				created TEXT NOT NULL
+			);`,
+			"CREATE INDEX IF NOT EXISTS option_history_name ON option_history (name, id);",
+		},
+	},
+}
+
+const createSchemaVersionTable string = `CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER PRIMARY KEY,
+	description TEXT NOT NULL,
+	applied TEXT NOT NULL
+);`
+
+// LatestSchemaVersion : Returns the schema version this build of edgeboxctl expects
+func LatestSchemaVersion() int {
+	if len(schemaMigrations) == 0 {
+		return 0
+	}
+	return schemaMigrations[len(schemaMigrations)-1].Version
+}
+
+// GetSchemaVersion : Returns the version of the last migration applied to the api database, 0 if none was
+func GetSchemaVersion() (int, error) {
+	db, err := GetDatabase()
+	if err != nil {
+		return 0, err
+	}
+	return getSchemaVersion(db)
+}
+
+func getSchemaVersion(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}) (int, error) {
+	var version sql.NullInt64
+	err := q.QueryRow("SELECT MAX(version) FROM schema_version;").Scan(&version)
+	return int(version.Int64), err
+}
+
+// MigrateDatabase : Applies the migrations newer than the schema version of the api database, each one in its own transaction.
+// Safe to run on every start, and concurrently with another edgeboxctl process. Returns the number of migrations applied.
+func MigrateDatabase() (int, error) {
+
+	db, err := GetDatabase()
+	if err != nil {
+		return 0, err
+	}
+
+	err = retryOnBusy(func() error {
+		_, err := db.Exec(createSchemaVersionTable)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, migration := range schemaMigrations {
+		var done bool
+		err = retryOnBusy(func() error {
+			done, err = applyMigration(db, migration)
+			return err
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s) failed: %s", migration.Version, migration.Description, err)
+		}
+		if done {
+			log.Printf("Applied database migration %d (%s)", migration.Version, migration.Description)
+			applied++
+		}
+	}
+
+	version, err := getSchemaVersion(db)
+	if err != nil {
+		return applied, err
+	}
+
+	databaseMutex.Lock()
+	if database == db {
+		migratedSchemaVersion = version
+	}
+	databaseMutex.Unlock()
+
+	return applied, nil
+}
+
+// applyMigration : Applies a single migration unless the database already is at its version or newer. Returns true if it was applied.
+func applyMigration(db *sql.DB, migration Migration) (bool, error) {
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	// Checked within the transaction, so a migration applied meanwhile by another process is not applied twice
+	version, err := getSchemaVersion(tx)
+	if err != nil || version >= migration.Version {
+		tx.Rollback()
+		return false, err
+	}
+
+	for _, statement := range migration.Statements {
+		_, err = tx.Exec(statement)
+		if err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO schema_version (version, description, applied) VALUES (?, ?, ?);",
+		migration.Version, migration.Description, GetSQLiteFormattedDateTime(time.Now()),
+	)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
@@ -0,0 +1,155 @@
+// +build unit
+
+package utils
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/config"
+)
+
+func setupTestMigrationDatabase(t *testing.T, statements ...string) (*sql.DB, func()) {
+	dir, _ := ioutil.TempDir("", "edgeboxctl-migrations")
+	database := filepath.Join(dir, "test.sqlite")
+	ioutil.WriteFile(database, nil, 0644)
+
+	db, err := sql.Open("sqlite3", database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range statements {
+		_, err = db.Exec(statement)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	c := config.Default()
+	c.Database = database
+	config.Set(c)
+
+	shared, err := GetDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return shared, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSchemaMigrationsAreOrdered(t *testing.T) {
+	previous := 0
+	for _, migration := range schemaMigrations {
+		if migration.Version != previous+1 {
+			t.Log("Expected migration", previous+1, "got", migration.Version)
+			t.Fail()
+		}
+		previous = migration.Version
+	}
+}
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	// An empty file, as no api tables are needed by the migrations
+	db, cleanup := setupTestMigrationDatabase(t)
+	defer cleanup()
+
+	applied, err := MigrateDatabase()
+	if err != nil || applied != len(schemaMigrations) {
+		t.Fatal("Expected every migration to be applied, got", applied, err)
+	}
+
+	version, err := GetSchemaVersion()
+	if err != nil || version != LatestSchemaVersion() {
+		t.Log("Expected schema version", LatestSchemaVersion(), "got", version, err)
+		t.Fail()
+	}
+
+	_, err = db.Exec("INSERT INTO webhook_delivery (delivery_id, webhook_id, event, url, attempt, status_code, error, created) VALUES ('d', 'w', 'e', 'u', 1, 200, '', '');")
+	if err != nil {
+		t.Log("Expected webhook_delivery to be usable:", err)
+		t.Fail()
+	}
+
+	applied, err = MigrateDatabase()
+	if err != nil || applied != 0 {
+		t.Log("Expected migrating again to do nothing, got", applied, err)
+		t.Fail()
+	}
+}
+
+func TestMigrateExistingDatabase(t *testing.T) {
+	// A database as left by versions of edgeboxctl that created webhook_delivery themselves
+	db, cleanup := setupTestMigrationDatabase(t,
+		"CREATE TABLE option (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT UNIQUE, value TEXT, created TEXT, updated TEXT);",
+		"CREATE TABLE webhook_delivery (id INTEGER PRIMARY KEY AUTOINCREMENT, delivery_id TEXT NOT NULL, webhook_id TEXT NOT NULL, event TEXT NOT NULL, url TEXT NOT NULL, attempt INTEGER NOT NULL, status_code INTEGER NOT NULL, error TEXT NOT NULL, created TEXT NOT NULL);",
+		"INSERT INTO webhook_delivery (delivery_id, webhook_id, event, url, attempt, status_code, error, created) VALUES ('d', 'w', 'e', 'u', 1, 200, '', '');",
+		"INSERT INTO option (name, value) VALUES ('HOSTNAME', 'edgebox');",
+	)
+	defer cleanup()
+
+	_, err := MigrateDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deliveries int
+	db.QueryRow("SELECT COUNT(*) FROM webhook_delivery;").Scan(&deliveries)
+	if deliveries != 1 {
+		t.Log("Expected existing deliveries to be kept, got", deliveries)
+		t.Fail()
+	}
+
+	if ReadOption("HOSTNAME") != "edgebox" {
+		t.Log("Expected options to be left untouched")
+		t.Fail()
+	}
+
+	if version, _ := GetSchemaVersion(); version != LatestSchemaVersion() {
+		t.Log("Expected schema version", LatestSchemaVersion(), "got", version)
+		t.Fail()
+	}
+}
+
+func TestFailedMigrationIsRolledBack(t *testing.T) {
+	db, cleanup := setupTestMigrationDatabase(t)
+	defer cleanup()
+
+	released := schemaMigrations
+	defer func() { schemaMigrations = released }()
+
+	schemaMigrations = append(append([]Migration(nil), released...), Migration{
+		Version:     len(released) + 1,
+		Description: "Broken",
+		Statements: []string{
+			"CREATE TABLE half_done (id INTEGER);",
+			"THIS IS NOT SQL;",
+		},
+	})
+
+	applied, err := MigrateDatabase()
+	if err == nil {
+		t.Fatal("Expected the broken migration to fail")
+	}
+	if applied != len(released) {
+		t.Log("Expected the migrations before the broken one to be applied, got", applied)
+		t.Fail()
+	}
+
+	if version, _ := GetSchemaVersion(); version != len(released) {
+		t.Log("Expected the schema version not to include the broken migration, got", version)
+		t.Fail()
+	}
+
+	var name string
+	err = db.QueryRow("SELECT name FROM sqlite_master WHERE name = 'half_done';").Scan(&name)
+	if err != sql.ErrNoRows {
+		t.Log("Expected the broken migration to be rolled back, got", name, err)
+		t.Fail()
+	}
+}
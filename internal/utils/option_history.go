@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// OPTION_HISTORY_LIMIT : Number of changes kept in the option history for each option, older ones are deleted
+const OPTION_HISTORY_LIMIT int = 100
+
+// DEFAULT_OPTION_SOURCE : Source recorded for option changes written without one
+const DEFAULT_OPTION_SOURCE string = "edgeboxctl"
+
+// optionHistorySchemaVersion : Schema version that created the option_history table
+const optionHistorySchemaVersion int = 2
+
+// OptionChange : Struct representing a change of an option, as recorded in the option history. Empty values mean the option was not set.
+type OptionChange struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	OldValue string `json:"old_value"`
+	NewValue string `json:"new_value"`
+	Source   string `json:"source"`
+	Created  string `json:"created"`
+}
+
+// OptionHistoryFilter : Decides whether a change of an option is kept in the option history, and the values recorded for it (ex: redacted)
+type OptionHistoryFilter func(name string, oldValue string, newValue string) (keep bool, recordedOld string, recordedNew string)
+
+var optionHistoryFilter OptionHistoryFilter = func(name string, oldValue string, newValue string) (bool, string, string) {
+	return oldValue != newValue, oldValue, newValue
+}
+
+var optionHistoryMutex sync.Mutex
+
+// SetOptionHistoryFilter : Replaces the filter deciding which option changes are recorded. The options package sets it from the option registry.
+func SetOptionHistoryFilter(filter OptionHistoryFilter) {
+	optionHistoryMutex.Lock()
+	defer optionHistoryMutex.Unlock()
+	optionHistoryFilter = filter
+}
+
+// isOptionHistoryReady : Returns true once the option_history table was migrated in the current database. Before that, changes are not recorded.
+func isOptionHistoryReady() bool {
+	databaseMutex.Lock()
+	defer databaseMutex.Unlock()
+	return migratedSchemaVersion >= optionHistorySchemaVersion
+}
+
+// recordOptionChange : Records a change of an option in the option history (if the filter keeps it), deleting its changes over OPTION_HISTORY_LIMIT
+func recordOptionChange(tx *sql.Tx, name string, oldValue string, newValue string, source string, created string) error {
+
+	optionHistoryMutex.Lock()
+	filter := optionHistoryFilter
+	optionHistoryMutex.Unlock()
+
+	keep, oldValue, newValue := filter(name, oldValue, newValue)
+	if !keep {
+		return nil
+	}
+
+	_, err := tx.Exec(
+		"INSERT INTO option_history (name, old_value, new_value, source, created) VALUES (?, ?, ?, ?, ?);",
+		name, oldValue, newValue, source, created,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"DELETE FROM option_history WHERE name = ? AND id NOT IN (SELECT id FROM option_history WHERE name = ? ORDER BY id DESC LIMIT ?);",
+		name, name, OPTION_HISTORY_LIMIT,
+	)
+	return err
+}
+
+// RecordChange : Records a change of an option made by someone else than edgeboxctl (ex: the dashboard), as detected after the fact
+func (s *OptionStore) RecordChange(name string, oldValue string, newValue string, source string) error {
+
+	if !isOptionHistoryReady() {
+		return nil
+	}
+
+	return retryOnBusy(func() error {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+
+		err = recordOptionChange(tx, name, oldValue, newValue, source, GetSQLiteFormattedDateTime(time.Now()))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// History : Returns the most recent changes of an option (or of every option, if name is empty), newest first
+func (s *OptionStore) History(name string, limit int) ([]OptionChange, error) {
+
+	changes := []OptionChange{}
+
+	query := "SELECT id, name, old_value, new_value, source, created FROM option_history"
+	args := []interface{}{}
+	if name != "" {
+		query += " WHERE name = ?"
+		args = append(args, name)
+	}
+	query += " ORDER BY id DESC LIMIT ?;"
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return changes, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var change OptionChange
+		err = rows.Scan(&change.ID, &change.Name, &change.OldValue, &change.NewValue, &change.Source, &change.Created)
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, rows.Err()
+}
@@ -0,0 +1,72 @@
+// +build unit
+
+package utils
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestOptionHistory(t *testing.T) {
+	store, cleanup := setupTestOptionStore(t)
+	defer cleanup()
+
+	// Not recorded before the option_history table is migrated
+	store.Set("TUNNEL_STATUS", "waiting", "")
+
+	_, err := MigrateDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.Set("TUNNEL_STATUS", "connected", "task:setup_tunnel#1")
+	store.Set("TUNNEL_STATUS", "connected", "task:setup_tunnel#2")
+	store.Delete("TUNNEL_STATUS", "")
+
+	changes, err := store.History("TUNNEL_STATUS", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []OptionChange{
+		{Name: "TUNNEL_STATUS", OldValue: "connected", NewValue: "", Source: DEFAULT_OPTION_SOURCE},
+		{Name: "TUNNEL_STATUS", OldValue: "waiting", NewValue: "connected", Source: "task:setup_tunnel#1"},
+	}
+	if len(changes) != len(expected) {
+		t.Fatal("Expected", expected, "got", changes)
+	}
+	for i := range expected {
+		change := changes[i]
+		if change.Name != expected[i].Name || change.OldValue != expected[i].OldValue || change.NewValue != expected[i].NewValue || change.Source != expected[i].Source {
+			t.Log("Expected", expected[i], "got", change)
+			t.Fail()
+		}
+	}
+}
+
+func TestOptionHistoryLimit(t *testing.T) {
+	store, cleanup := setupTestOptionStore(t)
+	defer cleanup()
+	MigrateDatabase()
+
+	for i := 0; i < OPTION_HISTORY_LIMIT+10; i++ {
+		store.SetMany(map[string]string{"BACKUP_LAST_RUN": strconv.Itoa(i), "HOSTNAME": "edgebox"}, "")
+	}
+
+	changes, _ := store.History("BACKUP_LAST_RUN", OPTION_HISTORY_LIMIT*2)
+	if len(changes) != OPTION_HISTORY_LIMIT {
+		t.Log("Expected", OPTION_HISTORY_LIMIT, "changes to be kept, got", len(changes))
+		t.Fail()
+	}
+	if len(changes) > 0 && changes[0].NewValue != strconv.Itoa(OPTION_HISTORY_LIMIT+9) {
+		t.Log("Expected the most recent changes to be kept, got", changes[0])
+		t.Fail()
+	}
+
+	// The limit applies to each option, noisy options do not evict the others
+	changes, _ = store.History("HOSTNAME", 10)
+	if len(changes) != 1 {
+		t.Log("Expected the change of HOSTNAME to be kept, got", changes)
+		t.Fail()
+	}
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrOptionNotFound : Returned by OptionStore.Get when the option was never written (or was deleted)
+var ErrOptionNotFound = errors.New("option not found")
+
+// OptionStore : Key value options in the api shared database, read by the dashboard
+type OptionStore struct {
+	db *sql.DB
+}
+
+// NewOptionStore : Returns an OptionStore over the given database
+func NewOptionStore(db *sql.DB) *OptionStore {
+	return &OptionStore{db: db}
+}
+
+// GetOptionStore : Returns an OptionStore over the shared connection to the api database
+func GetOptionStore() (*OptionStore, error) {
+	db, err := GetDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return NewOptionStore(db), nil
+}
+
+// Get : Returns the value of an option, or ErrOptionNotFound if it does not exist
+func (s *OptionStore) Get(name string) (string, error) {
+	var value sql.NullString
+	err := retryOnBusy(func() error {
+		return s.db.QueryRow("SELECT value FROM option WHERE name = ?;", name).Scan(&value)
+	})
+	if err == sql.ErrNoRows {
+		return "", ErrOptionNotFound
+	}
+	return value.String, err
+}
+
+// Set : Writes the value of an option, creating it if needed. source is who writes it (ex: the task being executed), as recorded in the option history.
+func (s *OptionStore) Set(name string, value string, source string) error {
+	return s.SetMany(map[string]string{name: value}, source)
+}
+
+// SetMany : Writes several options in a single transaction, so either all of them or none are written
+func (s *OptionStore) SetMany(values map[string]string, source string) error {
+	changes := make(map[string]*string, len(values))
+	for name := range values {
+		value := values[name]
+		changes[name] = &value
+	}
+	return s.apply(changes, source)
+}
+
+// Delete : Deletes an option. Deleting an option that does not exist is not an error.
+func (s *OptionStore) Delete(name string, source string) error {
+	return s.apply(map[string]*string{name: nil}, source)
+}
+
+// apply : Writes (or deletes, for nil values) options in a single transaction, recording the changes in the option history on behalf of source
+// (DEFAULT_OPTION_SOURCE when empty)
+func (s *OptionStore) apply(changes map[string]*string, source string) error {
+
+	// Always write in the same order, so concurrent batches acquire rows alike
+	names := make([]string, 0, len(changes))
+	for name := range changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	recordHistory := isOptionHistoryReady()
+	if source == "" {
+		source = DEFAULT_OPTION_SOURCE
+	}
+
+	return retryOnBusy(func() error {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+
+		formatedDatetime := GetSQLiteFormattedDateTime(time.Now())
+		for _, name := range names {
+			var previous sql.NullString
+			if recordHistory {
+				err = tx.QueryRow("SELECT value FROM option WHERE name = ?;", name).Scan(&previous)
+				if err == sql.ErrNoRows {
+					err = nil
+				}
+			}
+
+			value := ""
+			if err == nil && changes[name] == nil {
+				_, err = tx.Exec("DELETE FROM option WHERE name = ?;", name)
+			} else if err == nil {
+				value = *changes[name]
+				_, err = tx.Exec("REPLACE INTO option (name, value, created, updated) VALUES (?, ?, ?, ?);", name, value, formatedDatetime, formatedDatetime)
+			}
+
+			if err == nil && recordHistory {
+				err = recordOptionChange(tx, name, previous.String, value, source, formatedDatetime)
+			}
+
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		return tx.Commit()
+	})
+}
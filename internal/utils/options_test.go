@@ -0,0 +1,114 @@
+// +build unit
+
+package utils
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/config"
+)
+
+func setupTestOptionStore(t *testing.T) (*OptionStore, func()) {
+	location, cleanup := SetupTestConfig(t)
+
+	// Migrations are left to the tests, which check what is recorded before the option_history table exists
+	db, err := sql.Open("sqlite3", location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(TEST_OPTION_TABLE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	store, err := GetOptionStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store, cleanup
+}
+
+func TestOptionStore(t *testing.T) {
+	store, cleanup := setupTestOptionStore(t)
+	defer cleanup()
+
+	_, err := store.Get("DOMAIN_NAME")
+	if err != ErrOptionNotFound {
+		t.Log("Expected ErrOptionNotFound, got", err)
+		t.Fail()
+	}
+
+	err = store.Set("DOMAIN_NAME", "example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.SetMany(map[string]string{"DOMAIN_NAME": "edgebox.io", "HOSTNAME": "edgebox"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := store.Get("DOMAIN_NAME")
+	if err != nil || value != "edgebox.io" {
+		t.Log("Expected DOMAIN_NAME to be edgebox.io, got", value, err)
+		t.Fail()
+	}
+
+	if ReadOption("HOSTNAME") != "edgebox" {
+		t.Log("Expected ReadOption to read the value written by SetMany")
+		t.Fail()
+	}
+
+	err = store.Delete("DOMAIN_NAME", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.Get("DOMAIN_NAME")
+	if err != ErrOptionNotFound {
+		t.Log("Expected ErrOptionNotFound after delete, got", err)
+		t.Fail()
+	}
+}
+
+func TestOptionStoreSetManyIsAtomic(t *testing.T) {
+	store, cleanup := setupTestOptionStore(t)
+	defer cleanup()
+
+	db, _ := GetDatabase()
+	db.Exec("CREATE TRIGGER reject_invalid BEFORE INSERT ON option WHEN NEW.name = 'INVALID' BEGIN SELECT RAISE(ABORT, 'rejected'); END;")
+
+	err := store.SetMany(map[string]string{"BACKUP_SERVICE": "s3", "INVALID": "value"}, "")
+	if err == nil {
+		t.Fatal("Expected the batch to fail")
+	}
+
+	_, err = store.Get("BACKUP_SERVICE")
+	if err != ErrOptionNotFound {
+		t.Log("Expected no option of a failed batch to be written, got", err)
+		t.Fail()
+	}
+}
+
+func TestGetDatabaseDoesNotCreateDatabase(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "edgeboxctl-options")
+	defer os.RemoveAll(dir)
+	database := filepath.Join(dir, "missing.sqlite")
+
+	c := config.Default()
+	c.Database = database
+	config.Set(c)
+
+	WriteOption("DOMAIN_NAME", "example.com")
+
+	if _, err := os.Stat(database); !os.IsNotExist(err) {
+		t.Log("Expected the missing database not to be created")
+		t.Fail()
+	}
+}
@@ -0,0 +1,57 @@
+// +build unit
+
+package utils
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/config"
+)
+
+// Tables of the api database created by the api itself, for unit tests of other packages
+const (
+	TEST_OPTION_TABLE string = "CREATE TABLE option (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT UNIQUE, value TEXT, created TEXT, updated TEXT);"
+	TEST_TASK_TABLE   string = "CREATE TABLE task (id INTEGER PRIMARY KEY AUTOINCREMENT, task TEXT, args TEXT, status INTEGER, result TEXT, created TEXT, updated TEXT);"
+)
+
+// SetupTestConfig : Loads the default configuration with the database and the device key in a new temporary folder, without creating the database.
+// Returns the database location and a function removing the folder.
+func SetupTestConfig(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "edgeboxctl-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := config.Default()
+	c.Database = filepath.Join(dir, "test.sqlite")
+	c.Paths.SecretKeyFile = filepath.Join(dir, "secret.key")
+	config.Set(c)
+
+	return c.Database, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+// SetupTestDatabase : Like SetupTestConfig, creating the database with the option and task tables of the api and the edgeboxctl migrations applied
+func SetupTestDatabase(t *testing.T) func() {
+	location, cleanup := SetupTestConfig(t)
+
+	db, err := sql.Open("sqlite3", location)
+	if err == nil {
+		_, err = db.Exec(TEST_OPTION_TABLE + TEST_TASK_TABLE)
+		db.Close()
+	}
+	if err == nil {
+		_, err = MigrateDatabase()
+	}
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+
+	return cleanup
+}
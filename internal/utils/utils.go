@@ -3,7 +3,6 @@ package utils
 import (
 	"bufio"
 	"bytes"
-	"database/sql"
 	"fmt"
 	"io"
 	"log"
@@ -12,16 +11,27 @@ import (
 	"strings"
 	"time"
 
+	"github.com/edgebox-iot/edgeboxctl/internal/config"
+
 	"github.com/joho/godotenv"
 )
 
 // ExecAndStream : Runs a terminal command, but streams progress instead of outputting. Ideal for long lived process that need to be logged.
 func ExecAndStream(path string, command string, args []string) string {
+	return ExecAndStreamLines(path, command, args, nil)
+}
+
+// ExecAndStreamLines : Same as ExecAndStream, additionally calling onLine (if not nil) for every line written to stdout, as soon as it is written.
+func ExecAndStreamLines(path string, command string, args []string, onLine func(line string)) string {
 
 	cmd := exec.Command(command, args...)
 
 	var stdoutBuf, stderrBuf bytes.Buffer
-	cmd.Stdout = io.MultiWriter(os.Stdout, &stdoutBuf)
+	stdoutWriters := []io.Writer{os.Stdout, &stdoutBuf}
+	if onLine != nil {
+		stdoutWriters = append(stdoutWriters, &lineWriter{onLine: onLine})
+	}
+	cmd.Stdout = io.MultiWriter(stdoutWriters...)
 	cmd.Stderr = io.MultiWriter(os.Stderr, &stderrBuf)
 	cmd.Dir = path
 
@@ -40,6 +50,25 @@ func ExecAndStream(path string, command string, args []string) string {
 	return returnVal
 }
 
+// lineWriter : io.Writer calling onLine for every complete line written to it
+type lineWriter struct {
+	onLine  func(line string)
+	pending []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		index := bytes.IndexByte(w.pending, '\n')
+		if index < 0 {
+			break
+		}
+		w.onLine(strings.TrimRight(string(w.pending[:index]), "\r"))
+		w.pending = w.pending[index+1:]
+	}
+	return len(p), nil
+}
+
 // Exec : Runs a terminal Command, catches and logs errors, returns the result.
 func Exec(path string, command string, args []string) string {
 	cmd := exec.Command(command, args...)
@@ -84,15 +113,28 @@ func DeleteEmptySlices(s []string) []string {
 // GetSQLiteDbConnectionDetails : Returns the necessary string as connection info for SQL.db()
 func GetSQLiteDbConnectionDetails() string {
 
-	var apiEnv map[string]string
-	apiEnv, err := godotenv.Read(GetPath(ApiEnvFileLocation))
-
+	database, err := GetSQLiteDbLocation()
 	if err != nil {
 		log.Fatal("Error loading .env file")
 	}
 
-	return apiEnv["SQLITE_DATABASE"] // Will read from api project edgebox.env file
+	return database
+
+}
+
+// GetSQLiteDbLocation : Returns the location of the api SQLite database, or an error if it cannot be determined
+func GetSQLiteDbLocation() (string, error) {
+
+	if database := config.Get().Database; database != "" {
+		return database, nil
+	}
+
+	apiEnv, err := godotenv.Read(GetPath(ApiEnvFileLocation))
+	if err != nil {
+		return "", err
+	}
 
+	return apiEnv["SQLITE_DATABASE"], nil // Will read from api project edgebox.env file
 }
 
 // GetSQLiteFormattedDateTime: Given a Time, Returns a string that is formatted ready to be inserted into an SQLite Datetime field using sql.Prepare.
@@ -110,110 +152,65 @@ const ApiEnvFileLocation string = "apiEnvFileLocation"
 const ApiPath string = "apiPath"
 const EdgeAppsPath string = "edgeAppsPath"
 const EdgeAppsBackupPath string = "edgeAppsBackupPath"
+const EdgeAppExportsPath string = "edgeAppExportsPath"
+const EdgeAppLogsPath string = "edgeAppLogsPath"
 const WsPath string = "wsPath"
 const BrowserDevPath string = "browserDevPath"
 const LoggerPath string = "loggerPath"
 const BrowserDevPasswordFileLocation string = "browserDevPasswordFileLocation"
 const BrowserDevProxyPath string = "browserDevProxyPath"
-
-
-// GetPath : Returns either the hardcoded path, or a overwritten value via .env file at project root. Register paths here for seamless working code between dev and prod environments ;)
+const ScriptsPath string = "scriptsPath"
+const UpdaterPath string = "updaterPath"
+const CloudflaredPath string = "cloudflaredPath"
+const CloudflaredRootPath string = "cloudflaredRootPath"
+const CloudflaredServiceConfigLocation string = "cloudflaredServiceConfigLocation"
+const SecretKeyFileLocation string = "secretKeyFileLocation"
+
+// GetPath : Returns the path registered under pathKey in the loaded configuration (see the config package for defaults and overrides).
 func GetPath(pathKey string) string {
 
-	// Read whole of .env file to map.
-	var env map[string]string
-	env, err := godotenv.Read()
+	paths := config.Get().Paths
 	var targetPath string
 
-	if err != nil {
-		targetPath = ""
-	}
-
 	switch pathKey {
 	case CloudEnvFileLocation:
-
-		if env["CLOUD_ENV_FILE_LOCATION"] != "" {
-			targetPath = env["CLOUD_ENV_FILE_LOCATION"]
-		} else {
-			targetPath = "/home/system/components/api/cloud.env"
-		}
-
+		targetPath = paths.CloudEnvFile
 	case ApiEnvFileLocation:
-
-		if env["API_ENV_FILE_LOCATION"] != "" {
-			targetPath = env["API_ENV_FILE_LOCATION"]
-		} else {
-			targetPath = "/home/system/components/api/edgebox.env"
-		}
-
+		targetPath = paths.ApiEnvFile
 	case ApiPath:
-
-		if env["API_PATH"] != "" {
-			targetPath = env["API_PATH"]
-		} else {
-			targetPath = "/home/system/components/api/"
-		}
-
+		targetPath = paths.Api
 	case EdgeAppsPath:
-
-		if env["EDGEAPPS_PATH"] != "" {
-			targetPath = env["EDGEAPPS_PATH"]
-		} else {
-			targetPath = "/home/system/components/apps/"
-		}
-
+		targetPath = paths.EdgeApps
 	case EdgeAppsBackupPath:
-		if env["EDGEAPPS_BACKUP_PATH"] != "" {
-			targetPath = env["EDGEAPPS_BACKUP_PATH"]
-		} else {
-			targetPath = "/home/system/components/backups/"
-		}
-
+		targetPath = paths.EdgeAppsBackup
+	case EdgeAppExportsPath:
+		targetPath = paths.EdgeAppExports
+	case EdgeAppLogsPath:
+		targetPath = paths.EdgeAppLogs
 	case WsPath:
-
-		if env["WS_PATH"] != "" {
-			targetPath = env["WS_PATH"]
-		} else {
-			targetPath = "/home/system/components/ws/"
-		}
-
+		targetPath = paths.Ws
 	case BrowserDevPath:
-
-		if env["BROWSERDEV_PATH"] != "" {
-			targetPath = env["BROWSERDEV_PATH"]
-		} else {
-			targetPath = "/home/system/components/dev/"
-		}
-
+		targetPath = paths.BrowserDev
 	case LoggerPath:
-		if env["LOGGER_PATH"] != "" {
-			targetPath = env["LOGGER_PATH"]
-		} else {
-			targetPath = "/home/system/components/logger/"
-		}
-
+		targetPath = paths.Logger
 	case BackupPasswordFileLocation:
-
-		if env["BACKUP_PASSWORD_FILE_LOCATION"] != "" {
-			targetPath = env["BACKUP_PASSWORD_FILE_LOCATION"]
-		} else {
-			targetPath = "/home/system/components/backups/pw.txt"
-		}
-
+		targetPath = paths.BackupPasswordFile
 	case BrowserDevPasswordFileLocation:
-		if env["BROWSERDEV_PASSWORD_FILE_LOCATION"] != "" {
-			targetPath = env["BROWSERDEV_PASSWORD_FILE_LOCATION"]
-		} else {
-			targetPath = "/root/.config/code-server/config.yaml"
-		}
-
+		targetPath = paths.BrowserDevPasswordFile
 	case BrowserDevProxyPath:
-		if env["BROWSERDEV_PROXY_PATH"] != "" {
-			targetPath = env["BROWSERDEV_PROXY_PATH"]
-		} else {
-			targetPath = "/home/system/components/dev/"
-		}
-
+		targetPath = paths.BrowserDevProxy
+	case ScriptsPath:
+		targetPath = paths.Scripts
+	case UpdaterPath:
+		targetPath = paths.Updater
+	case CloudflaredPath:
+		targetPath = paths.Cloudflared
+	case CloudflaredRootPath:
+		targetPath = paths.CloudflaredRoot
+	case CloudflaredServiceConfigLocation:
+		targetPath = paths.CloudflaredService
+	case SecretKeyFileLocation:
+		targetPath = paths.SecretKeyFile
 	default:
 
 		log.Printf("path_key %s nonexistant in GetPath().\n", pathKey)
@@ -224,70 +221,52 @@ func GetPath(pathKey string) string {
 
 }
 
-// WriteOption : Writes a key value pair option into the api shared database
+// WriteOption : Writes a key value pair option into the api shared database. Errors are logged, use OptionStore to handle them.
 func WriteOption(optionKey string, optionValue string) {
+	WriteOptions(map[string]string{optionKey: optionValue})
+}
 
-	db, err := sql.Open("sqlite3", GetSQLiteDbConnectionDetails())
+// WriteOptions : Writes several key value pair options into the api shared database, in a single transaction. Errors are logged, use OptionStore to handle them.
+func WriteOptions(options map[string]string) {
 
-	if err != nil {
-		log.Fatal(err.Error())
+	store, err := GetOptionStore()
+	if err == nil {
+		err = store.SetMany(options, DEFAULT_OPTION_SOURCE)
 	}
 
-	statement, err := db.Prepare("REPLACE into option (name, value, created, updated) VALUES (?, ?, ?, ?);") // Prepare SQL Statement
 	if err != nil {
-		log.Fatal(err.Error())
-	}
-
-	formatedDatetime := GetSQLiteFormattedDateTime(time.Now())
-
-	_, err = statement.Exec(optionKey, optionValue, formatedDatetime, formatedDatetime) // Execute SQL Statement
-	if err != nil {
-		log.Fatal(err.Error())
+		for optionKey := range options {
+			log.Printf("Error writing option %s: %s", optionKey, err)
+		}
 	}
-
-	db.Close()
 }
 
-// ReadOption : Reads a key value pair option from the api shared database
+// ReadOption : Reads a key value pair option from the api shared database. Returns "" if it does not exist or cannot be read (errors are logged).
 func ReadOption(optionKey string) string {
-	
-	db, err := sql.Open("sqlite3", GetSQLiteDbConnectionDetails())
 
+	store, err := GetOptionStore()
 	if err != nil {
-		log.Fatal(err.Error())
+		log.Printf("Error reading option %s: %s", optionKey, err)
+		return ""
 	}
 
-	var optionValue string
-
-	err = db.QueryRow("SELECT value FROM option WHERE name = ?", optionKey).Scan(&optionValue)
-
-	if err != nil {
-		log.Println(err.Error())
+	optionValue, err := store.Get(optionKey)
+	if err != nil && err != ErrOptionNotFound {
+		log.Printf("Error reading option %s: %s", optionKey, err)
 	}
 
-	db.Close()
-
 	return optionValue
 }
 
-// DeleteOption : Deletes a key value pair option from the api shared database
+// DeleteOption : Deletes a key value pair option from the api shared database. Errors are logged, use OptionStore to handle them.
 func DeleteOption(optionKey string) {
-	
-	db, err := sql.Open("sqlite3", GetSQLiteDbConnectionDetails())
 
-	if err != nil {
-		log.Fatal(err.Error())
+	store, err := GetOptionStore()
+	if err == nil {
+		err = store.Delete(optionKey, DEFAULT_OPTION_SOURCE)
 	}
 
-	statement, err := db.Prepare("DELETE FROM option WHERE name = ?;") // Prepare SQL Statement
 	if err != nil {
-		log.Fatal(err.Error())
+		log.Printf("Error deleting option %s: %s", optionKey, err)
 	}
-
-	_, err = statement.Exec(optionKey) // Execute SQL Statement
-	if err != nil {
-		log.Fatal(err.Error())
-	}
-
-	db.Close()
 }
@@ -0,0 +1,337 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/events"
+	"github.com/edgebox-iot/edgeboxctl/internal/options"
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+
+	_ "github.com/mattn/go-sqlite3" // SQlite Driver
+)
+
+// Webhook : Struct representing a subscription of an external URL to some of the events published by edgeboxctl
+type Webhook struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"` // Patterns as in events.Match. Empty means every event.
+	Secret string   `json:"secret"` // Used to sign request bodies, see Sign
+}
+
+// Delivery : Struct representing a single attempt to deliver an event to a webhook
+type Delivery struct {
+	ID         int    `json:"id"`
+	DeliveryID string `json:"delivery_id"`
+	WebhookID  string `json:"webhook_id"`
+	Event      string `json:"event"`
+	URL        string `json:"url"`
+	Attempt    int    `json:"attempt"`
+	StatusCode int    `json:"status_code"`
+	Error      string `json:"error"`
+	Created    string `json:"created"`
+}
+
+// Headers sent along with every delivery
+const (
+	SIGNATURE_HEADER string = "X-Edgebox-Signature"
+	EVENT_HEADER     string = "X-Edgebox-Event"
+	DELIVERY_HEADER  string = "X-Edgebox-Delivery"
+)
+
+const webhooksOption string = "WEBHOOKS"
+const maxAttempts int = 5
+const maxConcurrentDeliveries int = 4
+const maxLoggedDeliveries int = 1000
+const requestTimeout time.Duration = time.Second * 10
+const reloadInterval time.Duration = time.Minute
+
+// retryDelay : Delay before the second attempt of a delivery, doubled on every following attempt
+var retryDelay time.Duration = time.Second * 10
+
+var client = &http.Client{Timeout: requestTimeout}
+var deliverySlots = make(chan bool, maxConcurrentDeliveries)
+
+var webhooks []Webhook
+var webhooksMutex sync.RWMutex
+
+// Start : Starts delivering published events to the webhook subscriptions in the background. Subscriptions are read on the first event, and again every minute.
+func Start() {
+
+	subscription, _ := events.Subscribe()
+	go func() {
+		reload := time.NewTicker(reloadInterval)
+		defer reload.Stop()
+
+		loaded := false
+		for {
+			select {
+			case <-reload.C:
+				// WEBHOOKS is encrypted, so subscriptions are only edited with the add_webhook and remove_webhook tasks,
+				// which reload them. Reloading still picks up the ones changed by another edgeboxctl process.
+				Reload()
+				loaded = true
+
+			case event := <-subscription:
+				if !loaded {
+					Reload()
+					loaded = true
+				}
+				dispatch(event)
+			}
+		}
+	}()
+}
+
+// Reload : Reads the webhook subscriptions again from the options table
+func Reload() {
+	loaded, err := GetWebhooks()
+	if err != nil {
+		log.Println("Error reading webhooks: " + err.Error())
+		return
+	}
+
+	webhooksMutex.Lock()
+	webhooks = loaded
+	webhooksMutex.Unlock()
+}
+
+// GetWebhooks : Returns the webhook subscriptions stored in the options table
+func GetWebhooks() ([]Webhook, error) {
+	subscriptions := []Webhook{}
+	err := options.GetJSON(webhooksOption, &subscriptions)
+	return subscriptions, err
+}
+
+// AddWebhook : Stores a new webhook subscription on behalf of source and returns it
+func AddWebhook(source options.Source, webhookURL string, patterns []string, secret string) (Webhook, error) {
+
+	var webhook Webhook
+
+	parsedURL, err := url.Parse(webhookURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return webhook, fmt.Errorf("invalid webhook url %s", webhookURL)
+	}
+
+	if secret == "" {
+		return webhook, errors.New("a webhook secret is required")
+	}
+
+	subscriptions, err := GetWebhooks()
+	if err != nil {
+		return webhook, err
+	}
+
+	webhook = Webhook{
+		ID:     newID(),
+		URL:    webhookURL,
+		Events: patterns,
+		Secret: secret,
+	}
+
+	err = saveWebhooks(source, append(subscriptions, webhook))
+	return webhook, err
+}
+
+// RemoveWebhook : Deletes a webhook subscription on behalf of source. Returns an error if it does not exist.
+func RemoveWebhook(source options.Source, ID string) error {
+
+	subscriptions, err := GetWebhooks()
+	if err != nil {
+		return err
+	}
+
+	var remaining []Webhook
+	for _, webhook := range subscriptions {
+		if webhook.ID != ID {
+			remaining = append(remaining, webhook)
+		}
+	}
+
+	if len(remaining) == len(subscriptions) {
+		return fmt.Errorf("webhook %s not found", ID)
+	}
+
+	return saveWebhooks(source, remaining)
+}
+
+func saveWebhooks(source options.Source, subscriptions []Webhook) error {
+	if subscriptions == nil {
+		subscriptions = []Webhook{}
+	}
+
+	err := source.SetJSON(webhooksOption, subscriptions)
+	if err != nil {
+		return err
+	}
+
+	Reload()
+	return nil
+}
+
+// Sign : Returns the signature sent in the X-Edgebox-Signature header, "sha256=" followed by the hex HMAC-SHA256 of the body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// dispatch : Starts delivering the event to every matching webhook. Never blocks the event subscription.
+func dispatch(event events.Event) {
+
+	webhooksMutex.RLock()
+	defer webhooksMutex.RUnlock()
+
+	if len(webhooks) == 0 {
+		return
+	}
+
+	// Signed and sent redacted. Only delivery metadata is logged, never the body.
+	body, err := json.Marshal(events.Redact(event))
+	if err != nil {
+		log.Printf("Error encoding %s event for webhooks: %s", event.Type, err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if events.MatchAny(webhook.Events, event.Type) {
+			go deliver(webhook, event.Type, body)
+		}
+	}
+}
+
+// deliver : Posts the event to the webhook, retrying with an exponential backoff until it succeeds or maxAttempts is reached
+func deliver(webhook Webhook, eventType string, body []byte) bool {
+
+	deliveryID := newID()
+	delay := retryDelay
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+
+		deliverySlots <- true
+		statusCode, err := send(webhook, eventType, deliveryID, body)
+		<-deliverySlots
+
+		errorMessage := ""
+		if err != nil {
+			errorMessage = err.Error()
+		}
+		logDelivery(Delivery{
+			DeliveryID: deliveryID,
+			WebhookID:  webhook.ID,
+			Event:      eventType,
+			URL:        webhook.URL,
+			Attempt:    attempt,
+			StatusCode: statusCode,
+			Error:      errorMessage,
+		})
+
+		if err == nil {
+			return true
+		}
+
+		log.Printf("Webhook %s delivery of %s failed (attempt %d of %d): %s", webhook.ID, eventType, attempt, maxAttempts, err)
+		if attempt < maxAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+
+	return false
+}
+
+// send : Makes a single delivery request. Any response other than 2xx is an error.
+func send(webhook Webhook, eventType string, deliveryID string, body []byte) (int, error) {
+
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "edgeboxctl")
+	request.Header.Set(EVENT_HEADER, eventType)
+	request.Header.Set(DELIVERY_HEADER, deliveryID)
+	request.Header.Set(SIGNATURE_HEADER, Sign(webhook.Secret, body))
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected response status %s", response.Status)
+	}
+
+	return response.StatusCode, nil
+}
+
+// logDelivery : Records a delivery attempt, keeping only the most recent maxLoggedDeliveries. The table is created by utils.MigrateDatabase.
+func logDelivery(delivery Delivery) {
+
+	db, err := utils.GetDatabase()
+	if err != nil {
+		log.Println("Error logging webhook delivery: " + err.Error())
+		return
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO webhook_delivery (delivery_id, webhook_id, event, url, attempt, status_code, error, created) VALUES (?, ?, ?, ?, ?, ?, ?, ?);",
+		delivery.DeliveryID, delivery.WebhookID, delivery.Event, delivery.URL, delivery.Attempt, delivery.StatusCode, delivery.Error, utils.GetSQLiteFormattedDateTime(time.Now()),
+	)
+	if err != nil {
+		log.Println("Error logging webhook delivery: " + err.Error())
+		return
+	}
+
+	_, err = db.Exec("DELETE FROM webhook_delivery WHERE id <= (SELECT MAX(id) FROM webhook_delivery) - ?;", maxLoggedDeliveries)
+	if err != nil {
+		log.Println("Error pruning webhook delivery log: " + err.Error())
+	}
+}
+
+// GetDeliveries : Returns the most recent delivery attempts, newest first
+func GetDeliveries(limit int) ([]Delivery, error) {
+
+	deliveries := []Delivery{}
+
+	db, err := utils.GetDatabase()
+	if err != nil {
+		return deliveries, err
+	}
+
+	rows, err := db.Query("SELECT id, delivery_id, webhook_id, event, url, attempt, status_code, error, created FROM webhook_delivery ORDER BY id DESC LIMIT ?;", limit)
+	if err != nil {
+		return deliveries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery Delivery
+		err = rows.Scan(&delivery.ID, &delivery.DeliveryID, &delivery.WebhookID, &delivery.Event, &delivery.URL, &delivery.Attempt, &delivery.StatusCode, &delivery.Error, &delivery.Created)
+		if err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func newID() string {
+	randomBytes := make([]byte, 8)
+	rand.Read(randomBytes)
+	return hex.EncodeToString(randomBytes)
+}
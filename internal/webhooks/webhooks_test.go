@@ -0,0 +1,106 @@
+// +build unit
+
+package webhooks
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edgebox-iot/edgeboxctl/internal/options"
+	"github.com/edgebox-iot/edgeboxctl/internal/utils"
+)
+
+func TestAddAndRemoveWebhook(t *testing.T) {
+	defer utils.SetupTestDatabase(t)()
+
+	_, err := AddWebhook(options.SOURCE_EDGEBOXCTL, "ftp://example.com/hook", nil, "secret")
+	if err == nil {
+		t.Log("Expected an error for a non http(s) url")
+		t.Fail()
+	}
+
+	_, err = AddWebhook(options.SOURCE_EDGEBOXCTL, "https://example.com/hook", nil, "")
+	if err == nil {
+		t.Log("Expected an error for an empty secret")
+		t.Fail()
+	}
+
+	webhook, err := AddWebhook(options.SOURCE_EDGEBOXCTL, "https://example.com/hook", []string{"backup.failed"}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscriptions, _ := GetWebhooks()
+	if len(subscriptions) != 1 || subscriptions[0].ID != webhook.ID {
+		t.Log("Expected the added webhook to be stored, got", subscriptions)
+		t.Fail()
+	}
+
+	err = RemoveWebhook(options.SOURCE_EDGEBOXCTL, webhook.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = RemoveWebhook(options.SOURCE_EDGEBOXCTL, webhook.ID)
+	if err == nil {
+		t.Log("Expected an error removing a webhook that does not exist")
+		t.Fail()
+	}
+}
+
+func TestDeliverRetriesAndSigns(t *testing.T) {
+	defer utils.SetupTestDatabase(t)()
+	retryDelay = time.Millisecond
+
+	var requestsMutex sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(SIGNATURE_HEADER) != Sign("secret", body) {
+			t.Log("Unexpected signature", r.Header.Get(SIGNATURE_HEADER))
+			t.Fail()
+		}
+
+		requestsMutex.Lock()
+		requests++
+		current := requests
+		requestsMutex.Unlock()
+
+		if current == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	webhook := Webhook{ID: "test", URL: server.URL, Secret: "secret"}
+	if !deliver(webhook, "backup.failed", []byte(`{"type":"backup.failed"}`)) {
+		t.Log("Expected the delivery to succeed on retry")
+		t.Fail()
+	}
+
+	deliveries, err := GetDeliveries(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 2 {
+		t.Fatal("Expected 2 logged delivery attempts, got", len(deliveries))
+	}
+	if deliveries[0].Attempt != 2 || deliveries[0].StatusCode != http.StatusNoContent || deliveries[0].Error != "" {
+		t.Log("Unexpected last delivery", deliveries[0])
+		t.Fail()
+	}
+	if deliveries[1].StatusCode != http.StatusInternalServerError || deliveries[1].Error == "" {
+		t.Log("Unexpected first delivery", deliveries[1])
+		t.Fail()
+	}
+	if deliveries[0].DeliveryID != deliveries[1].DeliveryID {
+		t.Log("Expected retries to keep the same delivery id")
+		t.Fail()
+	}
+}
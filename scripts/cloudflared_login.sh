@@ -1,6 +1,6 @@
 #!/usr/bin/env sh
 
 echo "Starting script login"
-cloudflared tunnel login 2>&1 | tee /home/system/components/edgeboxctl/scripts/output.log &
+cloudflared tunnel login 2>&1 | tee "$(dirname "$0")/output.log" &
 echo "sleeping 5 seconds"
 sleep 5
\ No newline at end of file
@@ -2,6 +2,6 @@
 
 echo "Starting script create"
 # script -q -c "cloudflared tunnel login 2>&1 | tee /app/output.log" &
-cloudflared tunnel create edgebox 2>&1 | tee /home/system/components/edgeboxctl/scripts/output.log
+cloudflared tunnel create "${EDGEBOX_TUNNEL_NAME:-edgebox}" 2>&1 | tee "$(dirname "$0")/output.log"
 echo "sleeping 5 seconds"
 sleep 5
\ No newline at end of file
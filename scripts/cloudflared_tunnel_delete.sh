@@ -1,7 +1,7 @@
 #!/usr/bin/env sh
 
 echo "Starting script delete"
-TUNNEL_ORIGIN_CERT=/home/system/.cloudflared/cert.pem
-cloudflared tunnel delete edgebox 2>&1 | tee /home/system/components/edgeboxctl/scripts/output.log &
+export TUNNEL_ORIGIN_CERT="${TUNNEL_ORIGIN_CERT:-/home/system/.cloudflared/cert.pem}"
+cloudflared tunnel delete "${EDGEBOX_TUNNEL_NAME:-edgebox}" 2>&1 | tee "$(dirname "$0")/output.log" &
 echo "sleeping 5 seconds"
 sleep 5
\ No newline at end of file